@@ -0,0 +1,26 @@
+package xorfilter
+
+import "unsafe"
+
+// cacheLineSize is the default alignment of the fingerprints of the filters,
+// so that the fingerprints of a segment span as few cache lines as possible.
+const cacheLineSize = 64
+
+// makeFingerprints returns n zeroed fingerprints, the first of which is
+// aligned to align bytes, a power of two. The array is cut from a larger
+// allocation; its capacity is n, so that appending to it reallocates.
+func makeFingerprints(n int, align int) []uint8 {
+	if n == 0 {
+		return []uint8{}
+	}
+	buf := make([]uint8, n+align-1)
+	offset := int(-uintptr(unsafe.Pointer(&buf[0])) & uintptr(align-1))
+	return buf[offset : offset+n : offset+n]
+}
+
+// copyFingerprints returns an aligned copy of fingerprints.
+func copyFingerprints(fingerprints []uint8) []uint8 {
+	c := makeFingerprints(len(fingerprints), cacheLineSize)
+	copy(c, fingerprints)
+	return c
+}
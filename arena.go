@@ -0,0 +1,56 @@
+package xorfilter
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// carve lays the temporary arrays of a construction out in buf, instead of
+// allocating them, in decreasing order of alignment so that only the start of
+// buf needs aligning. The arrays are cleared. It returns ErrScratchTooSmall
+// if buf cannot hold them.
+func (p *Populator) carve(buf []byte, size, capacity uint32, blockBits int, lowMemory, ranges bool) error {
+	reverseOrder := uint64(size) + 1
+	blocks := uint64(1) << uint(blockBits)
+	if lowMemory {
+		reverseOrder, blocks = lowMemoryChunkSize, 0
+	}
+	peeled := uint64(0)
+	if ranges {
+		peeled = uint64(capacity)
+	}
+	need := 8*uint64(capacity) + // t2hash
+		8*reverseOrder +
+		uint64(unsafe.Sizeof(uint(0)))*blocks + // startPos
+		4*uint64(capacity) + // alone
+		4*peeled +
+		uint64(capacity) // t2count
+	if len(buf) == 0 {
+		return ErrScratchTooSmall
+	}
+	offset := uint64(-uintptr(unsafe.Pointer(&buf[0])) & 7)
+	if uint64(len(buf)) < offset || uint64(len(buf))-offset < need {
+		return ErrScratchTooSmall
+	}
+	buf = buf[offset : offset+need]
+	for i := range buf {
+		buf[i] = 0
+	}
+	off := uint64(0)
+	take := func(slice unsafe.Pointer, n, width uint64) {
+		if n == 0 {
+			return
+		}
+		h := (*reflect.SliceHeader)(slice)
+		h.Data, h.Len, h.Cap = uintptr(unsafe.Pointer(&buf[off])), int(n), int(n)
+		off += n * width
+	}
+	*p = Populator{ranges: p.ranges}
+	take(unsafe.Pointer(&p.t2hash), uint64(capacity), 8)
+	take(unsafe.Pointer(&p.reverseOrder), reverseOrder, 8)
+	take(unsafe.Pointer(&p.startPos), blocks, uint64(unsafe.Sizeof(uint(0))))
+	take(unsafe.Pointer(&p.alone), uint64(capacity), 4)
+	take(unsafe.Pointer(&p.peeled), peeled, 4)
+	take(unsafe.Pointer(&p.t2count), uint64(capacity), 1)
+	return nil
+}
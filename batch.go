@@ -0,0 +1,256 @@
+package xorfilter
+
+import (
+	"encoding/binary"
+	"unsafe"
+)
+
+// ContainsBatch sets out[i] to whether keys[i] is part of the set, as
+// Contains does. out must be at least as long as keys. On the CPUs that
+// support it, the keys are queried several at a time with vector
+// instructions; otherwise, the hashes of a few keys are computed before their
+// fingerprints are read, so that the memory accesses overlap.
+func (filter *BinaryFuse8) ContainsBatch(keys []uint64, out []bool) {
+	out = out[:len(keys)]
+	if filter == nil {
+		for i := range out {
+			out[i] = false
+		}
+		return
+	}
+	i := filter.containsBatchKernel(keys, out)
+	filter.containsBatchGeneric(keys[i:], out[i:])
+}
+
+// batchKernelID identifies a kernel of ContainsBatch. The kernel is picked
+// once, from the features of the CPU, so that a single binary runs the
+// fastest one; the purego and tinygo build tags restrict it to
+// kernelGeneric.
+type batchKernelID int
+
+const (
+	// kernelGeneric is containsBatchGeneric alone.
+	kernelGeneric batchKernelID = iota
+	// kernelARM64 queries two keys at a time on arm64.
+	kernelARM64
+	// kernelAVX2 queries four keys at a time with AVX2 on amd64.
+	kernelAVX2
+	// kernelAVX512 queries eight keys at a time with AVX-512 on amd64.
+	kernelAVX512
+)
+
+// ContainsBatchBits is ContainsBatch with the results packed in a bitset: bit
+// i%64 of out[i/64] is set if keys[i] is part of the set. out must have at
+// least (len(keys)+63)/64 words, which are overwritten; the bits past the
+// last key are cleared.
+func (filter *BinaryFuse8) ContainsBatchBits(keys []uint64, out []uint64) {
+	out = out[:(len(keys)+63)/64]
+	var found [64]bool
+	for w := range out {
+		chunk := keys[64*w:]
+		if len(chunk) > 64 {
+			chunk = chunk[:64]
+		}
+		filter.ContainsBatch(chunk, found[:])
+		word := uint64(0)
+		for i, ok := range found[:len(chunk)] {
+			if ok {
+				word |= 1 << uint(i)
+			}
+		}
+		out[w] = word
+	}
+}
+
+// containsBatchGeneric is ContainsBatch in Go, eight keys at a time. A filter
+// that passes Validate has all the indices of getHashFromHash within its
+// fingerprints, so they are read without bounds checks. The xors of the eight
+// keys are packed in a word, whose zero bytes are then found and stored as
+// eight results at once.
+func (filter *BinaryFuse8) containsBatchGeneric(keys []uint64, out []bool) {
+	if len(keys) == 0 {
+		return
+	}
+	if filter.Validate() != nil {
+		for i, key := range keys {
+			out[i] = filter.Contains(key)
+		}
+		return
+	}
+	out = out[:len(keys)]
+	fingerprints := unsafe.Pointer(&filter.Fingerprints[0])
+	var h [8][3]uint32
+	var f [8]uint8
+	for len(keys) >= 8 {
+		k := keys[:8:8]
+		for j := range h {
+			hash := mixsplit(k[j], filter.Seed)
+			if filter.hasher != nil || filter.salted {
+				hash = filter.hash(k[j])
+			}
+			f[j] = filter.fingerprintOf(hash)
+			h[j][0], h[j][1], h[j][2] = filter.getHashFromHash(hash)
+		}
+		word := uint64(0)
+		for j := range h {
+			x := f[j] ^ fingerprintAt(fingerprints, h[j][0]) ^ fingerprintAt(fingerprints, h[j][1]) ^ fingerprintAt(fingerprints, h[j][2])
+			word |= uint64(x) << (8 * uint(j))
+		}
+		binary.LittleEndian.PutUint64((*[8]byte)(unsafe.Pointer(&out[0]))[:], zeroBytes(word))
+		keys, out = keys[8:], out[8:]
+	}
+	for i, key := range keys {
+		hash := filter.hash(key)
+		h0, h1, h2 := filter.getHashFromHash(hash)
+		out[i] = filter.fingerprintOf(hash)^fingerprintAt(fingerprints, h0)^fingerprintAt(fingerprints, h1)^fingerprintAt(fingerprints, h2) == 0
+	}
+}
+
+// zeroBytes returns the word with 1 in the bytes where x has 0, and 0 in the
+// others, which are the bytes of eight bools. Unlike the usual test for a zero
+// byte, it has no false positive next to a zero byte.
+func zeroBytes(x uint64) uint64 {
+	const low7 = 0x7f7f7f7f7f7f7f7f
+	// The high bit of a byte of t is set if any bit of the byte of x is.
+	t := (x&low7 + low7) | x
+	return ^t >> 7 & 0x0101010101010101
+}
+
+// fingerprintAt returns the fingerprint at index i of the array at
+// fingerprints, without bounds check.
+func fingerprintAt(fingerprints unsafe.Pointer, i uint32) uint8 {
+	return *(*uint8)(unsafe.Pointer(uintptr(fingerprints) + uintptr(i)))
+}
+
+// ContainsAll returns `true` if all the keys are part of the set, as Contains
+// does, and `true` for no keys. It stops at the first key that is missing.
+func (filter *BinaryFuse8) ContainsAll(keys []uint64) bool {
+	for _, key := range keys {
+		if !filter.Contains(key) {
+			return false
+		}
+	}
+	return true
+}
+
+// ContainsAny returns `true` if any of the keys is part of the set, as
+// Contains does, and `false` for no keys. It stops at the first key that is
+// present.
+func (filter *BinaryFuse8) ContainsAny(keys []uint64) bool {
+	for _, key := range keys {
+		if filter.Contains(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// CountContained returns the number of keys that are part of the set, as
+// Contains answers for them, for the jobs that only need the count, such as
+// the estimation of the overlap of a stream with the set. The keys are
+// queried by the kernels of ContainsBatch, a chunk at a time, without
+// allocating their results.
+func (filter *BinaryFuse8) CountContained(keys []uint64) int {
+	count := 0
+	if filter.Validate() != nil {
+		for _, key := range keys {
+			if filter.Contains(key) {
+				count++
+			}
+		}
+		return count
+	}
+	var found [256]bool
+	for len(keys) > 0 {
+		chunk := keys
+		if len(chunk) > len(found) {
+			chunk = chunk[:len(found)]
+		}
+		out := found[:len(chunk)]
+		i := filter.containsBatchKernel(chunk, out)
+		filter.containsBatchGeneric(chunk[i:], out[i:])
+		for _, ok := range out {
+			if ok {
+				count++
+			}
+		}
+		keys = keys[len(chunk):]
+	}
+	return count
+}
+
+// ContainsAll returns `true` if all the keys are part of the set, as Contains
+// does, and `true` for no keys. It stops at the first key that is missing.
+func (filter *BinaryFuse8Big) ContainsAll(keys []uint64) bool {
+	for _, key := range keys {
+		if !filter.Contains(key) {
+			return false
+		}
+	}
+	return true
+}
+
+// ContainsAny returns `true` if any of the keys is part of the set, as
+// Contains does, and `false` for no keys. It stops at the first key that is
+// present.
+func (filter *BinaryFuse8Big) ContainsAny(keys []uint64) bool {
+	for _, key := range keys {
+		if filter.Contains(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// CountContained returns the number of keys that are part of the set, as
+// Contains answers for them. Unlike that of BinaryFuse8, it queries the keys
+// one at a time: consecutive keys fall in different shards, so a batch of
+// the keys of a shard would first have to be gathered.
+func (filter *BinaryFuse8Big) CountContained(keys []uint64) int {
+	count := 0
+	for _, key := range keys {
+		if filter.Contains(key) {
+			count++
+		}
+	}
+	return count
+}
+
+// Contains4 is Contains for four keys. The indices of the fingerprints of all
+// the keys are computed and prefetched before any fingerprint is read, so that
+// the cache misses of the keys overlap instead of following one another.
+func (filter *BinaryFuse8) Contains4(keys [4]uint64) (out [4]bool) {
+	var f [4]uint8
+	var indices [12]uint32
+	filter.containsPrefetched(keys[:], out[:], f[:], indices[:])
+	return out
+}
+
+// Contains8 is Contains4 for eight keys.
+func (filter *BinaryFuse8) Contains8(keys [8]uint64) (out [8]bool) {
+	var f [8]uint8
+	var indices [24]uint32
+	filter.containsPrefetched(keys[:], out[:], f[:], indices[:])
+	return out
+}
+
+// containsPrefetched queries keys with the scratch arrays f, for their
+// fingerprints, and indices, for the three indices of each key.
+func (filter *BinaryFuse8) containsPrefetched(keys []uint64, out []bool, f []uint8, indices []uint32) {
+	if filter.Validate() != nil {
+		for i, key := range keys {
+			out[i] = filter.Contains(key)
+		}
+		return
+	}
+	for i, key := range keys {
+		hash := filter.hash(key)
+		f[i] = filter.fingerprintOf(hash)
+		indices[3*i], indices[3*i+1], indices[3*i+2] = filter.getHashFromHash(hash)
+	}
+	fingerprints := unsafe.Pointer(&filter.Fingerprints[0])
+	prefetchFingerprints(fingerprints, indices)
+	for i := range keys {
+		out[i] = f[i]^fingerprintAt(fingerprints, indices[3*i])^fingerprintAt(fingerprints, indices[3*i+1])^fingerprintAt(fingerprints, indices[3*i+2]) == 0
+	}
+}
@@ -0,0 +1,119 @@
+//go:build amd64 && !purego && !tinygo
+// +build amd64,!purego,!tinygo
+
+package xorfilter
+
+import "unsafe"
+
+// batchKernel is the fastest kernel of ContainsBatch that the CPU supports.
+var batchKernel = detectBatchKernel()
+
+// detectBatchKernel picks kernelAVX512 if the CPU supports AVX-512F,
+// AVX-512DQ and BMI2, and the operating system saves the ZMM and mask
+// registers, or else kernelAVX2 if it supports AVX2 and BMI2, and the
+// operating system saves the YMM registers.
+func detectBatchKernel() batchKernelID {
+	maxID, _, _, _ := cpuid(0, 0)
+	if maxID < 7 {
+		return kernelGeneric
+	}
+	_, _, ecx1, _ := cpuid(1, 0)
+	const osxsave, avx = 1 << 27, 1 << 28
+	if ecx1&(osxsave|avx) != osxsave|avx {
+		return kernelGeneric
+	}
+	xcr0, _ := xgetbv()
+	if xcr0&6 != 6 {
+		// The XMM and YMM registers are not saved.
+		return kernelGeneric
+	}
+	_, ebx7, _, _ := cpuid(7, 0)
+	const avx2, bmi2, avx512f, avx512dq = 1 << 5, 1 << 8, 1 << 16, 1 << 17
+	if ebx7&(avx2|bmi2) != avx2|bmi2 {
+		return kernelGeneric
+	}
+	if ebx7&(avx512f|avx512dq) == avx512f|avx512dq && xcr0&0xe0 == 0xe0 {
+		// The mask and ZMM registers are saved too.
+		return kernelAVX512
+	}
+	return kernelAVX2
+}
+
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+
+func xgetbv() (eax, edx uint32)
+
+// containsBatchAVX2 queries the keys four at a time, for a multiple of four
+// keys, the default hash and at least 8 fingerprints. The fingerprints are
+// gathered 8 bytes at a time, at an offset clamped to the end of the array.
+//
+//go:noescape
+func containsBatchAVX2(keys []uint64, out []bool, fingerprints []uint8, seed uint64, segmentCountLength, segmentLength, segmentLengthMask uint32)
+
+// containsBatchAVX512 is containsBatchAVX2 eight keys at a time, for a
+// multiple of eight keys.
+//
+//go:noescape
+func containsBatchAVX512(keys []uint64, out []bool, fingerprints []uint8, seed uint64, segmentCountLength, segmentLength, segmentLengthMask uint32)
+
+// containsBatchKernel queries a prefix of keys with the vector kernel of the
+// CPU, if any, and returns its length.
+func (filter *BinaryFuse8) containsBatchKernel(keys []uint64, out []bool) int {
+	if filter.hasher != nil || filter.fingerprinter != nil || filter.salted || len(filter.Fingerprints) < 8 {
+		return 0
+	}
+	switch batchKernel {
+	case kernelAVX512:
+		n := len(keys) &^ 7
+		if n > 0 {
+			containsBatchAVX512(keys[:n], out[:n], filter.Fingerprints, filter.Seed,
+				filter.SegmentCountLength, filter.SegmentLength, filter.SegmentLengthMask)
+		}
+		return n
+	case kernelAVX2:
+		n := len(keys) &^ 3
+		if n > 0 {
+			containsBatchAVX2(keys[:n], out[:n], filter.Fingerprints, filter.Seed,
+				filter.SegmentCountLength, filter.SegmentLength, filter.SegmentLengthMask)
+		}
+		return n
+	}
+	return 0
+}
+
+// mixsplitAVX2 sets dst[i] to mixsplit(keys[i], seed), for a multiple of four
+// keys.
+//
+//go:noescape
+func mixsplitAVX2(dst, keys []uint64, seed uint64)
+
+// mixsplitAVX512 is mixsplitAVX2 for a multiple of eight keys.
+//
+//go:noescape
+func mixsplitAVX512(dst, keys []uint64, seed uint64)
+
+// mixsplitKernel mixes a prefix of keys into dst with the vector kernel of
+// the CPU, if any, and returns its length.
+func mixsplitKernel(dst, keys []uint64, seed uint64) int {
+	switch batchKernel {
+	case kernelAVX512:
+		n := len(keys) &^ 7
+		if n > 0 {
+			mixsplitAVX512(dst[:n], keys[:n], seed)
+		}
+		return n
+	case kernelAVX2:
+		n := len(keys) &^ 3
+		if n > 0 {
+			mixsplitAVX2(dst[:n], keys[:n], seed)
+		}
+		return n
+	}
+	return 0
+}
+
+// prefetchFingerprints prefetches the fingerprints at indices of the array at
+// fingerprints.
+//
+//go:noescape
+func prefetchFingerprints(fingerprints unsafe.Pointer, indices []uint32)
@@ -0,0 +1,333 @@
+//go:build amd64 && !purego && !tinygo
+// +build amd64,!purego,!tinygo
+
+#include "textflag.h"
+
+// The multipliers of murmur64, split in 32-bit halves for VPMULUDQ.
+DATA murmurC1<>+0(SB)/8, $0xff51afd7ed558ccd
+DATA murmurC1<>+8(SB)/8, $0xff51afd7ed558ccd
+DATA murmurC1<>+16(SB)/8, $0xff51afd7ed558ccd
+DATA murmurC1<>+24(SB)/8, $0xff51afd7ed558ccd
+GLOBL murmurC1<>(SB), RODATA|NOPTR, $32
+
+DATA murmurC1hi<>+0(SB)/8, $0xff51afd7
+DATA murmurC1hi<>+8(SB)/8, $0xff51afd7
+DATA murmurC1hi<>+16(SB)/8, $0xff51afd7
+DATA murmurC1hi<>+24(SB)/8, $0xff51afd7
+GLOBL murmurC1hi<>(SB), RODATA|NOPTR, $32
+
+DATA murmurC2<>+0(SB)/8, $0xc4ceb9fe1a85ec53
+DATA murmurC2<>+8(SB)/8, $0xc4ceb9fe1a85ec53
+DATA murmurC2<>+16(SB)/8, $0xc4ceb9fe1a85ec53
+DATA murmurC2<>+24(SB)/8, $0xc4ceb9fe1a85ec53
+GLOBL murmurC2<>(SB), RODATA|NOPTR, $32
+
+DATA murmurC2hi<>+0(SB)/8, $0xc4ceb9fe
+DATA murmurC2hi<>+8(SB)/8, $0xc4ceb9fe
+DATA murmurC2hi<>+16(SB)/8, $0xc4ceb9fe
+DATA murmurC2hi<>+24(SB)/8, $0xc4ceb9fe
+GLOBL murmurC2hi<>(SB), RODATA|NOPTR, $32
+
+// func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+TEXT ·cpuid(SB), NOSPLIT, $0-24
+	MOVL eaxArg+0(FP), AX
+	MOVL ecxArg+4(FP), CX
+	CPUID
+	MOVL AX, eax+8(FP)
+	MOVL BX, ebx+12(FP)
+	MOVL CX, ecx+16(FP)
+	MOVL DX, edx+20(FP)
+	RET
+
+// func xgetbv() (eax, edx uint32)
+TEXT ·xgetbv(SB), NOSPLIT, $0-8
+	MOVL $0, CX
+	XGETBV
+	MOVL AX, eax+0(FP)
+	MOVL DX, edx+4(FP)
+	RET
+
+// MUL64 sets x to the low 64 bits of x*c in each lane, where clo holds c and
+// chi holds c>>32.
+#define MUL64(x, clo, chi, t0, t1) \
+	VPSRLQ   $32, x, t0; \
+	VPMULUDQ clo, t0, t0; \
+	VPMULUDQ chi, x, t1; \
+	VPADDQ   t1, t0, t0; \
+	VPSLLQ   $32, t0, t0; \
+	VPMULUDQ clo, x, x; \
+	VPADDQ   t0, x, x
+
+// XORFINGERPRINT xors into f the fingerprint at each index of idx. The 8
+// bytes are gathered at the index clamped to Y6, the last index at which 8
+// bytes can be read, and shifted to bring the fingerprint in the low byte.
+#define XORFINGERPRINT(idx, f) \
+	VPMINUD    Y6, idx, Y11; \
+	VPSUBQ     Y11, idx, idx; \
+	VPSLLQ     $3, idx, idx; \
+	VPCMPEQQ   Y12, Y12, Y12; \
+	VPGATHERQQ Y12, (DX)(Y11*1), Y13; \
+	VPSRLVQ    idx, Y13, Y13; \
+	VPXOR      Y13, f, f
+
+// func containsBatchAVX2(keys []uint64, out []bool, fingerprints []uint8, seed uint64, segmentCountLength, segmentLength, segmentLengthMask uint32)
+TEXT ·containsBatchAVX2(SB), NOSPLIT, $0-92
+	MOVQ keys_base+0(FP), SI
+	MOVQ keys_len+8(FP), CX
+	MOVQ out_base+24(FP), DI
+	MOVQ fingerprints_base+48(FP), DX
+	MOVQ fingerprints_len+56(FP), AX
+	SUBQ $8, AX
+	MOVQ AX, X0
+	VPBROADCASTQ X0, Y6
+	MOVQ $0xff, AX
+	MOVQ AX, X0
+	VPBROADCASTQ X0, Y7
+	MOVL segmentLengthMask+88(FP), AX
+	MOVQ AX, X0
+	VPBROADCASTQ X0, Y8
+	MOVL segmentLength+84(FP), AX
+	MOVQ AX, X0
+	VPBROADCASTQ X0, Y9
+	MOVL segmentCountLength+80(FP), AX
+	MOVQ AX, X0
+	VPBROADCASTQ X0, Y10
+	VPBROADCASTQ seed+72(FP), Y15
+	VMOVDQU murmurC1<>(SB), Y14
+	MOVL $0x01010101, R8
+	SHRQ $2, CX
+	JZ   done
+
+loop:
+	// hash = murmur64(key + seed)
+	VMOVDQU (SI), Y0
+	VPADDQ  Y15, Y0, Y0
+	VPSRLQ  $33, Y0, Y1
+	VPXOR   Y1, Y0, Y0
+	MUL64(Y0, Y14, murmurC1hi<>(SB), Y1, Y2)
+	VPSRLQ  $33, Y0, Y1
+	VPXOR   Y1, Y0, Y0
+	MUL64(Y0, murmurC2<>(SB), murmurC2hi<>(SB), Y1, Y2)
+	VPSRLQ  $33, Y0, Y1
+	VPXOR   Y1, Y0, Y0
+
+	// h0 = hash * SegmentCountLength >> 64
+	VPMULUDQ Y10, Y0, Y1
+	VPSRLQ   $32, Y1, Y1
+	VPSRLQ   $32, Y0, Y2
+	VPMULUDQ Y10, Y2, Y2
+	VPADDQ   Y1, Y2, Y2
+	VPSRLQ   $32, Y2, Y2
+
+	// h1 = (h0 + SegmentLength) ^ (hash >> 18 & SegmentLengthMask)
+	VPADDQ Y9, Y2, Y3
+	VPSRLQ $18, Y0, Y1
+	VPAND  Y8, Y1, Y1
+	VPXOR  Y1, Y3, Y3
+
+	// h2 = (h0 + 2*SegmentLength) ^ (hash & SegmentLengthMask)
+	VPADDQ Y9, Y2, Y4
+	VPADDQ Y9, Y4, Y4
+	VPAND  Y8, Y0, Y1
+	VPXOR  Y1, Y4, Y4
+
+	// f = hash ^ hash >> 32
+	VPSRLQ $32, Y0, Y5
+	VPXOR  Y0, Y5, Y5
+
+	XORFINGERPRINT(Y2, Y5)
+	XORFINGERPRINT(Y3, Y5)
+	XORFINGERPRINT(Y4, Y5)
+
+	// out = f & 0xff == 0, one byte per key
+	VPAND     Y7, Y5, Y5
+	VPXOR     Y0, Y0, Y0
+	VPCMPEQQ  Y0, Y5, Y5
+	VMOVMSKPD Y5, AX
+	PDEPL     R8, AX, AX
+	MOVL      AX, (DI)
+
+	ADDQ $32, SI
+	ADDQ $4, DI
+	DECQ CX
+	JNZ  loop
+
+done:
+	VZEROUPPER
+	RET
+
+// XORFINGERPRINT512 is XORFINGERPRINT for eight keys.
+#define XORFINGERPRINT512(idx, f) \
+	VPMINUQ    Z6, idx, Z11; \
+	VPSUBQ     Z11, idx, idx; \
+	VPSLLQ     $3, idx, idx; \
+	KXNORB     K1, K1, K1; \
+	VPGATHERQQ (DX)(Z11*1), K1, Z13; \
+	VPSRLVQ    idx, Z13, Z13; \
+	VPXORQ     Z13, f, f
+
+// func containsBatchAVX512(keys []uint64, out []bool, fingerprints []uint8, seed uint64, segmentCountLength, segmentLength, segmentLengthMask uint32)
+TEXT ·containsBatchAVX512(SB), NOSPLIT, $0-92
+	MOVQ keys_base+0(FP), SI
+	MOVQ keys_len+8(FP), CX
+	MOVQ out_base+24(FP), DI
+	MOVQ fingerprints_base+48(FP), DX
+	MOVQ fingerprints_len+56(FP), AX
+	SUBQ $8, AX
+	MOVQ AX, X0
+	VPBROADCASTQ X0, Z6
+	MOVQ $0xff, AX
+	MOVQ AX, X0
+	VPBROADCASTQ X0, Z7
+	MOVL segmentLengthMask+88(FP), AX
+	MOVQ AX, X0
+	VPBROADCASTQ X0, Z8
+	MOVL segmentLength+84(FP), AX
+	MOVQ AX, X0
+	VPBROADCASTQ X0, Z9
+	MOVL segmentCountLength+80(FP), AX
+	MOVQ AX, X0
+	VPBROADCASTQ X0, Z10
+	VPBROADCASTQ seed+72(FP), Z15
+	VPBROADCASTQ murmurC1<>(SB), Z14
+	VPBROADCASTQ murmurC2<>(SB), Z12
+	MOVQ $0x0101010101010101, R8
+	SHRQ $3, CX
+	JZ   done512
+
+loop512:
+	// hash = murmur64(key + seed)
+	VMOVDQU64 (SI), Z0
+	VPADDQ    Z15, Z0, Z0
+	VPSRLQ    $33, Z0, Z1
+	VPXORQ    Z1, Z0, Z0
+	VPMULLQ   Z14, Z0, Z0
+	VPSRLQ    $33, Z0, Z1
+	VPXORQ    Z1, Z0, Z0
+	VPMULLQ   Z12, Z0, Z0
+	VPSRLQ    $33, Z0, Z1
+	VPXORQ    Z1, Z0, Z0
+
+	// h0 = hash * SegmentCountLength >> 64
+	VPMULUDQ Z10, Z0, Z1
+	VPSRLQ   $32, Z1, Z1
+	VPSRLQ   $32, Z0, Z2
+	VPMULUDQ Z10, Z2, Z2
+	VPADDQ   Z1, Z2, Z2
+	VPSRLQ   $32, Z2, Z2
+
+	// h1 = (h0 + SegmentLength) ^ (hash >> 18 & SegmentLengthMask)
+	VPADDQ Z9, Z2, Z3
+	VPSRLQ $18, Z0, Z1
+	VPANDQ Z8, Z1, Z1
+	VPXORQ Z1, Z3, Z3
+
+	// h2 = (h0 + 2*SegmentLength) ^ (hash & SegmentLengthMask)
+	VPADDQ Z9, Z2, Z4
+	VPADDQ Z9, Z4, Z4
+	VPANDQ Z8, Z0, Z1
+	VPXORQ Z1, Z4, Z4
+
+	// f = hash ^ hash >> 32
+	VPSRLQ $32, Z0, Z5
+	VPXORQ Z0, Z5, Z5
+
+	XORFINGERPRINT512(Z2, Z5)
+	XORFINGERPRINT512(Z3, Z5)
+	XORFINGERPRINT512(Z4, Z5)
+
+	// out = f & 0xff == 0, one byte per key
+	VPTESTNMQ Z7, Z5, K2
+	KMOVB     K2, AX
+	PDEPQ     R8, AX, AX
+	MOVQ      AX, (DI)
+
+	ADDQ $64, SI
+	ADDQ $8, DI
+	DECQ CX
+	JNZ  loop512
+
+done512:
+	VZEROUPPER
+	RET
+
+// func mixsplitAVX2(dst, keys []uint64, seed uint64)
+TEXT ·mixsplitAVX2(SB), NOSPLIT, $0-56
+	MOVQ         dst_base+0(FP), DI
+	MOVQ         keys_base+24(FP), SI
+	MOVQ         keys_len+32(FP), CX
+	VPBROADCASTQ seed+48(FP), Y15
+	VMOVDQU      murmurC1<>(SB), Y14
+	SHRQ         $2, CX
+	JZ           mixed
+
+mix:
+	VMOVDQU (SI), Y0
+	VPADDQ  Y15, Y0, Y0
+	VPSRLQ  $33, Y0, Y1
+	VPXOR   Y1, Y0, Y0
+	MUL64(Y0, Y14, murmurC1hi<>(SB), Y1, Y2)
+	VPSRLQ  $33, Y0, Y1
+	VPXOR   Y1, Y0, Y0
+	MUL64(Y0, murmurC2<>(SB), murmurC2hi<>(SB), Y1, Y2)
+	VPSRLQ  $33, Y0, Y1
+	VPXOR   Y1, Y0, Y0
+	VMOVDQU Y0, (DI)
+	ADDQ    $32, SI
+	ADDQ    $32, DI
+	DECQ    CX
+	JNZ     mix
+
+mixed:
+	VZEROUPPER
+	RET
+
+// func mixsplitAVX512(dst, keys []uint64, seed uint64)
+TEXT ·mixsplitAVX512(SB), NOSPLIT, $0-56
+	MOVQ         dst_base+0(FP), DI
+	MOVQ         keys_base+24(FP), SI
+	MOVQ         keys_len+32(FP), CX
+	VPBROADCASTQ seed+48(FP), Z15
+	VPBROADCASTQ murmurC1<>(SB), Z14
+	VPBROADCASTQ murmurC2<>(SB), Z12
+	SHRQ         $3, CX
+	JZ           mixed512
+
+mix512:
+	VMOVDQU64 (SI), Z0
+	VPADDQ    Z15, Z0, Z0
+	VPSRLQ    $33, Z0, Z1
+	VPXORQ    Z1, Z0, Z0
+	VPMULLQ   Z14, Z0, Z0
+	VPSRLQ    $33, Z0, Z1
+	VPXORQ    Z1, Z0, Z0
+	VPMULLQ   Z12, Z0, Z0
+	VPSRLQ    $33, Z0, Z1
+	VPXORQ    Z1, Z0, Z0
+	VMOVDQU64 Z0, (DI)
+	ADDQ      $64, SI
+	ADDQ      $64, DI
+	DECQ      CX
+	JNZ       mix512
+
+mixed512:
+	VZEROUPPER
+	RET
+
+// func prefetchFingerprints(fingerprints unsafe.Pointer, indices []uint32)
+TEXT ·prefetchFingerprints(SB), NOSPLIT, $0-32
+	MOVQ  fingerprints+0(FP), AX
+	MOVQ  indices_base+8(FP), SI
+	MOVQ  indices_len+16(FP), CX
+	TESTQ CX, CX
+	JZ    prefetched
+
+prefetch:
+	MOVL       (SI), DX
+	PREFETCHT0 (AX)(DX*1)
+	ADDQ       $4, SI
+	DECQ       CX
+	JNZ        prefetch
+
+prefetched:
+	RET
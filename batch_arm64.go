@@ -0,0 +1,46 @@
+//go:build arm64 && !purego && !tinygo
+// +build arm64,!purego,!tinygo
+
+package xorfilter
+
+import "unsafe"
+
+// batchKernel is the kernel of ContainsBatch: every arm64 CPU runs the
+// same one, in general-purpose registers.
+var batchKernel = kernelARM64
+
+// containsBatchARM64 queries the keys two at a time, for a multiple of two
+// keys and the default hash. NEON has neither 64-bit multiplications nor
+// gathers, so the kernel interleaves the keys in general-purpose registers,
+// which overlaps their six memory accesses.
+//
+//go:noescape
+func containsBatchARM64(keys []uint64, out []bool, fingerprints []uint8, seed uint64, segmentCountLength, segmentLength, segmentLengthMask uint32)
+
+// containsBatchKernel queries a prefix of keys with the vector kernel of the
+// CPU, if any, and returns its length. The kernel reads the fingerprints
+// without bounds checks, so it only queries the filters that pass Validate.
+func (filter *BinaryFuse8) containsBatchKernel(keys []uint64, out []bool) int {
+	if filter.hasher != nil || filter.fingerprinter != nil || filter.salted || batchKernel != kernelARM64 ||
+		len(filter.Fingerprints) < 8 || filter.Validate() != nil {
+		return 0
+	}
+	n := len(keys) &^ 1
+	if n > 0 {
+		containsBatchARM64(keys[:n], out[:n], filter.Fingerprints, filter.Seed,
+			filter.SegmentCountLength, filter.SegmentLength, filter.SegmentLengthMask)
+	}
+	return n
+}
+
+// mixsplitKernel mixes a prefix of keys into dst with the vector kernel of
+// the CPU, if any, and returns its length.
+func mixsplitKernel(dst, keys []uint64, seed uint64) int {
+	return 0
+}
+
+// prefetchFingerprints prefetches the fingerprints at indices of the array at
+// fingerprints.
+//
+//go:noescape
+func prefetchFingerprints(fingerprints unsafe.Pointer, indices []uint32)
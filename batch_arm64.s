@@ -0,0 +1,104 @@
+//go:build arm64 && !purego && !tinygo
+// +build arm64,!purego,!tinygo
+
+#include "textflag.h"
+
+// func containsBatchARM64(keys []uint64, out []bool, fingerprints []uint8, seed uint64, segmentCountLength, segmentLength, segmentLengthMask uint32)
+TEXT ·containsBatchARM64(SB), NOSPLIT, $0-92
+	MOVD  keys_base+0(FP), R0
+	MOVD  keys_len+8(FP), R1
+	MOVD  out_base+24(FP), R2
+	MOVD  fingerprints_base+48(FP), R3
+	MOVD  seed+72(FP), R4
+	MOVWU segmentCountLength+80(FP), R5
+	MOVWU segmentLength+84(FP), R6
+	MOVWU segmentLengthMask+88(FP), R7
+	MOVD  $0xff51afd7ed558ccd, R8
+	MOVD  $0xc4ceb9fe1a85ec53, R9
+	LSR   $1, R1, R1
+	CBZ   R1, done
+
+loop:
+	// hash = murmur64(key + seed)
+	LDP.P 16(R0), (R10, R11)
+	ADD   R4, R10, R10
+	ADD   R4, R11, R11
+	EOR   R10>>33, R10, R10
+	EOR   R11>>33, R11, R11
+	MUL   R8, R10, R10
+	MUL   R8, R11, R11
+	EOR   R10>>33, R10, R10
+	EOR   R11>>33, R11, R11
+	MUL   R9, R10, R10
+	MUL   R9, R11, R11
+	EOR   R10>>33, R10, R10
+	EOR   R11>>33, R11, R11
+
+	// h0 = hash * SegmentCountLength >> 64
+	UMULH R5, R10, R12
+	UMULH R5, R11, R13
+
+	// h1 = (h0 + SegmentLength) ^ (hash >> 18 & SegmentLengthMask)
+	ADD R6, R12, R14
+	ADD R6, R13, R15
+	AND R10>>18, R7, R23
+	AND R11>>18, R7, R24
+	EOR R23, R14, R14
+	EOR R24, R15, R15
+
+	// h2 = (h0 + 2*SegmentLength) ^ (hash & SegmentLengthMask)
+	ADD R6<<1, R12, R19
+	ADD R6<<1, R13, R20
+	AND R10, R7, R23
+	AND R11, R7, R24
+	EOR R23, R19, R19
+	EOR R24, R20, R20
+
+	// f = hash ^ hash >> 32
+	EOR R10>>32, R10, R21
+	EOR R11>>32, R11, R22
+
+	MOVBU (R3)(R12), R12
+	MOVBU (R3)(R13), R13
+	MOVBU (R3)(R14), R14
+	MOVBU (R3)(R15), R15
+	MOVBU (R3)(R19), R19
+	MOVBU (R3)(R20), R20
+	EOR   R12, R21, R21
+	EOR   R13, R22, R22
+	EOR   R14, R21, R21
+	EOR   R15, R22, R22
+	EOR   R19, R21, R21
+	EOR   R20, R22, R22
+
+	// out = f & 0xff == 0
+	TST  $0xff, R21
+	CSET EQ, R21
+	TST  $0xff, R22
+	CSET EQ, R22
+	MOVB R21, (R2)
+	MOVB R22, 1(R2)
+	ADD  $2, R2, R2
+
+	SUBS $1, R1, R1
+	BNE  loop
+
+done:
+	RET
+
+// func prefetchFingerprints(fingerprints unsafe.Pointer, indices []uint32)
+TEXT ·prefetchFingerprints(SB), NOSPLIT, $0-32
+	MOVD fingerprints+0(FP), R0
+	MOVD indices_base+8(FP), R1
+	MOVD indices_len+16(FP), R2
+	CBZ  R2, prefetched
+
+prefetch:
+	MOVWU.P 4(R1), R3
+	ADD     R3, R0, R4
+	PRFM    (R4), PLDL1KEEP
+	SUBS    $1, R2, R2
+	BNE     prefetch
+
+prefetched:
+	RET
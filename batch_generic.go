@@ -0,0 +1,25 @@
+//go:build (!amd64 && !arm64) || purego || tinygo
+// +build !amd64,!arm64 purego tinygo
+
+package xorfilter
+
+import "unsafe"
+
+// batchKernel is the kernel of ContainsBatch: there is none but Go.
+var batchKernel = kernelGeneric
+
+// containsBatchKernel queries a prefix of keys with the vector kernel of the
+// CPU, if any, and returns its length.
+func (filter *BinaryFuse8) containsBatchKernel(keys []uint64, out []bool) int {
+	return 0
+}
+
+// mixsplitKernel mixes a prefix of keys into dst with the vector kernel of
+// the CPU, if any, and returns its length.
+func mixsplitKernel(dst, keys []uint64, seed uint64) int {
+	return 0
+}
+
+// prefetchFingerprints prefetches the fingerprints at indices of the array at
+// fingerprints.
+func prefetchFingerprints(fingerprints unsafe.Pointer, indices []uint32) {}
@@ -0,0 +1,197 @@
+package xorfilter
+
+import (
+	"context"
+	"math/bits"
+	"unsafe"
+)
+
+// BinaryFuse8Big is a filter for sets with more keys than a BinaryFuse8 filter
+// can hold. The keys are split by their hash among BinaryFuse8 shards of at
+// most MaxBinaryFuse8Keys keys each, and a key is looked up in its shard only,
+// so queries cost about the same as with a BinaryFuse8 filter.
+type BinaryFuse8Big struct {
+	// Seed is the seed of the hash that assigns the keys to the shards.
+	Seed   uint64
+	Shards []BinaryFuse8
+}
+
+// bigShardKeys is the target number of keys per shard. It leaves room for the
+// keys of a shard to exceed the average without going over MaxBinaryFuse8Keys.
+const bigShardKeys = 1 << 31
+
+// PopulateBinaryFuse8Big fills a BinaryFuse8Big filter with provided keys.
+// The shards are built one at a time, each from a pass over keys that picks
+// the keys of the shard, so that the construction only needs the temporary
+// arrays of one shard. The options apply to the construction of each shard.
+func PopulateBinaryFuse8Big(keys []uint64, opts ...Option) (*BinaryFuse8Big, error) {
+	return populateBinaryFuse8Big(context.Background(), &sliceSource{keys: keys}, uint64(len(keys)), bigShardKeys, newBuildConfig(opts))
+}
+
+func populateBinaryFuse8Big(ctx context.Context, src keySource, n uint64, shardKeys uint64, cfg *buildConfig) (*BinaryFuse8Big, error) {
+	rngcounter := cfg.rngCounter
+	filter := &BinaryFuse8Big{Seed: splitmix64(&rngcounter)}
+	shards := (n + shardKeys - 1) / shardKeys
+	if shards == 0 {
+		shards = 1
+	}
+	filter.Shards = make([]BinaryFuse8, shards)
+
+	// Count the keys of each shard.
+	counts := make([]uint64, shards)
+	if err := src.rewind(); err != nil {
+		return nil, err
+	}
+	for {
+		chunk, err := src.next()
+		if err != nil {
+			return nil, err
+		}
+		if len(chunk) == 0 {
+			break
+		}
+		for _, key := range chunk {
+			counts[filter.shard(key)]++
+		}
+	}
+
+	if cfg.zeroize {
+		defer wipeSource(src)
+	}
+	var p Populator
+	for i := range filter.Shards {
+		if counts[i] > MaxBinaryFuse8Keys {
+			return nil, ErrTooManyKeys
+		}
+		shard, err := p.populateBinaryFuse8(ctx, int(counts[i]), &shardSource{src: src, filter: filter, shard: uint64(i)}, cfg)
+		if err != nil {
+			return nil, err
+		}
+		filter.Shards[i] = *shard
+	}
+	return filter, nil
+}
+
+// shard returns the index of the shard of key. The hash differs from the one
+// of the shards, so that the keys of a shard are not clustered within it.
+func (filter *BinaryFuse8Big) shard(key uint64) uint64 {
+	hi, _ := bits.Mul64(wymix(key^wyp0, filter.Seed^wyp1), uint64(len(filter.Shards)))
+	return hi
+}
+
+// Contains returns `true` if key is part of the set with a false positive probability of <0.4%.
+func (filter *BinaryFuse8Big) Contains(key uint64) bool {
+	if filter == nil || len(filter.Shards) == 0 {
+		return false
+	}
+	return filter.Shards[filter.shard(key)].Contains(key)
+}
+
+// shardSource returns the keys of src that belong to one shard of a
+// BinaryFuse8Big filter.
+type shardSource struct {
+	src    keySource
+	filter *BinaryFuse8Big
+	shard  uint64
+	chunk  []uint64
+}
+
+func (s *shardSource) rewind() error {
+	return s.src.rewind()
+}
+
+func (s *shardSource) next() ([]uint64, error) {
+	for {
+		keys, err := s.src.next()
+		if err != nil || len(keys) == 0 {
+			return nil, err
+		}
+		s.chunk = s.chunk[:0]
+		for _, key := range keys {
+			if s.filter.shard(key) == s.shard {
+				s.chunk = append(s.chunk, key)
+			}
+		}
+		if len(s.chunk) > 0 {
+			return s.chunk, nil
+		}
+	}
+}
+
+// wipe zeroes the keys of the shard; those of src are wiped by the
+// construction of the whole filter, which reads them once per shard.
+func (s *shardSource) wipe() {
+	wipeKeys(s.chunk)
+}
+
+// SizeInBytes returns the size of the exported fields of the filter, which
+// is what needs to be serialized.
+func (filter *BinaryFuse8Big) SizeInBytes() uint64 {
+	if filter == nil {
+		return 0
+	}
+	size := uint64(8 + 4) // the seed and the number of shards
+	for i := range filter.Shards {
+		size += filter.Shards[i].SizeInBytes()
+	}
+	return size
+}
+
+// MemoryBytes returns the memory held by the filter.
+func (filter *BinaryFuse8Big) MemoryBytes() uint64 {
+	if filter == nil {
+		return 0
+	}
+	size := uint64(unsafe.Sizeof(*filter))
+	for i := range filter.Shards {
+		size += filter.Shards[i].MemoryBytes()
+	}
+	return size + uint64(cap(filter.Shards)-len(filter.Shards))*uint64(unsafe.Sizeof(BinaryFuse8{}))
+}
+
+// BitsPerEntry returns the size of the fingerprints, in bits per key, for a
+// filter built from n distinct keys.
+func (filter *BinaryFuse8Big) BitsPerEntry(n int) float64 {
+	if filter == nil {
+		return 0
+	}
+	fingerprints := 0
+	for i := range filter.Shards {
+		fingerprints += len(filter.Shards[i].Fingerprints)
+	}
+	return float64(8*fingerprints) / float64(n)
+}
+
+// FalsePositiveRate returns the theoretical probability that Contains returns
+// true for a key that is not in the set: one in 2^8 with 8-bit fingerprints.
+func (filter *BinaryFuse8Big) FalsePositiveRate() float64 {
+	return 1.0 / 256
+}
+
+// Clone returns a copy of the filter that does not share its fingerprints.
+func (filter *BinaryFuse8Big) Clone() *BinaryFuse8Big {
+	if filter == nil {
+		return nil
+	}
+	clone := &BinaryFuse8Big{Seed: filter.Seed, Shards: make([]BinaryFuse8, len(filter.Shards))}
+	for i := range filter.Shards {
+		clone.Shards[i] = *filter.Shards[i].Clone()
+	}
+	return clone
+}
+
+// Equal reports whether the filters have the same seed and shards.
+func (filter *BinaryFuse8Big) Equal(other *BinaryFuse8Big) bool {
+	if filter == nil || other == nil {
+		return filter == other
+	}
+	if filter.Seed != other.Seed || len(filter.Shards) != len(other.Shards) {
+		return false
+	}
+	for i := range filter.Shards {
+		if !filter.Shards[i].Equal(&other.Shards[i]) {
+			return false
+		}
+	}
+	return true
+}
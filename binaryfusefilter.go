@@ -1,11 +1,22 @@
 package xorfilter
 
 import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/binary"
 	"errors"
+	"io"
 	"math"
 	"math/bits"
+	"sort"
+	"time"
+	"unsafe"
 )
 
+// BinaryFuse8 is a binary fuse filter with 8-bit fingerprints. Once built or
+// decoded, it is safe for concurrent queries, as long as its fields are not
+// modified; Freeze returns a copy that cannot be.
 type BinaryFuse8 struct {
 	Seed               uint64
 	SegmentLength      uint32
@@ -14,44 +25,132 @@ type BinaryFuse8 struct {
 	SegmentCountLength uint32
 
 	Fingerprints []uint8
+
+	hasher        Hasher
+	fingerprinter Fingerprinter
+	mapping       *mapping
+	version       AlgorithmVersion
+	salt          uint64
+	salted        bool
+}
+
+// segmentLengthThresholds holds the smallest number of keys of each segment
+// length, from 8 upwards, of the filters of arity 3. They are those of the
+// formula 1 << floor(ln(size)/ln(3.33) + 2.25) of the reference
+// implementation, tabulated so that the parameters of a filter do not depend
+// on floating-point arithmetic. These parameters are very sensitive:
+// replacing 'floor' by 'round' can substantially affect the construction
+// time.
+var segmentLengthThresholds = [...]uint32{
+	3, 9, 28, 92, 304, 1010, 3362, 11193, 37273, 124118, 413310, 1376322,
+	4583150, 15261887, 50822082, 169237530, 563560975, 1876658045,
 }
 
-func calculateSegmentLength(arity uint32, size uint32) uint32 {
-	// These parameters are very sensitive. Replacing 'floor' by 'round' can
-    // substantially affect the construction time.
-	if size == 0 {
-		return 4
+func calculateSegmentLength(size uint32) uint32 {
+	length := uint32(4)
+	for _, threshold := range segmentLengthThresholds {
+		if size < threshold {
+			break
+		}
+		length <<= 1
 	}
-	if arity == 3 {
-		return uint32(1) << int(math.Floor(math.Log(float64(size)) / math.Log(3.33) + 2.25))
-	} else if arity == 4 {
-		return uint32(1) << int(math.Floor(math.Log(float64(size)) / math.Log(2.91) - 0.5))
-	} else {
-		return 65536
+	return length
+}
+
+// sizeFactorConstant is 0.25*log2(1000000) with 58 fractional bits.
+const sizeFactorConstant = 0x13ee7b471b3a9508
+
+// calculateCapacity returns the number of slots for size keys, size >= 2,
+// which is round(size * max(1.125, 0.875 + 0.25*ln(1000000)/ln(size))) in
+// the reference implementation. It is computed in fixed point, with the
+// same result for every size.
+func calculateCapacity(size uint32) uint32 {
+	if size >= 1000000 {
+		return uint32((9*uint64(size) + 4) >> 3)
 	}
+	// x = 0.875*size + size*sizeFactorConstant/log2(size), with 32
+	// fractional bits. log2Fixed rounds down, so that the exact tie of
+	// 162.5 slots for 100 keys rounds up, like math.Round.
+	hi, lo := bits.Mul64(uint64(size), sizeFactorConstant)
+	hi, lo = hi<<32|lo>>32, lo<<32
+	q, _ := bits.Div64(hi, lo, log2Fixed(size))
+	x := 7*uint64(size)<<29 + q
+	return uint32((x + 1<<31) >> 32)
 }
 
-func calculateSizeFactor(arity uint32, size uint32) float64 {
-	if arity == 3 {
-		return math.Max(1.125, 0.875 + 0.25 * math.Log(1000000)/math.Log(float64(size)))
-	} else if arity == 4 {
-		return math.Max(1.075, 0.77 + 0.305 * math.Log(600000)/math.Log(float64(size)))
-	} else {
-		return 2.0
+// log2Fixed returns log2(x), x >= 2, with 58 fractional bits, rounded down.
+// The fractional bits are those of the mantissa squared repeatedly.
+func log2Fixed(x uint32) uint64 {
+	n := uint(bits.Len32(x) - 1)
+	result := uint64(n) << 58
+	y := uint64(x) << (62 - n) // x / 2^n in [1, 2), with 62 fractional bits
+	for bit := uint64(1) << 57; bit != 0; bit >>= 1 {
+		hi, lo := bits.Mul64(y, y)
+		y = hi<<2 | lo>>62
+		if y >= 1<<63 {
+			y >>= 1
+			result |= bit
+		}
 	}
+	return result
 }
 
-func (filter *BinaryFuse8) initializeParameters(size uint32) {
+// tinySize is the number of keys under which a BinaryFuse8 filter is a single
+// segment of a fixed length, rather than sized with the formulas above, which
+// are fitted to large sets and degenerate for a handful of keys.
+const tinySize = 8
+
+// initializeParameters sets the segment fields of the filter for size keys
+// and allocates its fingerprints. It returns ErrTooManyKeys if the arrays of
+// the construction do not fit in the address space, as on 32-bit platforms
+// for the largest sets, rather than overflow their int lengths.
+func (filter *BinaryFuse8) initializeParameters(size uint32, cfg *buildConfig) error {
+	capacity := filter.sizeParameters(size, cfg)
+	scratch := binaryFuseScratchBytes(size, capacity, blockBitsFor(filter.SegmentCount, capacity, cfg), cfg.lowMemory)
+	if uint64(capacity)+scratch > maxInt {
+		return ErrTooManyKeys
+	}
+	n := int(capacity)
+	if cfg.hugePages {
+		if fingerprints, m := mapHugePages(n); fingerprints != nil {
+			filter.Fingerprints, filter.mapping = fingerprints, m
+			return nil
+		}
+	}
+	filter.Fingerprints = makeFingerprints(n, cfg.fingerprintAlignment())
+	return nil
+}
+
+// maxInt is the largest int, the largest length of a slice.
+const maxInt = uint64(^uint(0) >> 1)
+
+// sizeParameters sets the segment fields of the filter for size keys and
+// returns the length of the fingerprint array.
+func (filter *BinaryFuse8) sizeParameters(size uint32, cfg *buildConfig) uint32 {
 	arity := uint32(3)
-	filter.SegmentLength = calculateSegmentLength(arity, size)
-	if filter.SegmentLength > 262144 {
-		filter.SegmentLength = 262144
+	if size < tinySize {
+		// A single segment, with 3 slots per key at least: the peeling
+		// almost always succeeds at the first try.
+		filter.SegmentLength = 4
+		if size > 2 {
+			filter.SegmentLength = 8
+		}
+		filter.SegmentLengthMask = filter.SegmentLength - 1
+		filter.SegmentCount = 1
+		filter.SegmentCountLength = filter.SegmentLength
+		return arity * filter.SegmentLength
+	}
+	filter.SegmentLength = calculateSegmentLength(size)
+	if filter.SegmentLength > maxSegmentLength {
+		filter.SegmentLength = maxSegmentLength
+	}
+	if cfg.segmentLength > 0 {
+		filter.SegmentLength = cfg.segmentLength
 	}
 	filter.SegmentLengthMask = filter.SegmentLength - 1
-	sizeFactor := calculateSizeFactor(arity, size)
-	capacity := uint32(0)
-	if size > 1 {
-		capacity = uint32(math.Round(float64(size) * sizeFactor))
+	capacity := calculateCapacity(size)
+	if cfg.sizeFactor > 0 {
+		capacity = uint32(math.Round(float64(size) * cfg.sizeFactor))
 	}
 	initSegmentCount := (capacity+filter.SegmentLength-1)/filter.SegmentLength - (arity - 1)
 	arrayLength := (initSegmentCount + arity - 1) * filter.SegmentLength
@@ -63,7 +162,35 @@ func (filter *BinaryFuse8) initializeParameters(size uint32) {
 	}
 	arrayLength = (filter.SegmentCount + arity - 1) * filter.SegmentLength
 	filter.SegmentCountLength = filter.SegmentCount * filter.SegmentLength
-	filter.Fingerprints = make([]uint8, arrayLength)
+	return arrayLength
+}
+
+// maxBlockBits is the largest number of bits of the blocks of WithBlockBits.
+const maxBlockBits = 20
+
+// blockBitsFor returns the number of bits of the blocks into which the hashes
+// are binned during the construction, for capacity slots in segmentCount
+// segments. There is at most one block per segment, and the blocks are made
+// coarser as long as the counts and xors of the slots of a block fit in about
+// twice the L2 cache: the finer the blocks, the slower the binning. If the
+// counts and xors of all the slots fit, it returns 0: the hashes are then
+// stored in the order of the keys, without binning, which only pays off once
+// the construction arrays outgrow the caches.
+func blockBitsFor(segmentCount uint32, capacity uint32, cfg *buildConfig) int {
+	if cfg.blockBits != 0 {
+		return cfg.blockBits
+	}
+	if 9*uint64(capacity) <= 2*l2CacheSize() {
+		return 0
+	}
+	blockBits := 1
+	for (1 << blockBits) < segmentCount {
+		blockBits += 1
+	}
+	for blockBits > 1 && 9*uint64(capacity)>>uint(blockBits-1) <= 2*l2CacheSize() {
+		blockBits -= 1
+	}
+	return blockBits
 }
 
 func (filter *BinaryFuse8) getHashFromHash(hash uint64) (uint32, uint32, uint32) {
@@ -76,6 +203,93 @@ func (filter *BinaryFuse8) getHashFromHash(hash uint64) (uint32, uint32, uint32)
 	return h0, h1, h2
 }
 
+// pruneDuplicates sorts the keys in place and removes the duplicates.
+func pruneDuplicates(keys []uint64) []uint64 {
+	if len(keys) == 0 {
+		return keys
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	pos := 0
+	for i := 1; i < len(keys); i++ {
+		if keys[i] != keys[pos] {
+			pos++
+			keys[pos] = keys[i]
+		}
+	}
+	return keys[:pos+1]
+}
+
+// checkDuplicates returns ErrDuplicateKeys if src has duplicate keys. It sorts
+// a copy of the keys, which it zeroes afterwards if wipe is true.
+func checkDuplicates(src keySource, wipe bool) error {
+	keys, err := collectKeys(src, wipe)
+	if err != nil {
+		return err
+	}
+	if wipe {
+		defer wipeKeys(keys)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	for i := 1; i < len(keys); i++ {
+		if keys[i] == keys[i-1] {
+			return ErrDuplicateKeys
+		}
+	}
+	return nil
+}
+
+// binaryFuseScratchBytes returns the number of bytes of the temporary arrays
+// used to construct a filter with the given number of keys and slots.
+func binaryFuseScratchBytes(size, capacity uint32, blockBits int, lowMemory bool) uint64 {
+	scratch := 4*uint64(capacity) + // alone
+		uint64(capacity) + // t2count
+		8*uint64(capacity) // t2hash
+	if lowMemory {
+		return scratch + 8*lowMemoryChunkSize // reverseOrder
+	}
+	return scratch +
+		8*uint64(size+1) + // reverseOrder
+		8*(uint64(1)<<uint(blockBits)) // startPos
+}
+
+// lowMemoryChunkSize is the number of keys hashed at a time by the
+// constructions with the WithLowMemory option.
+const lowMemoryChunkSize = 4096
+
+// MemoryEstimate is the memory needed by a filter.
+type MemoryEstimate struct {
+	// FilterBytes is the size of the fingerprints of the filter.
+	FilterBytes uint64
+	// ScratchBytes is the peak size of the temporary arrays of the
+	// construction, on top of the keys and of the filter.
+	ScratchBytes uint64
+}
+
+// EstimateBinaryFuse8Memory returns the memory needed to build and to hold a
+// filter of n distinct keys, without building it. The options that change the
+// size of the filter, such as WithSizeFactor, are taken into account. For more
+// than MaxBinaryFuse8Keys keys, the estimate is that of a BinaryFuse8Big filter.
+func EstimateBinaryFuse8Memory(n uint64, opts ...Option) MemoryEstimate {
+	shards := uint64(1)
+	if n > MaxBinaryFuse8Keys {
+		shards = (n + bigShardKeys - 1) / bigShardKeys
+	}
+	size := uint32((n + shards - 1) / shards)
+	var filter BinaryFuse8
+	cfg := newBuildConfig(opts)
+	capacity := filter.sizeParameters(size, cfg)
+	estimate := MemoryEstimate{
+		FilterBytes:  shards * uint64(capacity),
+		ScratchBytes: binaryFuseScratchBytes(size, capacity, blockBitsFor(filter.SegmentCount, capacity, cfg), cfg.lowMemory),
+	}
+	if peelRangeCount(&filter, cfg) > 0 {
+		estimate.ScratchBytes += 4 * uint64(capacity) // the peeled slots of the ranges
+	}
+	if shards > 1 {
+		estimate.ScratchBytes += 8 * shards // the key counts of the shards
+	}
+	return estimate
+}
 
 func mod3(x uint8) uint8 {
 	if x > 2 {
@@ -86,99 +300,336 @@ func mod3(x uint8) uint8 {
 
 // PopulateBinaryFuse8 fills a BinaryFuse8 filter with provided keys.
 // The function may return an error after too many iterations: it is unlikely.
-func PopulateBinaryFuse8(keys []uint64) (*BinaryFuse8, error) {
-	size := uint32(len(keys))
-	filter := &BinaryFuse8{}
-	filter.initializeParameters(size)
-	rngcounter := uint64(1)
+// The error is then a *ConstructionError that describes the attempts.
+func PopulateBinaryFuse8(keys []uint64, opts ...Option) (*BinaryFuse8, error) {
+	p := getPopulator(len(keys))
+	defer putPopulator(len(keys), p)
+	return p.PopulateBinaryFuse8(keys, opts...)
+}
+
+// PopulateBinaryFuse8Ctx is like PopulateBinaryFuse8, but it checks ctx between
+// the passes of the construction and gives up promptly with ctx.Err() once ctx
+// is done.
+func PopulateBinaryFuse8Ctx(ctx context.Context, keys []uint64, opts ...Option) (*BinaryFuse8, error) {
+	p := getPopulator(len(keys))
+	defer putPopulator(len(keys), p)
+	return p.PopulateBinaryFuse8Ctx(ctx, keys, opts...)
+}
+
+// PopulateBinaryFuse8FromReader fills a BinaryFuse8 filter with n keys read from r,
+// each encoded as a little-endian uint64. The keys are read and hashed in chunks,
+// so they never need to be held in memory as a slice.
+// If the construction has to be retried and r implements io.Seeker, r is rewound
+// to its initial position and the keys are read again; otherwise the keys are
+// spilled to a temporary file, in the default directory for temporary files,
+// during the first pass and read back from it. The file is removed once the
+// construction returns.
+func PopulateBinaryFuse8FromReader(r io.Reader, n int, opts ...Option) (*BinaryFuse8, error) {
+	p := getPopulator(n)
+	defer putPopulator(n, p)
+	return p.PopulateBinaryFuse8FromReader(r, n, opts...)
+}
+
+// PopulateBinaryFuse8FromFunc fills a BinaryFuse8 filter with the n keys that
+// the keys function emits, a chunk at a time, on each of its calls. The
+// function is called once per pass of the construction over the keys, at
+// least once and again on each retry, and must emit the same keys every
+// time; it should stop and return the error of emit, if any. A chunk is
+// consumed before emit returns, so that the function can reuse it. With
+// WithLowMemory, the construction then never holds more than a chunk of keys,
+// unless it finds duplicate keys, which it removes from a copy of the keys.
+func PopulateBinaryFuse8FromFunc(n int, keys func(emit func(keys []uint64) error) error, opts ...Option) (*BinaryFuse8, error) {
+	p := getPopulator(n)
+	defer putPopulator(n, p)
+	return p.PopulateBinaryFuse8FromFunc(n, keys, opts...)
+}
+
+// PopulateBinaryFuse8FromUint32 fills a BinaryFuse8 filter with 32-bit keys.
+// Each key is widened to 64 bits, so that ContainsUint32(k) is the same as
+// Contains(uint64(k)), without the need to convert the keys beforehand.
+func PopulateBinaryFuse8FromUint32(keys []uint32, opts ...Option) (*BinaryFuse8, error) {
+	p := getPopulator(len(keys))
+	defer putPopulator(len(keys), p)
+	return p.PopulateBinaryFuse8FromUint32(keys, opts...)
+}
+
+// PopulateBinaryFuse8FromStrings fills a BinaryFuse8 filter with string keys.
+// Each string is hashed to a 64-bit key with wyhash (as found in the Go
+// runtime) under a fixed seed, and that key is then mixed with the seed of
+// the filter like any other. Strings with equal bytes are the same key, so
+// ContainsString works on a filter restored from its serialized fields.
+func PopulateBinaryFuse8FromStrings(keys []string, opts ...Option) (*BinaryFuse8, error) {
+	p := getPopulator(len(keys))
+	defer putPopulator(len(keys), p)
+	return p.PopulateBinaryFuse8FromStrings(keys, opts...)
+}
+
+// PopulateBinaryFuse8FromBytes fills a BinaryFuse8 filter with binary keys,
+// such as digests or UUIDs. The keys are hashed like the strings of
+// PopulateBinaryFuse8FromStrings: a byte slice and a string with the same
+// bytes are the same key.
+func PopulateBinaryFuse8FromBytes(keys [][]byte, opts ...Option) (*BinaryFuse8, error) {
+	p := getPopulator(len(keys))
+	defer putPopulator(len(keys), p)
+	return p.PopulateBinaryFuse8FromBytes(keys, opts...)
+}
+
+// PopulateBinaryFuse8From128 fills a BinaryFuse8 filter with 128-bit keys,
+// such as UUIDs or truncated digests. All 16 bytes of a key are hashed, like
+// the keys of PopulateBinaryFuse8FromBytes, so that Contains128(k),
+// ContainsBytes(k[:]) and ContainsUint128 of the two big-endian halves of k
+// agree.
+func PopulateBinaryFuse8From128(keys [][16]byte, opts ...Option) (*BinaryFuse8, error) {
+	p := getPopulator(len(keys))
+	defer putPopulator(len(keys), p)
+	return p.PopulateBinaryFuse8From128(keys, opts...)
+}
+
+// PopulateBinaryFuse8FromUUIDs fills a BinaryFuse8 filter with UUIDs, for
+// ContainsUUID. It is PopulateBinaryFuse8From128: the 16 bytes of a UUID are
+// hashed to a 64-bit key as a whole, where folding its halves with a xor
+// would give the same key to the UUIDs whose halves are swapped, and to all
+// those whose halves are equal.
+func PopulateBinaryFuse8FromUUIDs(uuids [][16]byte, opts ...Option) (*BinaryFuse8, error) {
+	return PopulateBinaryFuse8From128(uuids, opts...)
+}
+
+// PopulateBinaryFuse8Hashed fills a BinaryFuse8 filter with keys that are
+// already good 64-bit hashes. Instead of the full mixing function, a hash is
+// only xored with the seed of the filter and put through one xor-shift-multiply
+// round, so that the construction can still retry with other seeds. The filter
+// is queried with ContainsHashed; the WithHasher option is ignored.
+func PopulateBinaryFuse8Hashed(hashes []uint64, opts ...Option) (*BinaryFuse8, error) {
+	p := getPopulator(len(hashes))
+	defer putPopulator(len(hashes), p)
+	return p.PopulateBinaryFuse8Hashed(hashes, opts...)
+}
+
+// MaxBinaryFuse8Keys is the largest number of keys of a BinaryFuse8 filter,
+// whose sizes are 32-bit. Larger sets go into a BinaryFuse8Big filter.
+const MaxBinaryFuse8Keys = 3 << 30
+
+// ErrDuplicateKeys is returned when the keys given with the
+// WithDuplicateCheck option are not distinct.
+var ErrDuplicateKeys = errors.New("duplicate keys")
+
+// ErrMissingKey is returned when the verification of the WithVerifyKeys
+// option finds a key that the new filter does not contain.
+var ErrMissingKey = errors.New("the filter does not contain one of its keys")
+
+// ErrNotSortedUnique is returned when the keys given with the
+// WithSortedUniqueInput option are not sorted in increasing order.
+var ErrNotSortedUnique = errors.New("keys are not sorted and unique")
+
+// ErrTooManyKeys is returned when a set has more keys than a filter can hold.
+var ErrTooManyKeys = errors.New("too many keys for a single filter")
+
+// populateBinaryFuse8 builds a filter of the n keys of src. An attempt whose
+// peeling stalls is retried in full, with the next seed, even if it peeled
+// all but a few keys. The stalled keys cannot be retried alone, with a salt
+// or seeds of their own: Contains derives the slots of every key from the
+// single seed of the filter, and the slots of the unpeeled keys overlap those
+// of the peeled ones, so moving any key elsewhere would take a second seed in
+// the format of the filter and a second probe in Contains. The retries are
+// rare enough, at the sizes the filter is tuned for, not to be worth it.
+func (p *Populator) populateBinaryFuse8(ctx context.Context, n int, src keySource, cfg *buildConfig) (*BinaryFuse8, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if uint64(n) > MaxBinaryFuse8Keys {
+		return nil, ErrTooManyKeys
+	}
+	size := uint32(n)
+	if cfg.sizeFactor != 0 {
+		if cfg.sizeFactor < 1 {
+			return nil, errors.New("the size factor must be at least 1")
+		}
+		if float64(size)*cfg.sizeFactor > math.MaxUint32-4*maxSegmentLength {
+			return nil, ErrTooManyKeys
+		}
+	}
+	if cfg.segmentLength != 0 {
+		if cfg.segmentLength&(cfg.segmentLength-1) != 0 || cfg.segmentLength > maxSegmentLength {
+			return nil, errors.New("the segment length must be a power of two, at most 262144")
+		}
+	}
+	if cfg.blockBits < 0 || cfg.blockBits > maxBlockBits {
+		return nil, errors.New("the block bits must be between 1 and 20")
+	}
+	if cfg.alignment < 0 || cfg.alignment&(cfg.alignment-1) != 0 {
+		return nil, errors.New("the alignment must be a power of two")
+	}
+	if cfg.version != AlgorithmV1 && cfg.version != AlgorithmV2 {
+		return nil, errUnknownVersion
+	}
+	if cfg.zeroize {
+		defer wipeSource(src)
+	}
+	if cfg.duplicateCheck {
+		if err := checkDuplicates(src, cfg.zeroize); err != nil {
+			return nil, err
+		}
+	}
+	start := time.Now()
+	filter := &BinaryFuse8{hasher: cfg.hasher, fingerprinter: cfg.fingerprinter, version: cfg.version, salt: cfg.salt, salted: cfg.salted}
+	if err := filter.initializeParameters(size, cfg); err != nil {
+		return nil, err
+	}
+	rngcounter := cfg.rngCounter
 	filter.Seed = splitmix64(&rngcounter)
+	if cfg.seeded {
+		filter.Seed = cfg.seed
+		rngcounter = cfg.rngCounter
+	}
 	capacity := uint32(len(filter.Fingerprints))
 
-	alone := make([]uint32, capacity)
+	blockBits := blockBitsFor(filter.SegmentCount, capacity, cfg)
+	rangeCount := peelRangeCount(filter, cfg)
+	workers := workersFor(cfg.parallelism, int(size))
+	scratch := binaryFuseScratchBytes(size, capacity, blockBits, cfg.lowMemory)
+	if rangeCount > 0 {
+		scratch += 4 * uint64(capacity)
+	}
+	if cfg.maxScratch > 0 && scratch > cfg.maxScratch {
+		// Peel sequentially, but with AlgorithmV2, whose filter would
+		// change, and then hash the keys a chunk at a time, until the
+		// arrays fit.
+		if rangeCount > 0 && cfg.version < AlgorithmV2 {
+			rangeCount = 0
+			scratch -= 4 * uint64(capacity)
+		}
+		if scratch > cfg.maxScratch && !cfg.lowMemory {
+			lowMemory := *cfg
+			lowMemory.lowMemory = true
+			cfg = &lowMemory
+			scratch = binaryFuseScratchBytes(size, capacity, blockBits, true)
+		}
+		if scratch > cfg.maxScratch {
+			return nil, ErrScratchTooSmall
+		}
+	}
+	if cfg.scratch != nil {
+		// The arrays of p are set aside rather than released, and p does
+		// not retain buf once the construction is over.
+		saved := *p
+		defer func() { *p = saved }()
+		if err := p.carve(cfg.scratch, size, capacity, blockBits, cfg.lowMemory, rangeCount > 0); err != nil {
+			return nil, err
+		}
+	} else {
+		p.reserve(size, capacity, blockBits, cfg.lowMemory)
+	}
+	if cfg.zeroize {
+		// Before the arrays carved out of the scratch buffer are set
+		// aside.
+		defer p.wipe()
+	}
+	ranges := p.reserveRanges(filter, rangeCount)
+
+	// alone holds the queue of the slots with one key from its start, and
+	// the stack of the slots where the keys were peeled from its end: a slot
+	// enters the queue at most once, so they do not overlap.
+	alone := p.alone
 	// the lowest 2 bits are the h index (0, 1, or 2)
 	// so we only have 6 bits for counting;
 	// but that's sufficient
-	t2count := make([]uint8, capacity)
-	reverseH := make([]uint8, size)
+	t2count := p.t2count
 
-	t2hash := make([]uint64, capacity)
-	reverseOrder := make([]uint64, size+1)
-	reverseOrder[size] = 1
+	t2hash := p.t2hash
+	reverseOrder := p.reverseOrder
+	if !cfg.lowMemory {
+		reverseOrder[size] = 1
+	}
 
 	// the array h0, h1, h2, h0, h1, h2
 	var h012 [6]uint32
 	// this could be used to compute the mod3
 	// tabmod3 := [5]uint8{0,1,2,0,1}
 	iterations := 0
+	pruned := false
+	failure := &ConstructionError{}
+	if cfg.stats != nil {
+		defer func() {
+			cfg.stats.Iterations = iterations
+			cfg.stats.Seed = filter.Seed
+			cfg.stats.Duration = time.Since(start)
+			cfg.stats.ScratchBytes = scratch
+			cfg.stats.BitsPerEntry = 0
+			if size > 0 {
+				cfg.stats.BitsPerEntry = float64(8*len(filter.Fingerprints)) / float64(size)
+			}
+		}()
+	}
 	for true {
-		iterations += 1
-		if iterations > MaxIterations {
-			return nil, errors.New("too many iterations, you probably have duplicate keys")
-		}
-
-		blockBits := 1
-		for (1 << blockBits) < filter.SegmentCount {
-			blockBits += 1
-		}
-		startPos := make([]uint, 1<<blockBits)
-		for i, _ := range startPos {
-			// important: we do not want i * size to overflow!!!
-			startPos[i] = uint((uint64(i) * uint64(size)) >> blockBits)
+		if err := ctx.Err(); err != nil {
+			return nil, err
 		}
-		for _, key := range keys {
-			hash := mixsplit(key, filter.Seed)
-			segment_index := hash >> (64 - blockBits)
-			for reverseOrder[startPos[segment_index]] != 0 {
-				segment_index++
-				segment_index &= (1 << blockBits) - 1
-			}
-			reverseOrder[startPos[segment_index]] = hash
-			startPos[segment_index] += 1
+		if iterations >= cfg.iterationLimit() || (iterations > 0 && cfg.retryPolicy != nil && !cfg.retryPolicy(iterations)) {
+			failure.Attempts = iterations
+			failure.Keys = size
+			return nil, failure
 		}
-		error := 0
-		duplicates := uint32(0)
+		iterations += 1
+		failure.Seeds = append(failure.Seeds, filter.Seed)
 
-		for i := uint32(0); i < size; i++ {
-			hash := reverseOrder[i]
-			index1, index2, index3 := filter.getHashFromHash(hash)
-			t2count[index1] += 4
-			// t2count[index1] ^= 0 // noop
-			t2hash[index1] ^= hash
-			t2count[index2] += 4
-			t2count[index2] ^= 1
-			t2hash[index2] ^= hash
-			t2count[index3] += 4
-			t2count[index3] ^= 2
-			t2hash[index3] ^= hash
-			// If we have duplicated hash values, then it is likely that
-			// the next comparison is true
-			if t2hash[index1] & t2hash[index2] & t2hash[index3] == 0 {
-				// next we do the actual test
-				if ((t2hash[index1] == 0) && (t2count[index1] == 8)) || ((t2hash[index2] == 0) && (t2count[index2] == 8)) || ((t2hash[index3] == 0) && (t2count[index3] == 8)) {
-					duplicates += 1
-					t2count[index1] -= 4
-					t2hash[index1] ^= hash
-					t2count[index2] -= 4
-					t2count[index2] ^= 1
-					t2hash[index2] ^= hash
-					t2count[index3] -= 4
-					t2count[index3] ^= 2
-					t2hash[index3] ^= hash
-				}
-			}
-			if t2count[index1] < 4 {
-				error = 1
+		duplicates := uint32(0)
+		overflow := false
+		if cfg.lowMemory {
+			// The keys are hashed and added a chunk at a time.
+			if err := src.rewind(); err != nil {
+				return nil, err
 			}
-			if t2count[index2] < 4 {
-				error = 1
+			hashed := uint32(0)
+			prev := uint64(0)
+			for {
+				keys, err := src.next()
+				if err != nil {
+					return nil, err
+				}
+				if len(keys) == 0 {
+					break
+				}
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+				for len(keys) > 0 {
+					chunk := keys
+					if len(chunk) > lowMemoryChunkSize {
+						chunk = chunk[:lowMemoryChunkSize]
+					}
+					keys = keys[len(chunk):]
+					if cfg.sortedUnique {
+						for i, key := range chunk {
+							if hashed+uint32(i) > 0 && key <= prev {
+								return nil, ErrNotSortedUnique
+							}
+							prev = key
+						}
+					}
+					hashes := reverseOrder[:len(chunk)]
+					filter.hashKeys(hashes, chunk)
+					d, o := p.addHashes(filter, hashes, hashed, iterations, size, cfg)
+					duplicates += d
+					overflow = overflow || o
+					hashed += uint32(len(chunk))
+				}
 			}
-			if t2count[index3] < 4 {
-				error = 1
+		} else {
+			if err := p.binHashes(ctx, filter, src, blockBits, size, iterations, cfg); err != nil {
+				return nil, err
 			}
+			duplicates, overflow = p.addHashes(filter, reverseOrder[:size], 0, iterations, size, cfg)
 		}
-		if error == 1 {
-			for i := uint32(0); i < size; i++ {
-				reverseOrder[i] = 0
+		if duplicates > 0 {
+			failure.Duplicates = true
+		}
+		if overflow {
+			// A slot received more keys than t2count can count.
+			failure.Overflow = true
+			if !cfg.lowMemory {
+				for i := uint32(0); i < size; i++ {
+					reverseOrder[i] = 0
+				}
 			}
 			for i := uint32(0); i < capacity; i++ {
 				t2count[i] = 0
@@ -189,6 +640,13 @@ func PopulateBinaryFuse8(keys []uint64) (*BinaryFuse8, error) {
 		}
 
 		// End of key addition
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		// Peel the keys that lie within a range of segments in parallel:
+		// the remaining ones are peeled by the loop below.
+		peeledInRanges := p.peelRanges(filter, ranges, workers)
 
 		Qsize := 0
 		// Add sets with one key to the queue.
@@ -203,11 +661,13 @@ func PopulateBinaryFuse8(keys []uint64) (*BinaryFuse8, error) {
 			Qsize--
 			index := alone[Qsize]
 			if (t2count[index] >> 2) == 1 {
+				if stacksize%progressInterval == 0 {
+					cfg.report(PhasePeeling, iterations, stacksize, size)
+				}
 				hash := t2hash[index]
 				found := t2count[index] & 3
-				reverseH[stacksize] = found
-				reverseOrder[stacksize] = hash
 				stacksize++
+				alone[capacity-stacksize] = index
 
 				index1, index2, index3 := filter.getHashFromHash(hash)
 
@@ -216,9 +676,11 @@ func PopulateBinaryFuse8(keys []uint64) (*BinaryFuse8, error) {
 				h012[3] = index1
 				h012[4] = h012[1]
 
+				// The queue is only written when it grows, so as not to
+				// overwrite the stack.
 				other_index1 := h012[found+1]
-				alone[Qsize] = other_index1
 				if (t2count[other_index1] >> 2) == 2 {
+					alone[Qsize] = other_index1
 					Qsize++
 				}
 				t2count[other_index1] -= 4
@@ -226,8 +688,8 @@ func PopulateBinaryFuse8(keys []uint64) (*BinaryFuse8, error) {
 				t2hash[other_index1] ^= hash
 
 				other_index2 := h012[found+2]
-				alone[Qsize] = other_index2
 				if (t2count[other_index2] >> 2) == 2 {
+					alone[Qsize] = other_index2
 					Qsize++
 				}
 				t2count[other_index2] -= 4
@@ -236,30 +698,77 @@ func PopulateBinaryFuse8(keys []uint64) (*BinaryFuse8, error) {
 			}
 		}
 
-		if stacksize + duplicates == size {
+		if stacksize+peeledInRanges+duplicates == size {
 			// Success
 			size = stacksize
 			break
 		}
-		for i := uint32(0); i < size; i++ {
-			reverseOrder[i] = 0
+		if stacksize+peeledInRanges+duplicates > failure.BestPeeled {
+			failure.BestPeeled = stacksize + peeledInRanges + duplicates
+		}
+		if !cfg.lowMemory {
+			for i := uint32(0); i < size; i++ {
+				reverseOrder[i] = 0
+			}
 		}
 		for i := uint32(0); i < capacity; i++ {
 			t2count[i] = 0
 			t2hash[i] = 0
 		}
+		if duplicates > 0 && !pruned {
+			// Duplicates were found, but we did not manage to remove them
+			// all. We sort a copy of the keys and drop the duplicates: this
+			// runs in time O(n log n) but only happens once.
+			keys, err := collectKeys(src, cfg.zeroize)
+			if err != nil {
+				return nil, err
+			}
+			if cfg.zeroize {
+				defer wipeKeys(keys)
+			}
+			scratch += 8 * uint64(len(keys))
+			keys = pruneDuplicates(keys)
+			src = &sliceSource{keys: keys}
+			if cfg.lowMemory {
+				size = uint32(len(keys))
+			} else {
+				reverseOrder[size] = 0
+				size = uint32(len(keys))
+				reverseOrder[size] = 1
+			}
+			pruned = true
+		} else if !cfg.lowMemory && cfg.scratch == nil && cachedOnRetry(src) &&
+			(cfg.maxScratch == 0 || scratch+8*uint64(size) <= cfg.maxScratch) {
+			// The keys are derived anew at every pass: keep them for
+			// the next attempts, which then only mix them with the seed.
+			keys, err := collectKeys(src, cfg.zeroize)
+			if err != nil {
+				return nil, err
+			}
+			if cfg.zeroize {
+				defer wipeKeys(keys)
+			}
+			scratch += 8 * uint64(len(keys))
+			src = &sliceSource{keys: keys}
+		}
 		filter.Seed = splitmix64(&rngcounter)
 	}
-	if size == 0 {
+	if size == 0 && ranges == nil {
 		return filter, nil
 	}
 
-	for i := int(size - 1); i >= 0; i-- {
+	// The keys are assigned in the reverse order of their peeling: the slot
+	// where a key was peeled still holds its hash and index bits.
+	for i := capacity - size; i < capacity; i++ {
+		if (i-(capacity-size))%progressInterval == 0 {
+			cfg.report(PhaseAssigning, iterations, i-(capacity-size), size)
+		}
+		index := alone[i]
 		// the hash of the key we insert next
-		hash := reverseOrder[i]
-		xor2 := uint8(fingerprint(hash))
+		hash := t2hash[index]
+		xor2 := filter.fingerprintOf(hash)
 		index1, index2, index3 := filter.getHashFromHash(hash)
-		found := reverseH[i]
+		found := t2count[index] & 3
 		h012[0] = index1
 		h012[1] = index2
 		h012[2] = index3
@@ -267,16 +776,362 @@ func PopulateBinaryFuse8(keys []uint64) (*BinaryFuse8, error) {
 		h012[4] = h012[1]
 		filter.Fingerprints[h012[found]] = xor2 ^ filter.Fingerprints[h012[found+1]] ^ filter.Fingerprints[h012[found+2]]
 	}
+	// The keys peeled within the ranges were peeled first, so they are
+	// assigned last.
+	p.assignRanges(filter, ranges, workers)
+	cfg.report(PhaseAssigning, iterations, size, size)
 
+	if cfg.verifyKeys {
+		if err := verifyKeys(filter, src); err != nil {
+			return nil, err
+		}
+	}
 	return filter, nil
 }
 
+// binHashes hashes the keys of src into reverseOrder, binned by their top
+// blockBits bits so that the hashes of a bin fall in nearby segments.
+func (p *Populator) binHashes(ctx context.Context, filter *BinaryFuse8, src keySource, blockBits int, size uint32, iterations int, cfg *buildConfig) error {
+	reverseOrder := p.reverseOrder
+	startPos := p.startPos
+	for i, _ := range startPos {
+		// important: we do not want i * size to overflow!!!
+		startPos[i] = uint((uint64(i) * uint64(size)) >> blockBits)
+	}
+	if keys, ok := src.(*sliceSource); ok && !cfg.sortedUnique {
+		if cfg.parallelism > 0 {
+			// Even with a single goroutine, so that the order of the
+			// hashes does not depend on their number.
+			cfg.report(PhaseHashing, iterations, 0, size)
+			binParallel(filter, keys.keys, reverseOrder, blockBits, workersFor(cfg.parallelism, int(size)))
+			return nil
+		}
+	}
+	if err := src.rewind(); err != nil {
+		return err
+	}
+	hashed := uint32(0)
+	prev := uint64(0)
+	var hashes [hashChunkSize]uint64
+	for {
+		keys, err := src.next()
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		cfg.report(PhaseHashing, iterations, hashed, size)
+		if cfg.sortedUnique {
+			for i, key := range keys {
+				if hashed+uint32(i) > 0 && key <= prev {
+					return ErrNotSortedUnique
+				}
+				prev = key
+			}
+		}
+		if cfg.sortedUnique || blockBits == 0 {
+			// The keys are distinct, or the filter fits in the caches:
+			// the hashes are stored in the order of the keys, without
+			// binning them by segment.
+			filter.hashKeys(reverseOrder[hashed:], keys)
+			hashed += uint32(len(keys))
+			continue
+		}
+		hashed += uint32(len(keys))
+		for len(keys) > 0 {
+			chunk := keys
+			if len(chunk) > len(hashes) {
+				chunk = chunk[:len(hashes)]
+			}
+			keys = keys[len(chunk):]
+			filter.hashKeys(hashes[:], chunk)
+			for _, hash := range hashes[:len(chunk)] {
+				segment_index := hash >> (64 - blockBits)
+				for reverseOrder[startPos[segment_index]] != 0 {
+					segment_index++
+					segment_index &= (1 << blockBits) - 1
+				}
+				reverseOrder[startPos[segment_index]] = hash
+				startPos[segment_index] += 1
+			}
+		}
+	}
+}
+
+// hashChunkSize is the number of keys hashed at a time by the binning, in a
+// buffer small enough for the stack and for the L1 cache.
+const hashChunkSize = 256
+
+// addHashes adds the hashes to the counts and xors of the slots, done hashes
+// having been added before. It returns the number of duplicate hashes, which
+// it leaves out, and whether the count of a slot overflowed.
+func (p *Populator) addHashes(filter *BinaryFuse8, hashes []uint64, done uint32, iterations int, size uint32, cfg *buildConfig) (uint32, bool) {
+	t2count := p.t2count
+	t2hash := p.t2hash
+	error := 0
+	duplicates := uint32(0)
+
+	for i, hash := range hashes {
+		if (done+uint32(i))%progressInterval == 0 {
+			cfg.report(PhaseAdding, iterations, done+uint32(i), size)
+		}
+		index1, index2, index3 := filter.getHashFromHash(hash)
+		t2count[index1] += 4
+		// t2count[index1] ^= 0 // noop
+		t2hash[index1] ^= hash
+		t2count[index2] += 4
+		t2count[index2] ^= 1
+		t2hash[index2] ^= hash
+		t2count[index3] += 4
+		t2count[index3] ^= 2
+		t2hash[index3] ^= hash
+		// If we have duplicated hash values, then it is likely that
+		// the next comparison is true
+		if t2hash[index1]&t2hash[index2]&t2hash[index3] == 0 {
+			// next we do the actual test
+			if ((t2hash[index1] == 0) && (t2count[index1] == 8)) || ((t2hash[index2] == 0) && (t2count[index2] == 8)) || ((t2hash[index3] == 0) && (t2count[index3] == 8)) {
+				duplicates += 1
+				t2count[index1] -= 4
+				t2hash[index1] ^= hash
+				t2count[index2] -= 4
+				t2count[index2] ^= 1
+				t2hash[index2] ^= hash
+				t2count[index3] -= 4
+				t2count[index3] ^= 2
+				t2hash[index3] ^= hash
+			}
+		}
+		if t2count[index1] < 4 {
+			error = 1
+		}
+		if t2count[index2] < 4 {
+			error = 1
+		}
+		if t2count[index3] < 4 {
+			error = 1
+		}
+	}
+	return duplicates, error == 1
+}
+
+// verifyKeys returns ErrMissingKey if filter does not contain all the keys of src.
+func verifyKeys(filter *BinaryFuse8, src keySource) error {
+	if err := src.rewind(); err != nil {
+		return err
+	}
+	for {
+		keys, err := src.next()
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+		for _, key := range keys {
+			if !filter.Contains(key) {
+				return ErrMissingKey
+			}
+		}
+	}
+}
 
 // Contains returns `true` if key is part of the set with a false positive probability of <0.4%.
+//
+// Contains inlines the default hash and containsHash, so it makes no call for
+// a filter without a Hasher, a Fingerprinter or a namespace; the other filters
+// take containsSlow, which is kept out of line. The hash and the lookup alone
+// are over the inlining budget of the compiler: Contains is inlined in its
+// callers where it is hot in the profile of a build with profile-guided
+// optimization.
 func (filter *BinaryFuse8) Contains(key uint64) bool {
-	hash := mixsplit(key, filter.Seed)
-	f := uint8(fingerprint(hash))
+	if filter == nil || filter.hasher != nil || filter.fingerprinter != nil || filter.salted {
+		return filter.containsSlow(key)
+	}
+	return filter.containsHash(mixsplit(key, filter.Seed))
+}
+
+// containsSlow is Contains for the nil filter and for the filters with a
+// Hasher, a Fingerprinter or a namespace.
+//
+//go:noinline
+func (filter *BinaryFuse8) containsSlow(key uint64) bool {
+	if filter == nil {
+		return false
+	}
+	hash := filter.hash(key)
+	if filter.fingerprinter != nil {
+		return filter.containsFingerprinted(hash)
+	}
+	return filter.containsHash(hash)
+}
+
+// containsHash returns `true` if the mixed hash of a key is part of the set.
+// It spells getHashFromHash out, to stay within the inlining budget of the
+// compiler.
+func (filter *BinaryFuse8) containsHash(hash uint64) bool {
+	hi, _ := bits.Mul64(hash, uint64(filter.SegmentCountLength))
+	length, mask := filter.SegmentLength, filter.SegmentLengthMask
+	fingerprints := filter.Fingerprints
+	if len(fingerprints) == 0 {
+		// The zero filter, whose indexes are all 0, is empty.
+		return false
+	}
+	h0 := uint32(hi)
+	return uint8(hash^hash>>32)^fingerprints[h0]^fingerprints[(h0+length)^uint32(hash>>18)&mask]^fingerprints[(h0+2*length)^uint32(hash)&mask] == 0
+}
+
+// ContainsUnchecked is like Contains, but reads the fingerprints without
+// bounds checks, which the compiler cannot elide from Contains since they
+// depend on the parameters of the filter. It is only safe on filters whose
+// parameters are consistent, as checked by Validate: the filters built or
+// decoded by this package are, but a filter whose fields were modified
+// must be validated first, or ContainsUnchecked may read outside of its
+// fingerprints.
+func (filter *BinaryFuse8) ContainsUnchecked(key uint64) bool {
+	if filter == nil || len(filter.Fingerprints) == 0 {
+		return false
+	}
+	hash := filter.hash(key)
+	h0, h1, h2 := filter.getHashFromHash(hash)
+	fingerprints := unsafe.Pointer(&filter.Fingerprints[0])
+	return filter.fingerprintOf(hash)^fingerprintAt(fingerprints, h0)^fingerprintAt(fingerprints, h1)^fingerprintAt(fingerprints, h2) == 0
+}
+
+// ContainsConstantTime is like Contains, for the deployments where the time
+// of a query must not tell anything about the key: it reads the three
+// fingerprints of the key, always in the same order, and compares their
+// combination without a branch, so that the instructions executed are the
+// same whatever the key and the answer. The key is hashed by the default
+// mixing function, or by the Hasher of the filter, which must then be
+// constant-time as well. What it cannot hide is which fingerprints are read:
+// their addresses depend on the key, and an attacker who shares the caches
+// of the CPU may observe them. Its keys are the uint64 keys of Contains; those
+// of byte strings are given by HashBytes.
+func (filter *BinaryFuse8) ContainsConstantTime(key uint64) bool {
+	if filter == nil || len(filter.Fingerprints) == 0 {
+		return false
+	}
+	hash := filter.hash(key)
 	h0, h1, h2 := filter.getHashFromHash(hash)
-	f ^= filter.Fingerprints[h0] ^ filter.Fingerprints[h1] ^ filter.Fingerprints[h2]
-	return f == 0
+	x := filter.fingerprintOf(hash) ^ filter.Fingerprints[h0] ^ filter.Fingerprints[h1] ^ filter.Fingerprints[h2]
+	return subtle.ConstantTimeByteEq(x, 0) == 1
+}
+
+// ContainsUint32 returns `true` if the 32-bit key is part of the set, as
+// built by PopulateBinaryFuse8FromUint32.
+func (filter *BinaryFuse8) ContainsUint32(key uint32) bool {
+	return filter.Contains(uint64(key))
+}
+
+// ContainsString returns `true` if the string key is part of the set, as
+// built by PopulateBinaryFuse8FromStrings.
+func (filter *BinaryFuse8) ContainsString(key string) bool {
+	return filter.Contains(hashString(key, stringSeed))
+}
+
+// ContainsBytes returns `true` if the binary key is part of the set, as built
+// by PopulateBinaryFuse8FromBytes. It does not allocate, and key does not
+// escape: a key in a buffer on the stack of the caller stays there. The bytes
+// are hashed in place rather than converted to a string.
+func (filter *BinaryFuse8) ContainsBytes(key []byte) bool {
+	return filter.Contains(hashBytes(key, stringSeed))
+}
+
+// ContainsHashed returns `true` if the hash is part of the set, as built by
+// PopulateBinaryFuse8Hashed.
+func (filter *BinaryFuse8) ContainsHashed(hash uint64) bool {
+	if filter == nil {
+		return false
+	}
+	if filter.salted {
+		hash = filter.saltKey(hash)
+	}
+	hash = mixhashed(hash, filter.Seed)
+	if filter.fingerprinter != nil {
+		return filter.containsFingerprinted(hash)
+	}
+	return filter.containsHash(hash)
+}
+
+// Contains128 returns `true` if the 128-bit key is part of the set, as built
+// by PopulateBinaryFuse8From128.
+func (filter *BinaryFuse8) Contains128(key [16]byte) bool {
+	return filter.Contains(hashUint128(binary.BigEndian.Uint64(key[:8]), binary.BigEndian.Uint64(key[8:]), stringSeed))
+}
+
+// ContainsUUID returns `true` if the UUID is part of the set, as built by
+// PopulateBinaryFuse8FromUUIDs. It is Contains128.
+func (filter *BinaryFuse8) ContainsUUID(uuid [16]byte) bool {
+	return filter.Contains128(uuid)
+}
+
+// ContainsUint128 returns `true` if the 128-bit key made of hi followed by lo
+// is part of the set, as built by PopulateBinaryFuse8From128.
+func (filter *BinaryFuse8) ContainsUint128(hi, lo uint64) bool {
+	return filter.Contains(hashUint128(hi, lo, stringSeed))
+}
+
+// SizeInBytes returns the size of the exported fields of the filter, which
+// is what needs to be serialized.
+func (filter *BinaryFuse8) SizeInBytes() uint64 {
+	if filter == nil {
+		return 0
+	}
+	return 8 + 4*4 + uint64(len(filter.Fingerprints))
+}
+
+// MemoryBytes returns the memory held by the filter.
+func (filter *BinaryFuse8) MemoryBytes() uint64 {
+	if filter == nil {
+		return 0
+	}
+	return uint64(unsafe.Sizeof(*filter)) + uint64(cap(filter.Fingerprints))
+}
+
+// BitsPerEntry returns the size of the fingerprints, in bits per key, for a
+// filter built from n distinct keys.
+func (filter *BinaryFuse8) BitsPerEntry(n int) float64 {
+	if filter == nil {
+		return 0
+	}
+	return float64(8*len(filter.Fingerprints)) / float64(n)
+}
+
+// FalsePositiveRate returns the theoretical probability that Contains returns
+// true for a key that is not in the set: one in 2^8 with 8-bit fingerprints.
+func (filter *BinaryFuse8) FalsePositiveRate() float64 {
+	return 1.0 / 256
+}
+
+// Clone returns a copy of the filter that does not share its fingerprints.
+func (filter *BinaryFuse8) Clone() *BinaryFuse8 {
+	if filter == nil {
+		return nil
+	}
+	clone := *filter
+	clone.Fingerprints = copyFingerprints(filter.Fingerprints)
+	clone.mapping = nil
+	return &clone
+}
+
+// Equal reports whether the filters have the same seed, parameters and
+// fingerprints. The Hasher, Fingerprinter and namespace of the filters are
+// not compared, nor is their AlgorithmVersion: the queries do not depend on
+// it, and it is 0 for a filter restored from its exported fields or saved by
+// a release from before the versions, which is still equal to the filter it
+// was restored from.
+func (filter *BinaryFuse8) Equal(other *BinaryFuse8) bool {
+	if filter == nil || other == nil {
+		return filter == other
+	}
+	return filter.Seed == other.Seed &&
+		filter.SegmentLength == other.SegmentLength &&
+		filter.SegmentLengthMask == other.SegmentLengthMask &&
+		filter.SegmentCount == other.SegmentCount &&
+		filter.SegmentCountLength == other.SegmentCountLength &&
+		bytes.Equal(filter.Fingerprints, other.Fingerprints)
 }
@@ -1,9 +1,22 @@
 package xorfilter
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
 	"math/rand"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"unsafe"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -288,7 +301,6 @@ func BenchmarkBinaryFuse8Contains1000000(b *testing.B) {
 	}
 }
 
-
 var binaryfusedbig *BinaryFuse8
 
 func binaryfusedbigInit() {
@@ -310,3 +322,2146 @@ func BenchmarkBinaryFuse8Contains50000000(b *testing.B) {
 		binaryfusedbig.Contains(rand.Uint64())
 	}
 }
+
+// onlyReader hides the io.Seeker implementation of the wrapped reader.
+type onlyReader struct {
+	r io.Reader
+}
+
+func (o onlyReader) Read(p []byte) (int, error) {
+	return o.r.Read(p)
+}
+
+func TestBinaryFuse8FromReader(t *testing.T) {
+	keys := make([]uint64, MID_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	data := make([]byte, 8*len(keys))
+	for i, k := range keys {
+		binary.LittleEndian.PutUint64(data[8*i:], k)
+	}
+	expected, err := PopulateBinaryFuse8(keys)
+	assert.Equal(t, nil, err)
+	for _, r := range []io.Reader{bytes.NewReader(data), onlyReader{bytes.NewReader(data)}} {
+		filter, err := PopulateBinaryFuse8FromReader(r, len(keys))
+		assert.Equal(t, nil, err)
+		assert.Equal(t, expected, filter)
+	}
+	_, err = PopulateBinaryFuse8FromReader(bytes.NewReader(data[:len(data)-1]), len(keys))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestBinaryFuse8FromReaderDuplicates(t *testing.T) {
+	keys := []uint64{303, 1, 77, 31, 241, 303, 77, 77}
+	data := make([]byte, 8*len(keys))
+	for i, k := range keys {
+		binary.LittleEndian.PutUint64(data[8*i:], k)
+	}
+	for _, r := range []io.Reader{bytes.NewReader(data), onlyReader{bytes.NewReader(data)}} {
+		filter, err := PopulateBinaryFuse8FromReader(r, len(keys))
+		assert.Equal(t, nil, err)
+		for _, v := range keys {
+			assert.Equal(t, true, filter.Contains(v))
+		}
+	}
+}
+
+func TestReaderSourceSpill(t *testing.T) {
+	keys := make([]uint64, 3*readerChunkSize+5)
+	data := make([]byte, 8*len(keys))
+	for i := range keys {
+		keys[i] = rand.Uint64()
+		binary.LittleEndian.PutUint64(data[8*i:], keys[i])
+	}
+	src, err := newReaderSource(onlyReader{bytes.NewReader(data)}, len(keys))
+	assert.Equal(t, nil, err)
+	// The first pass stops after a chunk, and the next one goes on past
+	// what was spilled.
+	assert.Equal(t, nil, src.rewind())
+	chunk, err := src.next()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, keys[:readerChunkSize], chunk)
+	for pass := 0; pass < 2; pass++ {
+		got, err := collectKeys(src, false)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, keys, got)
+	}
+	assert.Equal(t, len(keys), src.spilled)
+	name := src.spill.Name()
+	src.close()
+	_, err = os.Stat(name)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestBinaryFuse8Ctx(t *testing.T) {
+	keys := make([]uint64, SMALL_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, err := PopulateBinaryFuse8Ctx(context.Background(), keys)
+	assert.Equal(t, nil, err)
+	for _, v := range keys {
+		assert.Equal(t, true, filter.Contains(v))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	filter, err = PopulateBinaryFuse8Ctx(ctx, keys)
+	assert.Equal(t, context.Canceled, err)
+	assert.Nil(t, filter)
+}
+
+func TestBinaryFuse8Progress(t *testing.T) {
+	keys := make([]uint64, 3*progressInterval)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	var reports []Progress
+	filter, err := PopulateBinaryFuse8(keys, WithProgress(func(p Progress) {
+		reports = append(reports, p)
+	}))
+	assert.Equal(t, nil, err)
+	for _, v := range keys {
+		assert.Equal(t, true, filter.Contains(v))
+	}
+	assert.True(t, len(reports) > 4*3)
+	for i := 1; i < len(reports); i++ {
+		prev, cur := reports[i-1], reports[i]
+		assert.True(t, cur.Iteration >= prev.Iteration)
+		if cur.Iteration == prev.Iteration && cur.Phase == prev.Phase {
+			assert.True(t, cur.Fraction >= prev.Fraction)
+		}
+	}
+	last := reports[len(reports)-1]
+	assert.Equal(t, PhaseAssigning, last.Phase)
+	assert.Equal(t, 1.0, last.Fraction)
+}
+
+func TestBinaryFuse8Stats(t *testing.T) {
+	keys := make([]uint64, MID_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	var stats BuildStats
+	filter, err := PopulateBinaryFuse8(keys, WithStats(&stats))
+	assert.Equal(t, nil, err)
+	assert.True(t, stats.Iterations >= 1)
+	assert.Equal(t, filter.Seed, stats.Seed)
+	assert.True(t, stats.Duration > 0)
+	assert.True(t, stats.ScratchBytes > 20*uint64(len(keys)))
+	assert.Equal(t, float64(8*len(filter.Fingerprints))/float64(len(keys)), stats.BitsPerEntry)
+}
+
+func TestBinaryFuse8RetryOptions(t *testing.T) {
+	keys := make([]uint64, SMALL_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	// Many duplicates make the first attempt fail.
+	dups := append(append([]uint64{}, keys...), keys...)
+	var stats BuildStats
+	_, err := PopulateBinaryFuse8(dups, WithStats(&stats), WithRetryPolicy(func(attempts int) bool {
+		return false
+	}))
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, stats.Iterations)
+	_, err = PopulateBinaryFuse8(dups, WithStats(&stats), WithMaxIterations(1))
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, stats.Iterations)
+	filter, err := PopulateBinaryFuse8(dups, WithRetryPolicy(func(attempts int) bool {
+		return attempts < 3
+	}))
+	assert.Equal(t, nil, err)
+	for _, v := range keys {
+		assert.Equal(t, true, filter.Contains(v))
+	}
+
+	first, _ := PopulateBinaryFuse8(keys)
+	other, err := PopulateBinaryFuse8(keys, WithRNGCounter(12345))
+	assert.Equal(t, nil, err)
+	assert.NotEqual(t, first.Seed, other.Seed)
+	for _, v := range keys {
+		assert.Equal(t, true, other.Contains(v))
+	}
+}
+
+func TestPopulatorReuse(t *testing.T) {
+	var p Populator
+	for _, n := range []int{MID_NUM_KEYS, SMALL_NUM_KEYS, 0, 2 * MID_NUM_KEYS, MID_NUM_KEYS} {
+		keys := make([]uint64, n)
+		for i := range keys {
+			keys[i] = rand.Uint64()
+		}
+		expected, err := PopulateBinaryFuse8(keys)
+		assert.Equal(t, nil, err)
+		filter, err := p.PopulateBinaryFuse8(keys)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, expected, filter)
+	}
+}
+
+func BenchmarkPopulatorBinaryFuse8Populate1000000(b *testing.B) {
+	keys := make([]uint64, NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	var p Populator
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		p.PopulateBinaryFuse8(keys)
+	}
+}
+
+func TestBinaryFuse8PooledScratch(t *testing.T) {
+	keys := make([]uint64, MID_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	expected, err := new(Populator).PopulateBinaryFuse8(keys)
+	assert.Equal(t, nil, err)
+	for trial := 0; trial < 3; trial++ {
+		filter, err := PopulateBinaryFuse8(keys)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, expected, filter)
+		// Filters of other sizes use other pools.
+		_, err = PopulateBinaryFuse8(keys[:trial*SMALL_NUM_KEYS])
+		assert.Equal(t, nil, err)
+	}
+}
+
+func TestBinaryFuse8FromUint32(t *testing.T) {
+	keys := make([]uint32, MID_NUM_KEYS)
+	wide := make([]uint64, len(keys))
+	for i := range keys {
+		keys[i] = rand.Uint32()
+		wide[i] = uint64(keys[i])
+	}
+	filter, err := PopulateBinaryFuse8FromUint32(keys)
+	assert.Equal(t, nil, err)
+	for _, v := range keys {
+		assert.Equal(t, true, filter.ContainsUint32(v))
+	}
+	expected, err := PopulateBinaryFuse8(wide)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, expected, filter)
+}
+
+func TestBinaryFuse8FromStrings(t *testing.T) {
+	keys := make([]string, MID_NUM_KEYS)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%x%s", rand.Uint64(), strings.Repeat("k", i%60))
+	}
+	filter, err := PopulateBinaryFuse8FromStrings(keys)
+	assert.Equal(t, nil, err)
+	for _, v := range keys {
+		assert.Equal(t, true, filter.ContainsString(v))
+	}
+	// The hash of a string is fixed: changing it breaks the filters already built.
+	assert.Equal(t, uint64(0xa0761d6478bd642f), hashString("", stringSeed))
+	long := strings.Repeat("xorfilter", 20)
+	assert.Equal(t, hashString(long, stringSeed), hashString(string([]byte(long)), stringSeed))
+	assert.NotEqual(t, hashString(long, stringSeed), hashString(long[1:], stringSeed))
+	matches := 0
+	for i := 0; i < 100000; i++ {
+		if filter.ContainsString(fmt.Sprintf("other-%d", i)) {
+			matches++
+		}
+	}
+	assert.True(t, matches < 1000)
+}
+
+func TestBinaryFuse8FromBytes(t *testing.T) {
+	keys := make([][]byte, MID_NUM_KEYS)
+	for i := range keys {
+		keys[i] = make([]byte, 32)
+		rand.Read(keys[i])
+	}
+	filter, err := PopulateBinaryFuse8FromBytes(keys)
+	assert.Equal(t, nil, err)
+	for _, v := range keys {
+		assert.Equal(t, true, filter.ContainsBytes(v))
+		assert.Equal(t, true, filter.ContainsString(string(v)))
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		filter.ContainsBytes(keys[0])
+	})
+	assert.Equal(t, 0.0, allocs)
+}
+
+func TestBinaryFuse8ContainsBytesAllocs(t *testing.T) {
+	keys := make([][]byte, 0, 1000)
+	for _, n := range []int{0, 1, 3, 4, 7, 8, 15, 16, 17, 48, 49, 100, 1000} {
+		key := make([]byte, n)
+		rand.Read(key)
+		keys = append(keys, key)
+	}
+	filter, err := PopulateBinaryFuse8FromBytes(keys)
+	assert.Equal(t, nil, err)
+	for _, opts := range [][]Option{nil, {WithHasher(HasherFunc(mixsplit))}} {
+		filter, err := PopulateBinaryFuse8FromBytes(keys, opts...)
+		assert.Equal(t, nil, err)
+		for _, key := range keys {
+			// The key is copied to the stack, where it stays only if
+			// ContainsBytes does not let it escape.
+			allocs := testing.AllocsPerRun(100, func() {
+				var buf [1000]byte
+				copy(buf[:], key)
+				if !filter.ContainsBytes(buf[:len(key)]) {
+					t.Fatal("missing key")
+				}
+			})
+			assert.Equal(t, 0.0, allocs, "%d bytes", len(key))
+		}
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		filter.ContainsBytes([]byte("not a key"))
+	})
+	assert.Equal(t, 0.0, allocs)
+}
+
+func TestBinaryFuse8Hasher(t *testing.T) {
+	keys := make([]uint64, MID_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	const secret = 0x0123456789abcdef
+	calls := 0
+	hasher := HasherFunc(func(key, seed uint64) uint64 {
+		calls++
+		return murmur64(rotl64(key^secret, 17) + seed)
+	})
+	filter, err := PopulateBinaryFuse8(keys, WithHasher(hasher))
+	assert.Equal(t, nil, err)
+	assert.True(t, calls >= len(keys))
+	assert.NotNil(t, filter.Hasher())
+	for _, v := range keys {
+		assert.Equal(t, true, filter.Contains(v))
+	}
+	restored := &BinaryFuse8{
+		Seed:               filter.Seed,
+		SegmentLength:      filter.SegmentLength,
+		SegmentLengthMask:  filter.SegmentLengthMask,
+		SegmentCount:       filter.SegmentCount,
+		SegmentCountLength: filter.SegmentCountLength,
+		Fingerprints:       filter.Fingerprints,
+	}
+	matches := 0
+	for _, v := range keys {
+		if restored.Contains(v) {
+			matches++
+		}
+	}
+	assert.True(t, matches < len(keys)/10)
+	restored.SetHasher(hasher)
+	for _, v := range keys {
+		assert.Equal(t, true, restored.Contains(v))
+	}
+}
+
+func TestBinaryFuse8Fingerprinter(t *testing.T) {
+	keys := make([]uint64, MID_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	fingerprinter := FingerprinterFunc(func(hash uint64) uint8 {
+		return uint8(hash>>56) ^ uint8(hash>>24)
+	})
+	reference, err := PopulateBinaryFuse8(keys)
+	assert.Equal(t, nil, err)
+	for _, opts := range [][]Option{nil, {WithParallelism(4), WithAlgorithmVersion(AlgorithmV2)}, {WithHasher(HasherFunc(mixsplit))}} {
+		filter, err := PopulateBinaryFuse8(keys, append(opts, WithFingerprinter(fingerprinter))...)
+		assert.Equal(t, nil, err)
+		assert.NotNil(t, filter.Fingerprinter())
+		assert.False(t, filter.Equal(reference))
+		out := make([]bool, len(keys))
+		filter.ContainsBatch(keys, out)
+		for i, v := range keys {
+			assert.True(t, out[i])
+			assert.True(t, filter.Contains(v))
+			assert.True(t, filter.ContainsUnchecked(v))
+			assert.True(t, filter.ContainsConstantTime(v))
+			assert.True(t, filter.ContainsSafe(v))
+		}
+		var eight [8]uint64
+		copy(eight[:], keys)
+		assert.Equal(t, [8]bool{true, true, true, true, true, true, true, true}, filter.Contains8(eight))
+		falsePositives := 0
+		for i := 0; i < 100000; i++ {
+			if filter.Contains(rand.Uint64()) {
+				falsePositives++
+			}
+		}
+		assert.True(t, falsePositives < 2*100000/256, "%d false positives", falsePositives)
+
+		data, err := filter.MarshalBinary()
+		assert.Equal(t, nil, err)
+		restored := &BinaryFuse8{}
+		restored.SetHasher(filter.Hasher())
+		restored.SetFingerprinter(fingerprinter)
+		assert.Equal(t, nil, restored.UnmarshalBinary(data))
+		for _, v := range keys {
+			assert.True(t, restored.Contains(v))
+		}
+	}
+
+	// Four bits of fingerprint make a filter of the same size with a false
+	// positive rate of 1/16.
+	filter, err := PopulateBinaryFuse8(keys, WithFingerprinter(FingerprinterFunc(func(hash uint64) uint8 {
+		return uint8(fingerprint(hash)) & 0x0f
+	})))
+	assert.Equal(t, nil, err)
+	falsePositives := 0
+	for i := 0; i < 100000; i++ {
+		if filter.Contains(rand.Uint64()) {
+			falsePositives++
+		}
+	}
+	assert.InDelta(t, 100000/16, falsePositives, 1000)
+	hashes := keys[:1000]
+	filter, err = PopulateBinaryFuse8Hashed(hashes, WithFingerprinter(fingerprinter))
+	assert.Equal(t, nil, err)
+	for _, v := range hashes {
+		assert.True(t, filter.ContainsHashed(v))
+	}
+}
+
+func TestBinaryFuse8Namespace(t *testing.T) {
+	keys := make([]uint64, MID_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	a, err := PopulateBinaryFuse8(keys, WithNamespace("tenant-a"))
+	assert.Equal(t, nil, err)
+	b, err := PopulateBinaryFuse8(keys, WithNamespace("tenant-b"))
+	assert.Equal(t, nil, err)
+	plain, err := PopulateBinaryFuse8(keys)
+	assert.Equal(t, nil, err)
+	assert.True(t, a.Namespaced())
+	assert.False(t, plain.Namespaced())
+	assert.False(t, a.Equal(b))
+	assert.False(t, a.Equal(plain))
+	out := make([]bool, len(keys))
+	for _, filter := range []*BinaryFuse8{a, b} {
+		filter.ContainsBatch(keys, out)
+		for i, v := range keys {
+			assert.True(t, out[i])
+			assert.True(t, filter.Contains(v))
+			assert.True(t, filter.ContainsSafe(v))
+			assert.True(t, filter.ContainsConstantTime(v))
+		}
+	}
+
+	// The false positives of a filter are those of another filter of the
+	// same keys and namespace, but not of one of another namespace.
+	probes, inB, inA2 := 0, 0, 0
+	a2, err := PopulateBinaryFuse8(keys, WithNamespace("tenant-a"))
+	assert.Equal(t, nil, err)
+	for i := 0; i < 1000000; i++ {
+		key := rand.Uint64()
+		if !a.Contains(key) {
+			continue
+		}
+		probes++
+		if b.Contains(key) {
+			inB++
+		}
+		if a2.Contains(key) {
+			inA2++
+		}
+	}
+	assert.Equal(t, probes, inA2)
+	assert.True(t, inB < probes/20, "%d of %d false positives shared", inB, probes)
+
+	data, err := a.MarshalBinary()
+	assert.Equal(t, nil, err)
+	restored := &BinaryFuse8{}
+	restored.SetNamespace("tenant-a")
+	assert.Equal(t, nil, restored.UnmarshalBinary(data))
+	for _, v := range keys {
+		assert.True(t, restored.Contains(v))
+	}
+
+	hashed, err := PopulateBinaryFuse8Hashed(keys, WithNamespace("tenant-a"))
+	assert.Equal(t, nil, err)
+	for _, v := range keys {
+		assert.True(t, hashed.ContainsHashed(v))
+	}
+	names := []string{"alice", "bob", "carol"}
+	strs, err := PopulateBinaryFuse8FromStrings(names, WithNamespace("tenant-a"))
+	assert.Equal(t, nil, err)
+	for _, name := range names {
+		assert.True(t, strs.ContainsString(name))
+	}
+}
+
+func TestBinaryFuse8Hashed(t *testing.T) {
+	hashes := make([]uint64, MID_NUM_KEYS)
+	for i := range hashes {
+		hashes[i] = rand.Uint64()
+	}
+	filter, err := PopulateBinaryFuse8Hashed(hashes)
+	assert.Equal(t, nil, err)
+	for _, v := range hashes {
+		assert.Equal(t, true, filter.ContainsHashed(v))
+	}
+	// The seed retry loop still works: duplicates force new seeds.
+	dups := append(append([]uint64{}, hashes[:SMALL_NUM_KEYS]...), hashes[:SMALL_NUM_KEYS]...)
+	var stats BuildStats
+	filter, err = PopulateBinaryFuse8Hashed(dups, WithStats(&stats))
+	assert.Equal(t, nil, err)
+	assert.True(t, stats.Iterations > 1)
+	for _, v := range dups {
+		assert.Equal(t, true, filter.ContainsHashed(v))
+	}
+	matches := 0
+	for i := 0; i < 100000; i++ {
+		if filter.ContainsHashed(rand.Uint64()) {
+			matches++
+		}
+	}
+	assert.True(t, matches < 1000)
+}
+
+func TestBinaryFuse8From128(t *testing.T) {
+	keys := make([][16]byte, MID_NUM_KEYS)
+	for i := range keys {
+		rand.Read(keys[i][:])
+	}
+	// Keys that only differ by the xor of their halves are distinct keys.
+	keys[1] = keys[0]
+	keys[1][0] ^= 1
+	keys[1][8] ^= 1
+	filter, err := PopulateBinaryFuse8From128(keys)
+	assert.Equal(t, nil, err)
+	for _, v := range keys {
+		assert.Equal(t, true, filter.Contains128(v))
+		assert.Equal(t, true, filter.ContainsBytes(v[:]))
+		assert.Equal(t, true, filter.ContainsUint128(binary.BigEndian.Uint64(v[:8]), binary.BigEndian.Uint64(v[8:])))
+	}
+	for _, v := range keys {
+		assert.Equal(t, hashBytes(v[:], stringSeed), hashUint128(binary.BigEndian.Uint64(v[:8]), binary.BigEndian.Uint64(v[8:]), stringSeed))
+	}
+	assert.NotEqual(t, hashBytes(keys[0][:], stringSeed), hashBytes(keys[1][:], stringSeed))
+}
+
+func TestBinaryFuse8FromUUIDs(t *testing.T) {
+	uuids := make([][16]byte, 1000)
+	for i := range uuids {
+		rand.Read(uuids[i][:])
+		uuids[i][6] = uuids[i][6]&0x0f | 0x40 // version 4
+		uuids[i][8] = uuids[i][8]&0x3f | 0x80 // RFC 4122 variant
+	}
+	// UUIDs with swapped halves, and with equal halves, that a xor of the
+	// halves would fold to the same key.
+	copy(uuids[1][:8], uuids[0][8:])
+	copy(uuids[1][8:], uuids[0][:8])
+	copy(uuids[3][8:], uuids[3][:8])
+	copy(uuids[2][:], uuids[3][:])
+	uuids[2][0], uuids[2][8] = uuids[2][0]^0xff, uuids[2][8]^0xff
+	filter, err := PopulateBinaryFuse8FromUUIDs(uuids)
+	assert.Equal(t, nil, err)
+	expected, err := PopulateBinaryFuse8From128(uuids)
+	assert.Equal(t, nil, err)
+	assert.True(t, filter.Equal(expected))
+	frozen, err := filter.Freeze()
+	assert.Equal(t, nil, err)
+	for _, v := range uuids {
+		assert.True(t, filter.ContainsUUID(v))
+		assert.True(t, frozen.ContainsUUID(v))
+	}
+	keys := make(map[uint64]bool)
+	for _, v := range uuids {
+		keys[hashBytes(v[:], stringSeed)] = true
+	}
+	assert.Equal(t, len(uuids), len(keys))
+}
+
+func TestBinaryFuse8Tiny(t *testing.T) {
+	for n := 0; n < tinySize+2; n++ {
+		for trial := 0; trial < 100; trial++ {
+			keys := make([]uint64, n)
+			for i := range keys {
+				keys[i] = rand.Uint64()
+			}
+			filter, err := PopulateBinaryFuse8(keys)
+			assert.Equal(t, nil, err)
+			assert.True(t, len(filter.Fingerprints) <= 48)
+			for _, v := range keys {
+				assert.Equal(t, true, filter.Contains(v))
+			}
+		}
+	}
+}
+
+func TestBinaryFuse8Big(t *testing.T) {
+	keys := make([]uint64, MID_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, err := PopulateBinaryFuse8Big(keys)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(filter.Shards))
+	// Force several shards with a small number of keys per shard.
+	filter, err = populateBinaryFuse8Big(context.Background(), &sliceSource{keys: keys}, uint64(len(keys)), 1000, newBuildConfig(nil))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, (len(keys)+999)/1000, len(filter.Shards))
+	total := 0
+	for i := range filter.Shards {
+		total += len(filter.Shards[i].Fingerprints)
+	}
+	assert.True(t, total < 2*len(keys))
+	for _, v := range keys {
+		assert.Equal(t, true, filter.Contains(v))
+	}
+	matches := 0
+	for i := 0; i < 100000; i++ {
+		if filter.Contains(rand.Uint64()) {
+			matches++
+		}
+	}
+	assert.True(t, matches < 1000)
+	if n := uint64(MaxBinaryFuse8Keys) + 1; int(n) > 0 {
+		_, err = PopulateBinaryFuse8FromReader(bytes.NewReader(nil), int(n))
+		assert.Equal(t, ErrTooManyKeys, err)
+	}
+	if ^uint(0)>>32 == 0 {
+		// On 32-bit platforms, the arrays of the construction overflow
+		// the address space well before MaxBinaryFuse8Keys keys.
+		_, err = PopulateBinaryFuse8FromReader(bytes.NewReader(nil), 1<<30)
+		assert.Equal(t, ErrTooManyKeys, err)
+	}
+}
+
+func TestBinaryFuse8SortedUniqueInput(t *testing.T) {
+	keys := make([]uint64, MID_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	keys = pruneDuplicates(keys)
+	filter, err := PopulateBinaryFuse8(keys, WithSortedUniqueInput())
+	assert.Equal(t, nil, err)
+	for _, v := range keys {
+		assert.Equal(t, true, filter.Contains(v))
+	}
+	keys[1] = keys[0]
+	_, err = PopulateBinaryFuse8(keys, WithSortedUniqueInput())
+	assert.Equal(t, ErrNotSortedUnique, err)
+}
+
+func TestBinaryFuse8DuplicateCheck(t *testing.T) {
+	keys := make([]uint64, MID_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, err := PopulateBinaryFuse8(keys, WithDuplicateCheck())
+	assert.Equal(t, nil, err)
+	for _, v := range keys {
+		assert.Equal(t, true, filter.Contains(v))
+	}
+	keys[len(keys)-1] = keys[0]
+	var stats BuildStats
+	filter, err = PopulateBinaryFuse8(keys, WithDuplicateCheck(), WithStats(&stats))
+	assert.Equal(t, ErrDuplicateKeys, err)
+	assert.Nil(t, filter)
+	assert.Equal(t, 0, stats.Iterations)
+	_, err = PopulateBinaryFuse8FromStrings([]string{"a", "b", "a"}, WithDuplicateCheck())
+	assert.Equal(t, ErrDuplicateKeys, err)
+}
+
+func TestEstimateBinaryFuse8Memory(t *testing.T) {
+	for _, n := range []int{0, 1, SMALL_NUM_KEYS, MID_NUM_KEYS, NUM_KEYS} {
+		keys := make([]uint64, n)
+		for i := range keys {
+			keys[i] = rand.Uint64()
+		}
+		var stats BuildStats
+		filter, err := PopulateBinaryFuse8(keys, WithStats(&stats))
+		assert.Equal(t, nil, err)
+		estimate := EstimateBinaryFuse8Memory(uint64(n))
+		assert.Equal(t, uint64(len(filter.Fingerprints)), estimate.FilterBytes)
+		assert.Equal(t, stats.ScratchBytes, estimate.ScratchBytes)
+	}
+	big := EstimateBinaryFuse8Memory(10e9)
+	assert.True(t, big.FilterBytes > 10e9 && big.FilterBytes < 12e9)
+	assert.True(t, big.ScratchBytes < 32*bigShardKeys)
+}
+
+func TestBinaryFuse8Sizes(t *testing.T) {
+	keys := make([]uint64, MID_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, err := PopulateBinaryFuse8(keys)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, uint64(24+len(filter.Fingerprints)), filter.SizeInBytes())
+	assert.True(t, filter.MemoryBytes() > filter.SizeInBytes())
+	assert.Equal(t, float64(8*len(filter.Fingerprints))/float64(len(keys)), filter.BitsPerEntry(len(keys)))
+	assert.True(t, filter.BitsPerEntry(len(keys)) < 10)
+
+	big, err := populateBinaryFuse8Big(context.Background(), &sliceSource{keys: keys}, uint64(len(keys)), 1000, newBuildConfig(nil))
+	assert.Equal(t, nil, err)
+	assert.True(t, big.BitsPerEntry(len(keys)) < 16)
+	assert.True(t, big.MemoryBytes() > big.SizeInBytes())
+}
+
+func TestBinaryFuse8FalsePositiveRate(t *testing.T) {
+	keys := make([]uint64, MID_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, err := PopulateBinaryFuse8(keys)
+	assert.Equal(t, nil, err)
+	falsesize := 1000000
+	matches := 0
+	for i := 0; i < falsesize; i++ {
+		if filter.Contains(rand.Uint64()) {
+			matches++
+		}
+	}
+	fpp := float64(matches) / float64(falsesize)
+	assert.InDelta(t, filter.FalsePositiveRate(), fpp, 0.001)
+}
+
+func TestBinaryFuse8Clone(t *testing.T) {
+	keys := make([]uint64, SMALL_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, err := PopulateBinaryFuse8(keys)
+	assert.Equal(t, nil, err)
+	clone := filter.Clone()
+	assert.Equal(t, filter, clone)
+	clone.Fingerprints[0]++
+	assert.NotEqual(t, filter.Fingerprints[0], clone.Fingerprints[0])
+
+	big, err := PopulateBinaryFuse8Big(keys)
+	assert.Equal(t, nil, err)
+	bigClone := big.Clone()
+	assert.Equal(t, big, bigClone)
+	bigClone.Shards[0].Fingerprints[0]++
+	assert.NotEqual(t, big.Shards[0].Fingerprints[0], bigClone.Shards[0].Fingerprints[0])
+}
+
+func TestBinaryFuse8Equal(t *testing.T) {
+	keys := make([]uint64, SMALL_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, err := PopulateBinaryFuse8(keys)
+	assert.Equal(t, nil, err)
+	clone := filter.Clone()
+	assert.True(t, filter.Equal(clone))
+	clone.Fingerprints[0]++
+	assert.False(t, filter.Equal(clone))
+	clone = filter.Clone()
+	clone.Seed++
+	assert.False(t, filter.Equal(clone))
+	// The version is not compared.
+	restored := &BinaryFuse8{
+		Seed:               filter.Seed,
+		SegmentLength:      filter.SegmentLength,
+		SegmentLengthMask:  filter.SegmentLengthMask,
+		SegmentCount:       filter.SegmentCount,
+		SegmentCountLength: filter.SegmentCountLength,
+		Fingerprints:       filter.Fingerprints,
+	}
+	assert.Equal(t, AlgorithmVersion(0), restored.AlgorithmVersion())
+	assert.True(t, filter.Equal(restored))
+
+	big, err := PopulateBinaryFuse8Big(keys)
+	assert.Equal(t, nil, err)
+	bigClone := big.Clone()
+	assert.True(t, big.Equal(bigClone))
+	bigClone.Shards[0].SegmentCount++
+	assert.False(t, big.Equal(bigClone))
+}
+
+func TestBinaryFuse8Validate(t *testing.T) {
+	for _, n := range []int{0, 1, SMALL_NUM_KEYS, MID_NUM_KEYS} {
+		keys := make([]uint64, n)
+		for i := range keys {
+			keys[i] = rand.Uint64()
+		}
+		filter, err := PopulateBinaryFuse8(keys)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, nil, filter.Validate())
+		data, err := filter.MarshalBinary()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, filter.binarySize(), uint64(len(data)))
+		var decoded BinaryFuse8
+		assert.Equal(t, nil, decoded.UnmarshalBinary(data))
+		assert.True(t, filter.Equal(&decoded))
+		for _, v := range keys {
+			assert.Equal(t, true, decoded.Contains(v))
+		}
+	}
+
+	filter, _ := PopulateBinaryFuse8([]uint64{1, 2, 3, 4, 5, 6, 7, 8, 9})
+	corruptions := []func(f *BinaryFuse8){
+		func(f *BinaryFuse8) { f.SegmentLength = 3 },
+		func(f *BinaryFuse8) { f.SegmentLengthMask = 0 },
+		func(f *BinaryFuse8) { f.SegmentCount = 0 },
+		func(f *BinaryFuse8) { f.SegmentCountLength *= 2 },
+		func(f *BinaryFuse8) { f.Fingerprints = f.Fingerprints[1:] },
+		func(f *BinaryFuse8) { f.Fingerprints = nil },
+	}
+	for _, corrupt := range corruptions {
+		bad := filter.Clone()
+		corrupt(bad)
+		err := bad.Validate()
+		assert.True(t, errors.Is(err, ErrInvalidFilter), "%v", err)
+		data, _ := bad.MarshalBinary()
+		assert.True(t, errors.Is(new(BinaryFuse8).UnmarshalBinary(data), ErrInvalidFilter))
+	}
+	data, _ := filter.MarshalBinary()
+	assert.True(t, errors.Is(new(BinaryFuse8).UnmarshalBinary(data[:len(data)-1]), ErrInvalidFilter))
+	assert.True(t, errors.Is(new(BinaryFuse8).UnmarshalBinary(append(data, 0)), ErrInvalidFilter))
+	// A huge segment count is rejected before anything is allocated.
+	binary.LittleEndian.PutUint32(data[16:], 1<<31)
+	assert.True(t, errors.Is(new(BinaryFuse8).UnmarshalBinary(data), ErrInvalidFilter))
+	// So are segments whose fingerprints would overflow 32-bit indexes.
+	huge := &BinaryFuse8{
+		SegmentLength:      maxSegmentLength,
+		SegmentLengthMask:  maxSegmentLength - 1,
+		SegmentCount:       1<<32/maxSegmentLength - 1,
+		SegmentCountLength: (1<<32/maxSegmentLength - 1) * maxSegmentLength,
+	}
+	err := huge.Validate()
+	assert.True(t, errors.Is(err, ErrInvalidFilter))
+	assert.True(t, strings.Contains(err.Error(), "more than"), "%v", err)
+
+	keys := make([]uint64, MID_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	big, err := populateBinaryFuse8Big(context.Background(), &sliceSource{keys: keys}, uint64(len(keys)), 1000, newBuildConfig(nil))
+	assert.Equal(t, nil, err)
+	data, err = big.MarshalBinary()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, big.binarySize(), uint64(len(data)))
+	var decodedBig BinaryFuse8Big
+	assert.Equal(t, nil, decodedBig.UnmarshalBinary(data))
+	assert.True(t, big.Equal(&decodedBig))
+	assert.True(t, errors.Is(decodedBig.UnmarshalBinary(data[:len(data)-1]), ErrInvalidFilter))
+}
+
+func TestBinaryFuse8String(t *testing.T) {
+	keys := make([]uint64, MID_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, err := PopulateBinaryFuse8(keys)
+	assert.Equal(t, nil, err)
+	s := filter.String()
+	assert.Contains(t, s, fmt.Sprintf("seed: %#x", filter.Seed))
+	assert.Contains(t, s, fmt.Sprintf("%d segments of length %d", filter.SegmentCount, filter.SegmentLength))
+	assert.Contains(t, s, "bits/key")
+	var buf bytes.Buffer
+	assert.Equal(t, nil, filter.Dump(&buf))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, s, lines[0])
+	assert.Equal(t, int(filter.SegmentCount)+2+1, len(lines))
+	assert.Contains(t, (&BinaryFuse8{}).String(), "0 fingerprints")
+}
+
+func TestNewOptimalFilter(t *testing.T) {
+	keys := make([]uint64, NUM_KEYS/10)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, choice, err := NewOptimalFilter(keys, 0.01)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "BinaryFuse8", choice.Type)
+	assert.Equal(t, 8, choice.FingerprintBits)
+	assert.Equal(t, filter.FalsePositiveRate(), choice.FalsePositiveRate)
+	assert.Equal(t, filter.SizeInBytes()-24, choice.Memory.FilterBytes)
+	for _, v := range keys {
+		assert.Equal(t, true, filter.Contains(v))
+	}
+	_, _, err = NewOptimalFilter(keys, 0.0001)
+	assert.Equal(t, ErrUnreachableFalsePositiveRate, err)
+
+	// A Xor8 filter is smaller for a small set, but takes no options.
+	small := keys[:SMALL_NUM_KEYS]
+	filter, choice, err = NewOptimalFilter(small, 0.01)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "Xor8", choice.Type)
+	assert.IsType(t, &Xor8{}, filter)
+	assert.Equal(t, filter.SizeInBytes()-12, choice.Memory.FilterBytes)
+	assert.True(t, choice.Memory.FilterBytes < EstimateBinaryFuse8Memory(uint64(len(small))).FilterBytes)
+	for _, v := range small {
+		assert.Equal(t, true, filter.Contains(v))
+	}
+	filter, choice, err = NewOptimalFilter(small, 0.01, WithSeed(1))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "BinaryFuse8", choice.Type)
+	assert.Equal(t, uint64(1), filter.(*BinaryFuse8).Seed)
+
+	choice, err = ChooseFilter(10e9, 0.01)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "BinaryFuse8Big", choice.Type)
+}
+
+func TestBinaryFuse8VerifyKeys(t *testing.T) {
+	keys := make([]uint64, MID_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	keys = append(keys, keys[:SMALL_NUM_KEYS]...)
+	filter, err := PopulateBinaryFuse8(keys, WithVerifyKeys())
+	assert.Equal(t, nil, err)
+	assert.NotNil(t, filter)
+	_, err = PopulateBinaryFuse8FromStrings([]string{"a", "b", "c"}, WithVerifyKeys())
+	assert.Equal(t, nil, err)
+	_, err = PopulateBinaryFuse8Hashed(keys, WithVerifyKeys())
+	assert.Equal(t, nil, err)
+
+	built, err := PopulateBinaryFuse8(keys)
+	assert.Equal(t, nil, err)
+	for i := range built.Fingerprints {
+		built.Fingerprints[i]++
+	}
+	assert.Equal(t, ErrMissingKey, verifyKeys(built, &sliceSource{keys: keys}))
+}
+
+func TestBinaryFuse8ConstructionError(t *testing.T) {
+	keys := make([]uint64, SMALL_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	dups := append(append([]uint64{}, keys...), keys...)
+	_, err := PopulateBinaryFuse8(dups, WithMaxIterations(1))
+	assert.True(t, errors.Is(err, ErrTooManyIterations))
+	var failure *ConstructionError
+	assert.True(t, errors.As(err, &failure))
+	assert.Equal(t, 1, failure.Attempts)
+	assert.Equal(t, 1, len(failure.Seeds))
+	// The duplicates were removed after the first attempt.
+	assert.Equal(t, uint32(len(keys)), failure.Keys)
+	assert.Equal(t, VerdictLikelyDuplicates, failure.Verdict())
+	assert.Contains(t, err.Error(), "likely duplicates")
+
+	_, err = PopulateBinaryFuse8(dups, WithMaxIterations(3))
+	assert.Equal(t, nil, err)
+}
+
+func TestBinaryFuse8SizeFactor(t *testing.T) {
+	keys := make([]uint64, MID_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	for _, factor := range []float64{1.5, 2, 3} {
+		var stats BuildStats
+		padded, err := PopulateBinaryFuse8(keys, WithSizeFactor(factor), WithStats(&stats))
+		assert.Equal(t, nil, err)
+		assert.True(t, len(padded.Fingerprints) >= int(factor*float64(len(keys))))
+		assert.Equal(t, uint64(len(padded.Fingerprints)), EstimateBinaryFuse8Memory(uint64(len(keys)), WithSizeFactor(factor)).FilterBytes)
+		for _, v := range keys {
+			assert.Equal(t, true, padded.Contains(v))
+		}
+	}
+	// Too few fingerprints make the construction fail, and the failure is
+	// not blamed on duplicates.
+	_, err := PopulateBinaryFuse8(keys, WithSizeFactor(1.01), WithMaxIterations(5))
+	var failure *ConstructionError
+	assert.True(t, errors.As(err, &failure))
+	assert.Equal(t, VerdictBadLuck, failure.Verdict())
+	assert.True(t, failure.BestPeeled < failure.Keys)
+	_, err = PopulateBinaryFuse8(keys, WithSizeFactor(0.5))
+	assert.NotNil(t, err)
+}
+
+func TestBinaryFuse8SegmentLength(t *testing.T) {
+	keys := make([]uint64, MID_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	for _, length := range []uint32{256, 1024, 4096} {
+		filter, err := PopulateBinaryFuse8(keys, WithSegmentLength(length))
+		assert.Equal(t, nil, err)
+		assert.Equal(t, length, filter.SegmentLength)
+		assert.Equal(t, nil, filter.Validate())
+		for _, v := range keys {
+			assert.Equal(t, true, filter.Contains(v))
+		}
+	}
+	_, err := PopulateBinaryFuse8(keys, WithSegmentLength(1000))
+	assert.NotNil(t, err)
+	_, err = PopulateBinaryFuse8(keys, WithSegmentLength(1<<20))
+	assert.NotNil(t, err)
+}
+
+func TestBinaryFuse8Parallelism(t *testing.T) {
+	keys := make([]uint64, 8*minKeysPerWorker)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	expected, err := PopulateBinaryFuse8(keys, WithParallelism(1))
+	assert.Equal(t, nil, err)
+	expectedBytes, _ := expected.MarshalBinary()
+	for _, n := range []int{0, 2, 3, 16} {
+		filter, err := PopulateBinaryFuse8(keys, WithParallelism(n))
+		assert.Equal(t, nil, err)
+		assert.Equal(t, nil, filter.Validate())
+		for _, v := range keys {
+			assert.Equal(t, true, filter.Contains(v))
+		}
+		data, _ := filter.MarshalBinary()
+		assert.Equal(t, expectedBytes, data)
+	}
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(0))
+	for _, procs := range []int{1, 2, 7} {
+		runtime.GOMAXPROCS(procs)
+		filter, err := PopulateBinaryFuse8(keys, WithParallelism(0))
+		assert.Equal(t, nil, err)
+		data, _ := filter.MarshalBinary()
+		assert.Equal(t, expectedBytes, data)
+	}
+	dups := append(append([]uint64{}, keys...), keys[:SMALL_NUM_KEYS]...)
+	filter, err := PopulateBinaryFuse8(dups, WithParallelism(4))
+	assert.Equal(t, nil, err)
+	for _, v := range dups {
+		assert.Equal(t, true, filter.Contains(v))
+	}
+}
+
+func BenchmarkBinaryFuse8PopulateParallel1000000(b *testing.B) {
+	keys := make([]uint64, NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		PopulateBinaryFuse8(keys, WithParallelism(0))
+	}
+}
+
+func TestBinaryFuse8ContainsBatch(t *testing.T) {
+	keys := make([]uint64, MID_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	queries := make([]uint64, 2*len(keys)+3)
+	copy(queries, keys)
+	for i := len(keys); i < len(queries); i++ {
+		queries[i] = rand.Uint64()
+	}
+	rand.Shuffle(len(queries), func(i, j int) { queries[i], queries[j] = queries[j], queries[i] })
+	detected := batchKernel
+	defer func() { batchKernel = detected }()
+	for kernel := kernelGeneric; kernel <= detected; kernel++ {
+		batchKernel = kernel
+		for _, size := range []int{0, 1, 5, SMALL_NUM_KEYS, len(keys)} {
+			for _, opts := range [][]Option{nil, {WithHasher(HasherFunc(mixsplit))}} {
+				filter, err := PopulateBinaryFuse8(keys[:size], opts...)
+				assert.Equal(t, nil, err)
+				out := make([]bool, len(queries))
+				for _, n := range []int{0, 1, 4, 7, 8, 17, len(queries)} {
+					filter.ContainsBatch(queries[:n], out)
+					for i, v := range queries[:n] {
+						assert.Equal(t, filter.Contains(v), out[i])
+					}
+				}
+			}
+		}
+	}
+	filter, _ := PopulateBinaryFuse8(keys)
+	assert.Panics(t, func() { filter.ContainsBatch(keys, make([]bool, 1)) })
+}
+
+func BenchmarkBinaryFuse8ContainsBatch50000000(b *testing.B) {
+	keys := make([]uint64, 50000000)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, _ := PopulateBinaryFuse8(keys)
+	out := make([]bool, 1024)
+	b.ResetTimer()
+	for n := 0; n < b.N; n += len(out) {
+		filter.ContainsBatch(keys[n%(len(keys)-len(out)):][:len(out)], out)
+	}
+}
+
+func TestBinaryFuse8ContainsAllAny(t *testing.T) {
+	keys := make([]uint64, SMALL_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, err := PopulateBinaryFuse8(keys)
+	assert.Equal(t, nil, err)
+	big, err := PopulateBinaryFuse8Big(keys)
+	assert.Equal(t, nil, err)
+	missing := rand.Uint64()
+	for filter.Contains(missing) || big.Contains(missing) {
+		missing = rand.Uint64()
+	}
+	assert.Equal(t, true, filter.ContainsAll(keys))
+	assert.Equal(t, true, filter.ContainsAll(nil))
+	assert.Equal(t, false, filter.ContainsAll(append([]uint64{missing}, keys...)))
+	assert.Equal(t, true, filter.ContainsAny([]uint64{missing, keys[0]}))
+	assert.Equal(t, false, filter.ContainsAny([]uint64{missing}))
+	assert.Equal(t, false, filter.ContainsAny(nil))
+	assert.Equal(t, true, big.ContainsAll(keys))
+	assert.Equal(t, false, big.ContainsAll(append([]uint64{missing}, keys...)))
+	assert.Equal(t, true, big.ContainsAny([]uint64{missing, keys[0]}))
+	assert.Equal(t, false, big.ContainsAny([]uint64{missing}))
+}
+
+func TestBinaryFuse8CountContained(t *testing.T) {
+	keys := make([]uint64, MID_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, err := PopulateBinaryFuse8(keys[:MID_NUM_KEYS/2])
+	assert.Equal(t, nil, err)
+	big, err := PopulateBinaryFuse8Big(keys[:MID_NUM_KEYS/2])
+	assert.Equal(t, nil, err)
+	frozen, err := filter.Freeze()
+	assert.Equal(t, nil, err)
+	namespaced, err := PopulateBinaryFuse8(keys[:MID_NUM_KEYS/2], WithNamespace("count"))
+	assert.Equal(t, nil, err)
+	for _, n := range []int{0, 1, 63, 64, 65, 255, 256, 257, 1000, len(keys)} {
+		expected := 0
+		for _, key := range keys[:n] {
+			if filter.Contains(key) {
+				expected++
+			}
+		}
+		assert.Equal(t, expected, filter.CountContained(keys[:n]), "%d keys", n)
+		assert.Equal(t, expected, frozen.CountContained(keys[:n]), "%d keys", n)
+		expected = 0
+		for _, key := range keys[:n] {
+			if namespaced.Contains(key) {
+				expected++
+			}
+		}
+		assert.Equal(t, expected, namespaced.CountContained(keys[:n]), "%d keys", n)
+		expected = 0
+		for _, key := range keys[:n] {
+			if big.Contains(key) {
+				expected++
+			}
+		}
+		assert.Equal(t, expected, big.CountContained(keys[:n]), "%d keys", n)
+	}
+	assert.True(t, filter.CountContained(keys) >= MID_NUM_KEYS/2)
+	allocs := testing.AllocsPerRun(10, func() {
+		filter.CountContained(keys)
+	})
+	assert.Equal(t, 0.0, allocs)
+}
+
+func TestBinaryFuse8Contains4Contains8(t *testing.T) {
+	keys := make([]uint64, MID_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, err := PopulateBinaryFuse8(keys)
+	assert.Equal(t, nil, err)
+	for i := 0; i+8 <= len(keys); i += 8 {
+		var k8 [8]uint64
+		copy(k8[:], keys[i:])
+		k8[7] = rand.Uint64()
+		out8 := filter.Contains8(k8)
+		var k4 [4]uint64
+		copy(k4[:], k8[4:])
+		out4 := filter.Contains4(k4)
+		for j := range k8 {
+			assert.Equal(t, filter.Contains(k8[j]), out8[j])
+		}
+		for j := range k4 {
+			assert.Equal(t, filter.Contains(k4[j]), out4[j])
+		}
+	}
+}
+
+func BenchmarkBinaryFuse8Contains8_50000000(b *testing.B) {
+	keys := make([]uint64, 50000000)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, _ := PopulateBinaryFuse8(keys)
+	b.ResetTimer()
+	for n := 0; n < b.N; n += 8 {
+		var k8 [8]uint64
+		copy(k8[:], keys[n%(len(keys)-8):])
+		filter.Contains8(k8)
+	}
+}
+
+func TestBinaryFuse8LowMemory(t *testing.T) {
+	keys := make([]uint64, MID_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	expected, err := PopulateBinaryFuse8(keys)
+	assert.Equal(t, nil, err)
+	var stats, lowStats BuildStats
+	_, err = PopulateBinaryFuse8(keys, WithStats(&stats))
+	assert.Equal(t, nil, err)
+	filter, err := PopulateBinaryFuse8(keys, WithLowMemory(), WithStats(&lowStats))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, expected, filter)
+	assert.True(t, lowStats.ScratchBytes < stats.ScratchBytes)
+	assert.Equal(t, lowStats.ScratchBytes, EstimateBinaryFuse8Memory(uint64(len(keys)), WithLowMemory()).ScratchBytes)
+
+	data := make([]byte, 8*len(keys))
+	for i, k := range keys {
+		binary.LittleEndian.PutUint64(data[8*i:], k)
+	}
+	filter, err = PopulateBinaryFuse8FromReader(bytes.NewReader(data), len(keys), WithLowMemory())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, expected, filter)
+
+	sorted := append([]uint64{}, keys...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	filter, err = PopulateBinaryFuse8(sorted, WithLowMemory(), WithSortedUniqueInput())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, expected, filter)
+
+	dups := append(append([]uint64{}, keys...), keys[:SMALL_NUM_KEYS]...)
+	filter, err = PopulateBinaryFuse8(dups, WithLowMemory())
+	assert.Equal(t, nil, err)
+	for _, v := range keys {
+		assert.Equal(t, true, filter.Contains(v))
+	}
+}
+
+func TestBinaryFuse8External(t *testing.T) {
+	keys := make([]uint64, 10*MID_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	data := make([]byte, 8*len(keys))
+	for i, k := range keys {
+		binary.LittleEndian.PutUint64(data[8*i:], k)
+	}
+	dir, err := ioutil.TempDir("", "xorfilter")
+	assert.Equal(t, nil, err)
+	defer os.RemoveAll(dir)
+	filter, err := PopulateBinaryFuse8External(bytes.NewReader(data), uint64(len(keys)), dir, 1<<20)
+	assert.Equal(t, nil, err)
+	assert.True(t, len(filter.Shards) > 1)
+	assert.Equal(t, nil, filter.Validate())
+	for _, v := range keys {
+		assert.Equal(t, true, filter.Contains(v))
+	}
+	files, err := ioutil.ReadDir(dir)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(files))
+
+	_, err = PopulateBinaryFuse8External(bytes.NewReader(data[:len(data)-1]), uint64(len(keys)), dir, 1<<20)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	_, err = PopulateBinaryFuse8External(bytes.NewReader(data), uint64(len(keys)), dir, 1<<10)
+	assert.Equal(t, ErrScratchTooSmall, err)
+	files, err = ioutil.ReadDir(dir)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(files))
+}
+
+func TestBinaryFuse8FromFunc(t *testing.T) {
+	keys := make([]uint64, MID_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	calls := 0
+	emitKeys := func(keys []uint64) func(emit func([]uint64) error) error {
+		return func(emit func([]uint64) error) error {
+			calls++
+			buf := make([]uint64, 100)
+			for i := 0; i < len(keys); i += len(buf) {
+				n := copy(buf, keys[i:])
+				if err := emit(buf[:n]); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	expected, err := PopulateBinaryFuse8(keys)
+	assert.Equal(t, nil, err)
+	for _, opts := range [][]Option{nil, {WithLowMemory()}, {WithVerifyKeys()}} {
+		filter, err := PopulateBinaryFuse8FromFunc(len(keys), emitKeys(keys), opts...)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, expected, filter)
+	}
+	assert.True(t, calls >= 3)
+
+	dups := append(append([]uint64{}, keys...), keys[:SMALL_NUM_KEYS]...)
+	filter, err := PopulateBinaryFuse8FromFunc(len(dups), emitKeys(dups), WithLowMemory())
+	assert.Equal(t, nil, err)
+	for _, v := range keys {
+		assert.Equal(t, true, filter.Contains(v))
+	}
+
+	_, err = PopulateBinaryFuse8FromFunc(len(keys)-1, emitKeys(keys))
+	assert.NotNil(t, err)
+	_, err = PopulateBinaryFuse8FromFunc(len(keys)+1, emitKeys(keys))
+	assert.NotNil(t, err)
+	failing := errors.New("failing")
+	_, err = PopulateBinaryFuse8FromFunc(len(keys), func(emit func([]uint64) error) error {
+		return failing
+	})
+	assert.Equal(t, failing, err)
+	_, err = PopulateBinaryFuse8FromFunc(len(keys), emitKeys(keys), WithSortedUniqueInput())
+	assert.Equal(t, ErrNotSortedUnique, err)
+}
+
+func TestBinaryFuse8Alignment(t *testing.T) {
+	keys := make([]uint64, MID_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	aligned := func(fingerprints []uint8, align uintptr) bool {
+		return uintptr(unsafe.Pointer(&fingerprints[0]))%align == 0
+	}
+	filter, err := PopulateBinaryFuse8(keys)
+	assert.Equal(t, nil, err)
+	assert.True(t, aligned(filter.Fingerprints, 64))
+	assert.True(t, aligned(filter.Clone().Fingerprints, 64))
+	data, _ := filter.MarshalBinary()
+	var decoded BinaryFuse8
+	assert.Equal(t, nil, decoded.UnmarshalBinary(data))
+	assert.True(t, aligned(decoded.Fingerprints, 64))
+	xor, err := Populate(keys)
+	assert.Equal(t, nil, err)
+	assert.True(t, aligned(xor.Fingerprints, 64))
+
+	filter, err = PopulateBinaryFuse8(keys[:SMALL_NUM_KEYS], WithAlignment(4096))
+	assert.Equal(t, nil, err)
+	assert.True(t, aligned(filter.Fingerprints, 4096))
+	for _, v := range keys[:SMALL_NUM_KEYS] {
+		assert.Equal(t, true, filter.Contains(v))
+	}
+	_, err = PopulateBinaryFuse8(keys, WithAlignment(100))
+	assert.NotNil(t, err)
+}
+
+func TestBinaryFuse8HugePages(t *testing.T) {
+	keys := make([]uint64, 2000000)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, err := PopulateBinaryFuse8(keys, WithHugePages())
+	assert.Equal(t, nil, err)
+	expected, err := PopulateBinaryFuse8(keys)
+	assert.Equal(t, nil, err)
+	assert.True(t, filter.Equal(expected))
+	assert.True(t, filter.ContainsAll(keys))
+	if runtime.GOOS == "linux" && (runtime.GOARCH == "amd64" || runtime.GOARCH == "arm64") {
+		assert.True(t, filter.mapping != nil)
+	}
+	clone := filter.Clone()
+	filter = nil
+	runtime.GC()
+	assert.True(t, clone.Equal(expected))
+	small, err := PopulateBinaryFuse8(keys[:SMALL_NUM_KEYS], WithHugePages())
+	assert.Equal(t, nil, err)
+	assert.True(t, small.mapping == nil)
+}
+
+func TestBinaryFuse8ContainsBatchBits(t *testing.T) {
+	keys := make([]uint64, MID_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, err := PopulateBinaryFuse8(keys)
+	assert.Equal(t, nil, err)
+	queries := append(append([]uint64{}, keys[:500]...), keys[:500]...)
+	for i := 500; i < len(queries); i++ {
+		queries[i] = rand.Uint64()
+	}
+	for _, n := range []int{0, 1, 63, 64, 65, len(queries)} {
+		out := make([]uint64, (n+63)/64)
+		for i := range out {
+			out[i] = ^uint64(0)
+		}
+		filter.ContainsBatchBits(queries[:n], out)
+		for i := 0; i < 64*len(out); i++ {
+			expected := i < n && filter.Contains(queries[i])
+			assert.Equal(t, expected, out[i/64]&(1<<uint(i%64)) != 0)
+		}
+	}
+	assert.Panics(t, func() { filter.ContainsBatchBits(queries, make([]uint64, 1)) })
+}
+
+func TestBinaryFuse8BlockBits(t *testing.T) {
+	keys := make([]uint64, MID_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	expected, err := PopulateBinaryFuse8(keys)
+	assert.Equal(t, nil, err)
+	for _, bits := range []int{1, 4, 12} {
+		filter, err := PopulateBinaryFuse8(keys, WithBlockBits(bits))
+		assert.Equal(t, nil, err)
+		assert.Equal(t, expected, filter)
+	}
+	_, err = PopulateBinaryFuse8(keys, WithBlockBits(21))
+	assert.NotNil(t, err)
+	assert.True(t, l2CacheSize() > 0)
+}
+
+func TestBinaryFuse8ScratchBuffer(t *testing.T) {
+	keys := make([]uint64, MID_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	expected, err := PopulateBinaryFuse8(keys)
+	assert.Equal(t, nil, err)
+	for _, opts := range [][]Option{nil, {WithLowMemory()}, {WithParallelism(4)}} {
+		need := EstimateBinaryFuse8Memory(uint64(len(keys)), opts...).ScratchBytes
+		buf := make([]byte, need+8)
+		var p Populator
+		filter, err := p.PopulateBinaryFuse8(keys, append(opts, WithScratchBuffer(buf[1:]))...)
+		assert.Equal(t, nil, err)
+		assert.Nil(t, p.alone)
+		for _, v := range keys {
+			assert.True(t, filter.Contains(v))
+		}
+		if opts == nil {
+			assert.Equal(t, expected, filter)
+		}
+		_, err = PopulateBinaryFuse8(keys, append(opts, WithScratchBuffer(buf[:need/2]))...)
+		assert.Equal(t, ErrScratchTooSmall, err)
+	}
+}
+
+func TestBinaryFuse8ContainsUnchecked(t *testing.T) {
+	keys := make([]uint64, NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, err := PopulateBinaryFuse8(keys)
+	assert.Equal(t, nil, err)
+	for _, v := range keys {
+		assert.True(t, filter.ContainsUnchecked(v))
+	}
+	for i := 0; i < 100000; i++ {
+		v := rand.Uint64()
+		assert.Equal(t, filter.Contains(v), filter.ContainsUnchecked(v))
+	}
+}
+
+func BenchmarkBinaryFuse8ContainsUnchecked1000000(b *testing.B) {
+	keys := make([]uint64, NUM_KEYS, NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, _ := PopulateBinaryFuse8(keys)
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		filter.ContainsUnchecked(keys[n%len(keys)])
+	}
+}
+
+func TestContainsParallel(t *testing.T) {
+	keys := make([]uint64, MID_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	fuse, err := PopulateBinaryFuse8(keys)
+	assert.Equal(t, nil, err)
+	xor, err := Populate(keys)
+	assert.Equal(t, nil, err)
+	queries := make([]uint64, 2*len(keys)+5)
+	copy(queries, keys)
+	for i := len(keys); i < len(queries); i++ {
+		queries[i] = rand.Uint64()
+	}
+	for _, filter := range []Filter{fuse, xor} {
+		for _, workers := range []int{0, 1, 3, 16} {
+			out := make([]bool, len(queries))
+			ContainsParallel(filter, queries, out, workers)
+			for i, v := range queries {
+				assert.Equal(t, filter.Contains(v), out[i])
+			}
+		}
+	}
+}
+
+func TestViewBinaryFuse8(t *testing.T) {
+	keys := make([]uint64, NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, err := PopulateBinaryFuse8(keys)
+	assert.Equal(t, nil, err)
+	data, err := filter.MarshalBinary()
+	assert.Equal(t, nil, err)
+	view, err := ViewBinaryFuse8(data)
+	assert.Equal(t, nil, err)
+	assert.True(t, &view.Fingerprints[0] == &data[binaryFuse8HeaderSize])
+	for _, v := range keys {
+		assert.True(t, view.Contains(v))
+	}
+	_, err = ViewBinaryFuse8(data[:len(data)-1])
+	assert.True(t, errors.Is(err, ErrInvalidFilter))
+	_, err = ViewBinaryFuse8(append(data, 0))
+	assert.True(t, errors.Is(err, ErrInvalidFilter))
+}
+
+// TestBinaryFuse8Parameters checks that the parameters computed with integers
+// are those of the floating-point formulas of the reference implementation,
+// and freezes a few of them.
+func TestBinaryFuse8Parameters(t *testing.T) {
+	segmentLength := func(size uint32) uint32 {
+		return uint32(1) << int(math.Floor(math.Log(float64(size))/math.Log(3.33)+2.25))
+	}
+	capacity := func(size uint32) uint32 {
+		sizeFactor := math.Max(1.125, 0.875+0.25*math.Log(1000000)/math.Log(float64(size)))
+		return uint32(math.Round(float64(size) * sizeFactor))
+	}
+	check := func(size uint32) {
+		if calculateSegmentLength(size) != segmentLength(size) || calculateCapacity(size) != capacity(size) {
+			t.Fatalf("size %d: %d slots in segments of %d instead of %d in segments of %d", size,
+				calculateCapacity(size), calculateSegmentLength(size), capacity(size), segmentLength(size))
+		}
+	}
+	for size := uint32(tinySize); size < 2000000; size++ {
+		check(size)
+	}
+	for size := uint64(2000000); size < 1<<32; size += 999983 {
+		check(uint32(size))
+	}
+	for _, threshold := range segmentLengthThresholds {
+		check(threshold - 1)
+		check(threshold)
+	}
+	for _, c := range []struct{ size, segmentLength, capacity uint32 }{
+		{8, 8, 20},
+		{100, 64, 163},
+		{1000, 128, 1375},
+		{1000000, 8192, 1125000},
+		{MaxBinaryFuse8Keys, 1048576, 3623878656},
+	} {
+		assert.Equal(t, c.segmentLength, calculateSegmentLength(c.size))
+		assert.Equal(t, c.capacity, calculateCapacity(c.size))
+	}
+}
+
+func TestBuildMany(t *testing.T) {
+	keySets := make([][]uint64, 20)
+	for i := range keySets {
+		keySets[i] = make([]uint64, rand.Intn(SMALL_NUM_KEYS))
+		for j := range keySets[i] {
+			keySets[i][j] = rand.Uint64()
+		}
+	}
+	keySets[7] = append(keySets[7], 1, 1)
+	for _, parallelism := range []int{0, 1, 4, 100} {
+		filters, errs := BuildMany(keySets, parallelism, WithDuplicateCheck())
+		assert.Equal(t, len(keySets), len(filters))
+		for i, keys := range keySets {
+			if i == 7 {
+				assert.Nil(t, filters[i])
+				assert.Equal(t, ErrDuplicateKeys, errs[i])
+				continue
+			}
+			assert.Equal(t, nil, errs[i])
+			expected, _ := PopulateBinaryFuse8(keys)
+			assert.Equal(t, expected, filters[i])
+		}
+	}
+	filters, errs := BuildMany(nil, 0)
+	assert.Equal(t, 0, len(filters))
+	assert.Equal(t, 0, len(errs))
+}
+
+func TestBinaryFuse8MaxScratchMemory(t *testing.T) {
+	keys := make([]uint64, MID_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	var stats BuildStats
+	expected, err := PopulateBinaryFuse8(keys, WithStats(&stats))
+	assert.Equal(t, nil, err)
+	need := stats.ScratchBytes
+	filter, err := PopulateBinaryFuse8(keys, WithMaxScratchMemory(need), WithStats(&stats))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, expected, filter)
+	assert.Equal(t, need, stats.ScratchBytes)
+	filter, err = PopulateBinaryFuse8(keys, WithMaxScratchMemory(need-1), WithStats(&stats))
+	assert.Equal(t, nil, err)
+	assert.True(t, stats.ScratchBytes < need)
+	for _, v := range keys {
+		assert.True(t, filter.Contains(v))
+	}
+	_, err = PopulateBinaryFuse8(keys, WithMaxScratchMemory(need/4))
+	assert.Equal(t, ErrScratchTooSmall, err)
+}
+
+func TestZeroBytes(t *testing.T) {
+	for i := 0; i < 100000; i++ {
+		x := rand.Uint64()
+		// Clear random bytes, and set others to 0x01 or 0x80, the
+		// bytes that trip the usual test next to a zero byte.
+		for j := uint(0); j < 64; j += 8 {
+			switch rand.Intn(4) {
+			case 0:
+				x &^= 0xff << j
+			case 1:
+				x = x&^(0xff<<j) | 0x01<<j
+			case 2:
+				x = x&^(0xff<<j) | 0x80<<j
+			}
+		}
+		expected := uint64(0)
+		for j := uint(0); j < 64; j += 8 {
+			if x>>j&0xff == 0 {
+				expected |= 1 << j
+			}
+		}
+		assert.Equal(t, expected, zeroBytes(x))
+	}
+}
+
+func TestBinaryFuse8RetryCachedKeys(t *testing.T) {
+	keys := make([]string, MID_NUM_KEYS)
+	hashes := make([]uint64, len(keys))
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+		hashes[i] = hashString(keys[i], stringSeed)
+	}
+	// A tight filter takes several attempts.
+	var stats BuildStats
+	filter, err := PopulateBinaryFuse8FromStrings(keys, WithSizeFactor(1.2), WithStats(&stats))
+	assert.Equal(t, nil, err)
+	assert.True(t, stats.Iterations > 1)
+	expected, err := PopulateBinaryFuse8(hashes, WithSizeFactor(1.2))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, expected, filter)
+	for _, key := range keys {
+		assert.True(t, filter.ContainsString(key))
+	}
+}
+
+func TestBinaryFuse8HashKeys(t *testing.T) {
+	keys := make([]uint64, 100)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	detected := batchKernel
+	defer func() { batchKernel = detected }()
+	for kernel := kernelGeneric; kernel <= detected; kernel++ {
+		batchKernel = kernel
+		for _, filter := range []*BinaryFuse8{{Seed: rand.Uint64()}, {Seed: rand.Uint64(), hasher: HasherFunc(mixsplit)}} {
+			for n := 0; n <= len(keys); n += 1 + n/4 {
+				hashes := make([]uint64, n+1)
+				filter.hashKeys(hashes, keys[:n])
+				for i, key := range keys[:n] {
+					assert.Equal(t, filter.hash(key), hashes[i])
+				}
+				assert.Equal(t, uint64(0), hashes[n])
+			}
+		}
+	}
+}
+
+func TestPopulateBinaryFuse8Race(t *testing.T) {
+	keys := make([]uint64, MID_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	for _, k := range []int{0, 1, 4} {
+		var stats BuildStats
+		filter, err := PopulateBinaryFuse8Race(context.Background(), keys, k, WithStats(&stats))
+		assert.Equal(t, nil, err)
+		assert.True(t, filter.ContainsAll(keys))
+		assert.True(t, stats.Iterations >= 1)
+		if k == 1 {
+			expected, _ := PopulateBinaryFuse8(keys)
+			assert.Equal(t, expected, filter)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := PopulateBinaryFuse8Race(ctx, keys, 4)
+	assert.Equal(t, context.Canceled, err)
+	_, err = PopulateBinaryFuse8Race(context.Background(), append(keys, keys[0]), 4, WithDuplicateCheck())
+	assert.Equal(t, ErrDuplicateKeys, err)
+}
+
+func TestHashBytes(t *testing.T) {
+	keys := [][]byte{[]byte("a"), []byte("bb"), []byte("a somewhat longer key than the others")}
+	hashes := make([]uint64, len(keys))
+	for i, key := range keys {
+		hashes[i] = HashBytes(key)
+	}
+	filter, err := PopulateBinaryFuse8(hashes)
+	assert.Equal(t, nil, err)
+	expected, _ := PopulateBinaryFuse8FromBytes(keys)
+	for _, key := range keys {
+		assert.True(t, filter.ContainsBytes(key))
+		assert.True(t, expected.ContainsBytes(key))
+	}
+}
+
+func TestContainsSafe(t *testing.T) {
+	keys := make([]uint64, SMALL_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, err := PopulateBinaryFuse8(keys)
+	assert.Equal(t, nil, err)
+	xor, err := Populate(keys)
+	assert.Equal(t, nil, err)
+	fuse, err := PopulateFuse8(keys)
+	assert.Equal(t, nil, err)
+	big, err := populateBinaryFuse8Big(context.Background(), &sliceSource{keys: keys}, uint64(len(keys)), 1000, newBuildConfig(nil))
+	assert.Equal(t, nil, err)
+	for i := 0; i < 100000; i++ {
+		key := rand.Uint64()
+		if i < len(keys) {
+			key = keys[i]
+		}
+		assert.Equal(t, filter.Contains(key), filter.ContainsSafe(key))
+		assert.Equal(t, xor.Contains(key), xor.ContainsSafe(key))
+		assert.Equal(t, fuse.Contains(key), fuse.ContainsSafe(key))
+		assert.Equal(t, big.Contains(key), big.ContainsSafe(key))
+	}
+
+	// Whatever the fields, the queries do not panic.
+	corrupted := []*BinaryFuse8{
+		{},
+		{SegmentLength: 1 << 31, SegmentLengthMask: math.MaxUint32, SegmentCountLength: math.MaxUint32, Fingerprints: make([]uint8, 10)},
+		{SegmentLength: filter.SegmentLength, SegmentLengthMask: filter.SegmentLengthMask, SegmentCountLength: filter.SegmentCountLength, Fingerprints: filter.Fingerprints[:100]},
+	}
+	for _, bad := range corrupted {
+		for _, key := range keys {
+			bad.ContainsSafe(key)
+		}
+	}
+	for _, bad := range []*Xor8{{}, {BlockLength: math.MaxUint32, Fingerprints: make([]uint8, 10)}} {
+		for _, key := range keys {
+			bad.ContainsSafe(key)
+		}
+	}
+	for _, bad := range []*Fuse8{{}, {SegmentLength: math.MaxUint32, Fingerprints: make([]uint8, 10)}} {
+		for _, key := range keys {
+			bad.ContainsSafe(key)
+		}
+	}
+	assert.False(t, new(BinaryFuse8Big).ContainsSafe(keys[0]))
+}
+
+func TestContainsConstantTime(t *testing.T) {
+	keys := make([]uint64, SMALL_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, err := PopulateBinaryFuse8(keys)
+	assert.Equal(t, nil, err)
+	for _, key := range keys {
+		assert.True(t, filter.ContainsConstantTime(key))
+	}
+	for i := 0; i < 100000; i++ {
+		key := rand.Uint64()
+		assert.Equal(t, filter.Contains(key), filter.ContainsConstantTime(key))
+	}
+	assert.True(t, filter.ContainsConstantTime(HashBytes([]byte("absent"))) == filter.ContainsBytes([]byte("absent")))
+}
+
+func TestNoPanics(t *testing.T) {
+	for name, filter := range map[string]*BinaryFuse8{"zero": {}, "nil": nil} {
+		t.Run("BinaryFuse8/"+name, func(t *testing.T) {
+			check := func(what string, fn func()) {
+				assert.NotPanics(t, fn, what)
+			}
+			check("Contains", func() { assert.False(t, filter.Contains(1)) })
+			check("ContainsUnchecked", func() { assert.False(t, filter.ContainsUnchecked(1)) })
+			check("ContainsConstantTime", func() { assert.False(t, filter.ContainsConstantTime(1)) })
+			check("ContainsSafe", func() { assert.False(t, filter.ContainsSafe(1)) })
+			check("ContainsUint32", func() { assert.False(t, filter.ContainsUint32(1)) })
+			check("ContainsString", func() { assert.False(t, filter.ContainsString("a")) })
+			check("ContainsBytes", func() { assert.False(t, filter.ContainsBytes(nil)) })
+			check("ContainsHashed", func() { assert.False(t, filter.ContainsHashed(1)) })
+			check("Contains128", func() { assert.False(t, filter.Contains128([16]byte{})) })
+			check("ContainsUint128", func() { assert.False(t, filter.ContainsUint128(1, 2)) })
+			check("ContainsBatch", func() {
+				out := []bool{true, true}
+				filter.ContainsBatch([]uint64{1, 2}, out)
+				assert.Equal(t, []bool{false, false}, out)
+			})
+			check("ContainsBatchBits", func() {
+				out := []uint64{1}
+				filter.ContainsBatchBits([]uint64{1, 2}, out)
+				assert.Equal(t, []uint64{0}, out)
+			})
+			check("ContainsAll", func() { assert.False(t, filter.ContainsAll([]uint64{1})) })
+			check("ContainsAny", func() { assert.False(t, filter.ContainsAny([]uint64{1})) })
+			check("CountContained", func() { assert.Equal(t, 0, filter.CountContained([]uint64{1, 2})) })
+			check("Contains4", func() { filter.Contains4([4]uint64{}) })
+			check("Contains8", func() { filter.Contains8([8]uint64{}) })
+			check("ContainsParallel", func() {
+				ContainsParallel(filter, make([]uint64, 100000), make([]bool, 100000), 4)
+			})
+			check("SizeInBytes", func() { filter.SizeInBytes() })
+			check("MemoryBytes", func() { filter.MemoryBytes() })
+			check("BitsPerEntry", func() { filter.BitsPerEntry(0) })
+			check("FalsePositiveRate", func() { filter.FalsePositiveRate() })
+			check("Clone", func() { filter.Clone() })
+			check("Equal", func() { filter.Equal(&BinaryFuse8{}); filter.Equal(nil) })
+			check("String", func() { _ = filter.String() })
+			check("Dump", func() { filter.Dump(ioutil.Discard) })
+			check("Validate", func() { assert.NotNil(t, filter.Validate()) })
+			check("MarshalBinary", func() { filter.MarshalBinary() })
+			check("UnmarshalBinary", func() { assert.NotNil(t, filter.UnmarshalBinary(nil)) })
+			check("Hasher", func() { filter.Hasher() })
+		})
+	}
+	for name, filter := range map[string]*BinaryFuse8Big{"zero": {}, "nil": nil} {
+		t.Run("BinaryFuse8Big/"+name, func(t *testing.T) {
+			check := func(what string, fn func()) {
+				assert.NotPanics(t, fn, what)
+			}
+			check("Contains", func() { assert.False(t, filter.Contains(1)) })
+			check("ContainsSafe", func() { assert.False(t, filter.ContainsSafe(1)) })
+			check("ContainsAll", func() { assert.False(t, filter.ContainsAll([]uint64{1})) })
+			check("ContainsAny", func() { assert.False(t, filter.ContainsAny([]uint64{1})) })
+			check("CountContained", func() { assert.Equal(t, 0, filter.CountContained([]uint64{1, 2})) })
+			check("SizeInBytes", func() { filter.SizeInBytes() })
+			check("MemoryBytes", func() { filter.MemoryBytes() })
+			check("BitsPerEntry", func() { filter.BitsPerEntry(0) })
+			check("FalsePositiveRate", func() { filter.FalsePositiveRate() })
+			check("Clone", func() { filter.Clone() })
+			check("Equal", func() { filter.Equal(&BinaryFuse8Big{}); filter.Equal(nil) })
+			check("String", func() { _ = filter.String() })
+			check("Dump", func() { filter.Dump(ioutil.Discard) })
+			check("Validate", func() { assert.NotNil(t, filter.Validate()) })
+			check("MarshalBinary", func() { filter.MarshalBinary() })
+			check("UnmarshalBinary", func() { assert.NotNil(t, filter.UnmarshalBinary(nil)) })
+		})
+	}
+	for name, filter := range map[string]*Xor8{"zero": {}, "nil": nil} {
+		t.Run("Xor8/"+name, func(t *testing.T) {
+			check := func(what string, fn func()) {
+				assert.NotPanics(t, fn, what)
+			}
+			check("Contains", func() { assert.False(t, filter.Contains(1)) })
+			check("ContainsSafe", func() { assert.False(t, filter.ContainsSafe(1)) })
+			check("SizeInBytes", func() { filter.SizeInBytes() })
+			check("MemoryBytes", func() { filter.MemoryBytes() })
+			check("BitsPerEntry", func() { filter.BitsPerEntry(0) })
+			check("FalsePositiveRate", func() { filter.FalsePositiveRate() })
+			check("Clone", func() { filter.Clone() })
+			check("Equal", func() { filter.Equal(&Xor8{}); filter.Equal(nil) })
+			check("String", func() { _ = filter.String() })
+			check("Validate", func() { assert.NotNil(t, filter.Validate()) })
+			check("MarshalBinary", func() { filter.MarshalBinary() })
+			check("UnmarshalBinary", func() { assert.NotNil(t, filter.UnmarshalBinary(nil)) })
+		})
+	}
+	for name, filter := range map[string]*Fuse8{"zero": {}, "nil": nil} {
+		t.Run("Fuse8/"+name, func(t *testing.T) {
+			check := func(what string, fn func()) {
+				assert.NotPanics(t, fn, what)
+			}
+			check("Contains", func() { assert.False(t, filter.Contains(1)) })
+			check("ContainsSafe", func() { assert.False(t, filter.ContainsSafe(1)) })
+			check("SizeInBytes", func() { filter.SizeInBytes() })
+			check("MemoryBytes", func() { filter.MemoryBytes() })
+			check("BitsPerEntry", func() { filter.BitsPerEntry(0) })
+			check("FalsePositiveRate", func() { filter.FalsePositiveRate() })
+			check("Clone", func() { filter.Clone() })
+			check("Equal", func() { filter.Equal(&Fuse8{}); filter.Equal(nil) })
+			check("String", func() { _ = filter.String() })
+			check("Validate", func() { assert.NotNil(t, filter.Validate()) })
+			check("MarshalBinary", func() { filter.MarshalBinary() })
+			check("UnmarshalBinary", func() { assert.NotNil(t, filter.UnmarshalBinary(nil)) })
+		})
+	}
+
+	t.Run("Populate", func(t *testing.T) {
+		check := func(what string, fn func() (Filter, error)) {
+			assert.NotPanics(t, func() {
+				filter, err := fn()
+				if assert.Equal(t, nil, err, what) {
+					assert.False(t, filter.Contains(1), what)
+				}
+			}, what)
+		}
+		check("PopulateBinaryFuse8", func() (Filter, error) { return PopulateBinaryFuse8(nil) })
+		check("PopulateBinaryFuse8Ctx", func() (Filter, error) { return PopulateBinaryFuse8Ctx(nil, nil) })
+		check("PopulateBinaryFuse8FromReader", func() (Filter, error) { return PopulateBinaryFuse8FromReader(nil, 0) })
+		check("PopulateBinaryFuse8FromUint32", func() (Filter, error) { return PopulateBinaryFuse8FromUint32(nil) })
+		check("PopulateBinaryFuse8FromStrings", func() (Filter, error) { return PopulateBinaryFuse8FromStrings(nil) })
+		check("PopulateBinaryFuse8FromBytes", func() (Filter, error) { return PopulateBinaryFuse8FromBytes(nil) })
+		check("PopulateBinaryFuse8From128", func() (Filter, error) { return PopulateBinaryFuse8From128(nil) })
+		check("PopulateBinaryFuse8Hashed", func() (Filter, error) { return PopulateBinaryFuse8Hashed(nil) })
+		check("PopulateBinaryFuse8Big", func() (Filter, error) { return PopulateBinaryFuse8Big(nil) })
+		check("PopulateBinaryFuse8External", func() (Filter, error) {
+			return PopulateBinaryFuse8External(bytes.NewReader(nil), 0, t.TempDir(), 1<<20)
+		})
+		check("PopulateBinaryFuse8Race", func() (Filter, error) { return PopulateBinaryFuse8Race(context.Background(), nil, 0) })
+		check("Populate", func() (Filter, error) { return Populate(nil) })
+		check("PopulateFuse8", func() (Filter, error) { return PopulateFuse8(nil) })
+		check("nil option", func() (Filter, error) { return PopulateBinaryFuse8([]uint64{1}[:0], nil) })
+
+		assert.NotPanics(t, func() {
+			_, err := PopulateBinaryFuse8FromReader(nil, 1)
+			assert.NotNil(t, err)
+			_, err = PopulateBinaryFuse8FromFunc(0, nil)
+			assert.NotNil(t, err)
+			_, err = PopulateBinaryFuse8External(nil, 1, t.TempDir(), 1<<20)
+			assert.NotNil(t, err)
+			_, err = ViewBinaryFuse8(nil)
+			assert.NotNil(t, err)
+			filters, errs := BuildMany([][]uint64{nil, {}}, 0)
+			assert.Equal(t, 2, len(filters))
+			assert.Equal(t, []error{nil, nil}, errs)
+			_, _, err = NewOptimalFilter(nil, 0)
+			assert.NotNil(t, err)
+			_, err = NewRollingFilter(0, 0)
+			assert.NotNil(t, err)
+			EstimateBinaryFuse8Memory(0)
+			EstimateBinaryFuse8Memory(1 << 62)
+			HashBytes(nil)
+		})
+	})
+}
+
+func TestFreeze(t *testing.T) {
+	keys := make([]uint64, SMALL_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, err := PopulateBinaryFuse8(keys)
+	assert.Equal(t, nil, err)
+	data, err := filter.MarshalBinary()
+	assert.Equal(t, nil, err)
+	view, err := ViewBinaryFuse8(data)
+	assert.Equal(t, nil, err)
+	frozen, err := view.Freeze()
+	assert.Equal(t, nil, err)
+	var _ Filter = frozen
+
+	// The frozen filter shares nothing with the view: the data it was
+	// decoded from is overwritten while the frozen filter is queried, which
+	// the race detector would report otherwise.
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, key := range keys {
+				if !frozen.Contains(key) {
+					t.Errorf("key %d is missing", key)
+					return
+				}
+			}
+		}()
+	}
+	for i := range data {
+		data[i] = 0
+	}
+	wg.Wait()
+	assert.False(t, view.Equal(filter))
+	assert.True(t, frozen.BinaryFuse8().Equal(filter))
+	encoded, err := frozen.MarshalBinary()
+	assert.Equal(t, nil, err)
+	decoded, err := ViewBinaryFuse8(encoded)
+	assert.Equal(t, nil, err)
+	assert.True(t, decoded.Equal(filter))
+
+	names, err := PopulateBinaryFuse8FromStrings([]string{"a", "b"})
+	assert.Equal(t, nil, err)
+	frozenNames, err := names.Freeze()
+	assert.Equal(t, nil, err)
+	assert.True(t, frozenNames.ContainsString("a"))
+	assert.True(t, frozenNames.ContainsBytes([]byte("b")))
+
+	_, err = (&BinaryFuse8{}).Freeze()
+	assert.True(t, errors.Is(err, ErrInvalidFilter))
+}
+
+func TestTestVectors(t *testing.T) {
+	golden, err := ioutil.ReadFile("testdata/vectors.json")
+	assert.NoError(t, err)
+	vectors, err := ReadTestVectors(bytes.NewReader(golden))
+	assert.NoError(t, err)
+	canonical, err := CanonicalTestVectors()
+	assert.NoError(t, err)
+	assert.Equal(t, len(canonical), len(vectors))
+	for i, v := range vectors {
+		assert.NoError(t, v.Verify())
+		assert.Equal(t, canonical[i].Name, v.Name)
+		assert.True(t, canonical[i].Filter.Equal(&v.Filter), v.Name)
+		for _, key := range v.Keys {
+			assert.True(t, v.Filter.Contains(key))
+		}
+		for _, key := range v.Strings {
+			assert.True(t, v.Filter.ContainsString(key))
+		}
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, WriteTestVectors(&buf, canonical))
+	assert.Equal(t, string(golden), buf.String(), "testdata/vectors.json is out of date: go run ./cmd/xorfilter vectors > testdata/vectors.json")
+
+	v := vectors[len(vectors)-1]
+	v.Filter.Fingerprints[3] ^= 1
+	assert.Error(t, v.Verify())
+	v.Filter.Fingerprints[3] ^= 1
+	v.Filter.Seed++
+	assert.Error(t, v.Verify())
+
+	_, err = ReadTestVectors(strings.NewReader(`[{"name": "x", "seed": "zz"}]`))
+	assert.Error(t, err)
+	_, err = ReadTestVectors(strings.NewReader(`[{"name": "x", "rng_counter": "1", "seed": "1", "fingerprints": "00"}]`))
+	assert.True(t, errors.Is(err, ErrInvalidFilter))
+}
+
+func TestAlgorithmVersion(t *testing.T) {
+	keys := make([]uint64, 100000)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, err := PopulateBinaryFuse8(keys)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultAlgorithm, filter.AlgorithmVersion())
+	v1, err := PopulateBinaryFuse8(keys, WithAlgorithmVersion(AlgorithmV1))
+	assert.NoError(t, err)
+	assert.True(t, v1.Equal(filter))
+
+	// The version survives the binary form, which rejects the versions
+	// that this release does not know.
+	data, err := filter.MarshalBinary()
+	assert.NoError(t, err)
+	var decoded BinaryFuse8
+	assert.NoError(t, decoded.UnmarshalBinary(data))
+	assert.Equal(t, AlgorithmV1, decoded.AlgorithmVersion())
+	assert.True(t, decoded.Equal(filter))
+	view, err := ViewBinaryFuse8(data)
+	assert.NoError(t, err)
+	assert.Equal(t, AlgorithmV1, view.AlgorithmVersion())
+	data[24] = 0
+	assert.NoError(t, decoded.UnmarshalBinary(data))
+	assert.Equal(t, AlgorithmVersion(0), decoded.AlgorithmVersion())
+	data[24] = 3
+	err = decoded.UnmarshalBinary(data)
+	assert.True(t, errors.Is(err, ErrInvalidFilter))
+	_, err = ViewBinaryFuse8(data)
+	assert.True(t, errors.Is(err, ErrInvalidFilter))
+	data[24] = 1
+	data[27] = 1
+	assert.True(t, errors.Is(decoded.UnmarshalBinary(data), ErrInvalidFilter))
+
+	// With AlgorithmV2, the filter does not depend on the parallelism.
+	v2, err := PopulateBinaryFuse8(keys, WithAlgorithmVersion(AlgorithmV2))
+	assert.NoError(t, err)
+	assert.Equal(t, AlgorithmV2, v2.AlgorithmVersion())
+	assert.False(t, v2.Equal(v1))
+	parallel, err := PopulateBinaryFuse8(keys, WithAlgorithmVersion(AlgorithmV2), WithParallelism(4))
+	assert.NoError(t, err)
+	assert.True(t, parallel.Equal(v2))
+	low := EstimateBinaryFuse8Memory(uint64(len(keys)), WithAlgorithmVersion(AlgorithmV2), WithLowMemory())
+	capped, err := PopulateBinaryFuse8(keys, WithAlgorithmVersion(AlgorithmV2), WithMaxScratchMemory(low.ScratchBytes))
+	assert.NoError(t, err)
+	assert.True(t, capped.Equal(v2))
+	parallel, err = PopulateBinaryFuse8(keys, WithAlgorithmVersion(AlgorithmV1), WithParallelism(4))
+	assert.NoError(t, err)
+	assert.False(t, parallel.Equal(v1))
+
+	for _, v := range []AlgorithmVersion{0, 3} {
+		_, err = PopulateBinaryFuse8(keys, WithAlgorithmVersion(v))
+		assert.Error(t, err)
+	}
+	assert.Equal(t, "v2", AlgorithmV2.String())
+	assert.Equal(t, AlgorithmVersion(0), (*BinaryFuse8)(nil).AlgorithmVersion())
+}
+
+func TestWithSeed(t *testing.T) {
+	keys := make([]uint64, 10000)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	for _, version := range []AlgorithmVersion{AlgorithmV1, AlgorithmV2} {
+		filter, err := PopulateBinaryFuse8(keys, WithRNGCounter(rand.Uint64()), WithAlgorithmVersion(version))
+		assert.NoError(t, err)
+		var stats BuildStats
+		rebuilt, err := PopulateBinaryFuse8(keys, WithSeed(filter.Seed), WithAlgorithmVersion(filter.AlgorithmVersion()), WithStats(&stats))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, stats.Iterations)
+		assert.True(t, rebuilt.Equal(filter))
+	}
+}
+
+func TestZeroizeScratch(t *testing.T) {
+	zeroed := func(p *Populator) bool {
+		for _, x := range p.t2hash[:cap(p.t2hash)] {
+			if x != 0 {
+				return false
+			}
+		}
+		for _, x := range p.reverseOrder[:cap(p.reverseOrder)] {
+			if x != 0 {
+				return false
+			}
+		}
+		for _, x := range p.t2count[:cap(p.t2count)] {
+			if x != 0 {
+				return false
+			}
+		}
+		for _, x := range p.alone[:cap(p.alone)] {
+			if x != 0 {
+				return false
+			}
+		}
+		return true
+	}
+	keys := make([]string, 10000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("secret-%d", i)
+	}
+	var p Populator
+	expected, err := p.PopulateBinaryFuse8FromStrings(keys)
+	assert.NoError(t, err)
+	assert.False(t, zeroed(&p))
+	for _, opts := range [][]Option{
+		{WithZeroizeScratch()},
+		{WithZeroizeScratch(), WithLowMemory()},
+		{WithZeroizeScratch(), WithDuplicateCheck(), WithAlgorithmVersion(AlgorithmV2)},
+	} {
+		expected, err := PopulateBinaryFuse8FromStrings(keys, opts[1:]...)
+		assert.NoError(t, err)
+		filter, err := p.PopulateBinaryFuse8FromStrings(keys, opts...)
+		assert.NoError(t, err)
+		assert.True(t, filter.Equal(expected))
+		assert.True(t, zeroed(&p))
+	}
+
+	// The arrays are zeroed after a failure too, and after a construction
+	// that prunes duplicate keys.
+	_, err = p.PopulateBinaryFuse8FromStrings(keys, WithZeroizeScratch(), WithMaxIterations(1), WithSizeFactor(1))
+	assert.Error(t, err)
+	assert.True(t, zeroed(&p))
+	_, err = p.PopulateBinaryFuse8FromStrings(append(keys, keys...), WithZeroizeScratch())
+	assert.NoError(t, err)
+	assert.True(t, zeroed(&p))
+
+	// So is the scratch buffer.
+	buf := make([]byte, EstimateBinaryFuse8Memory(uint64(len(keys))).ScratchBytes+7)
+	filter, err := PopulateBinaryFuse8FromStrings(keys, WithScratchBuffer(buf), WithZeroizeScratch())
+	assert.NoError(t, err)
+	assert.True(t, filter.Equal(expected))
+	assert.True(t, bytes.Equal(buf, make([]byte, len(buf))))
+}
+
+func BenchmarkPopulatorBinaryFuse8Sizes(b *testing.B) {
+	for _, n := range []int{100, 1000, 10000, 100000} {
+		keys := make([]uint64, n)
+		for i := range keys {
+			keys[i] = rand.Uint64()
+		}
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			var p Populator
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				p.PopulateBinaryFuse8(keys)
+			}
+		})
+	}
+}
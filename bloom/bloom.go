@@ -0,0 +1,239 @@
+// Package bloom is a stand-in for the Bloom filters of
+// github.com/bits-and-blooms/bloom, formerly github.com/willf/bloom, backed by
+// a binary fuse filter: swapping the import path is enough to migrate the
+// code that adds keys to a filter and tests them.
+//
+// Unlike a Bloom filter, a binary fuse filter cannot take keys once built.
+// The BloomFilter therefore keeps the 64-bit hashes of the keys, 8 bytes per
+// key. The keys added since the filter was built are held in a set of their
+// own as well, which the tests look up after the filter; the filter is
+// rebuilt with them once they outnumber the keys it holds, so that adding n
+// keys rebuilds it O(log n) times, whether or not tests come in between, as
+// in a loop of TestAndAdd. Build rebuilds it at once. The false positive rate
+// is at most about 0.4%, whatever the parameters given to New or
+// NewWithEstimates.
+package bloom
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/FastFilter/xorfilter"
+)
+
+// A BloomFilter is a set of byte strings with the methods of the
+// BloomFilter of bits-and-blooms/bloom. It is safe for concurrent use.
+type BloomFilter struct {
+	mu sync.Mutex
+	// keys holds the hashes of the keys. The first built ones are sorted,
+	// distinct, and in filter; the others were added since, and are the
+	// keys of pending.
+	keys    []uint64
+	built   int
+	pending map[uint64]struct{}
+	filter  *xorfilter.BinaryFuse8
+}
+
+// minPending is the number of keys added since the last build below which
+// the filter is not rebuilt.
+const minPending = 1 << 10
+
+// New returns an empty BloomFilter. m and k, the number of bits and of hash
+// functions of a Bloom filter, are ignored.
+func New(m uint, k uint) *BloomFilter {
+	return &BloomFilter{}
+}
+
+// NewWithEstimates returns an empty BloomFilter, sized for n keys. The false
+// positive rate fp is ignored.
+func NewWithEstimates(n uint, fp float64) *BloomFilter {
+	return &BloomFilter{keys: make([]uint64, 0, n)}
+}
+
+// Cap returns the number of bits of the fingerprints of the filter.
+func (f *BloomFilter) Cap() uint {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.build()
+	if f.filter == nil {
+		return 0
+	}
+	return uint(len(f.filter.Fingerprints)) * 8
+}
+
+// K returns 3, the number of fingerprints read by a test.
+func (f *BloomFilter) K() uint {
+	return 3
+}
+
+// Add adds data to the set, and returns f.
+func (f *BloomFilter) Add(data []byte) *BloomFilter {
+	hash := xorfilter.HashBytes(data)
+	f.mu.Lock()
+	f.add(hash)
+	f.mu.Unlock()
+	return f
+}
+
+// AddString is Add for a string.
+func (f *BloomFilter) AddString(data string) *BloomFilter {
+	return f.Add([]byte(data))
+}
+
+// Test returns whether data may be part of the set: it is true if data was
+// added, and almost always false otherwise.
+func (f *BloomFilter) Test(data []byte) bool {
+	hash := xorfilter.HashBytes(data)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.test(hash)
+}
+
+// TestString is Test for a string.
+func (f *BloomFilter) TestString(data string) bool {
+	return f.Test([]byte(data))
+}
+
+// TestAndAdd adds data to the set, and returns whether it may have been part
+// of it before.
+func (f *BloomFilter) TestAndAdd(data []byte) bool {
+	hash := xorfilter.HashBytes(data)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	present := f.test(hash)
+	f.add(hash)
+	return present
+}
+
+// TestAndAddString is TestAndAdd for a string.
+func (f *BloomFilter) TestAndAddString(data string) bool {
+	return f.TestAndAdd([]byte(data))
+}
+
+// TestOrAdd adds data to the set if it may not be part of it, and returns
+// whether it may have been part of it before.
+func (f *BloomFilter) TestOrAdd(data []byte) bool {
+	hash := xorfilter.HashBytes(data)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	present := f.test(hash)
+	if !present {
+		f.add(hash)
+	}
+	return present
+}
+
+// TestOrAddString is TestOrAdd for a string.
+func (f *BloomFilter) TestOrAddString(data string) bool {
+	return f.TestOrAdd([]byte(data))
+}
+
+// ClearAll removes all the keys from the set, and returns f.
+func (f *BloomFilter) ClearAll() *BloomFilter {
+	f.mu.Lock()
+	f.keys = f.keys[:0]
+	f.built = 0
+	f.pending = nil
+	f.filter = nil
+	f.mu.Unlock()
+	return f
+}
+
+// ApproximatedSize returns the number of distinct keys of the set, up to the
+// collisions of their 64-bit hashes.
+func (f *BloomFilter) ApproximatedSize() uint32 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.build()
+	return uint32(len(f.keys))
+}
+
+// Merge adds the keys of g to f. It never fails: the error is part of the
+// signature of bits-and-blooms/bloom, which fails for filters of different
+// sizes.
+func (f *BloomFilter) Merge(g *BloomFilter) error {
+	g.mu.Lock()
+	keys := append([]uint64(nil), g.keys...)
+	g.mu.Unlock()
+	f.mu.Lock()
+	for _, key := range keys {
+		f.add(key)
+	}
+	f.mu.Unlock()
+	return nil
+}
+
+// Build rebuilds the filter with the keys added since it was built, if any,
+// rather than when they outnumber its keys.
+func (f *BloomFilter) Build() {
+	f.mu.Lock()
+	f.build()
+	f.mu.Unlock()
+}
+
+// Copy returns a copy of f.
+func (f *BloomFilter) Copy() *BloomFilter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := &BloomFilter{
+		keys:   append([]uint64(nil), f.keys...),
+		built:  f.built,
+		filter: f.filter,
+	}
+	for key := range f.pending {
+		if c.pending == nil {
+			c.pending = make(map[uint64]struct{}, len(f.pending))
+		}
+		c.pending[key] = struct{}{}
+	}
+	return c
+}
+
+// add adds the key of the given hash, and rebuilds the filter once the keys
+// added since it was built outnumber its keys.
+func (f *BloomFilter) add(hash uint64) {
+	f.keys = append(f.keys, hash)
+	if f.pending == nil {
+		f.pending = make(map[uint64]struct{})
+	}
+	f.pending[hash] = struct{}{}
+	if pending := len(f.keys) - f.built; pending > minPending && pending > f.built {
+		f.build()
+	}
+}
+
+// test returns whether the key of the given hash may be part of the set.
+func (f *BloomFilter) test(hash uint64) bool {
+	if _, ok := f.pending[hash]; ok {
+		return true
+	}
+	if f.filter == nil {
+		// No filter was built, or its construction failed, which is
+		// unlikely: the sorted hashes answer instead, without false
+		// positives.
+		built := f.keys[:f.built]
+		i := sort.Search(len(built), func(i int) bool { return built[i] >= hash })
+		return i < len(built) && built[i] == hash
+	}
+	return f.filter.Contains(hash)
+}
+
+// build rebuilds the filter if keys were added since it was built.
+func (f *BloomFilter) build() {
+	if f.built == len(f.keys) {
+		return
+	}
+	f.pending = nil
+	keys := f.keys
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	n := 0
+	for i, key := range keys {
+		if i == 0 || key != keys[n-1] {
+			keys[n] = key
+			n++
+		}
+	}
+	f.keys = keys[:n]
+	f.built = n
+	f.filter, _ = xorfilter.PopulateBinaryFuse8(f.keys, xorfilter.WithSortedUniqueInput())
+}
@@ -0,0 +1,89 @@
+package bloom
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/FastFilter/xorfilter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBloomFilter(t *testing.T) {
+	f := NewWithEstimates(10000, 0.01)
+	assert.False(t, f.TestString("key-0"))
+	for i := 0; i < 10000; i++ {
+		f.AddString(fmt.Sprintf("key-%d", i))
+	}
+	f.Add([]byte("key-0"))
+	assert.Equal(t, uint32(10000), f.ApproximatedSize())
+	for i := 0; i < 10000; i++ {
+		assert.True(t, f.Test([]byte(fmt.Sprintf("key-%d", i))))
+	}
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if f.TestString(fmt.Sprintf("other-%d", i)) {
+			falsePositives++
+		}
+	}
+	assert.True(t, falsePositives < 100, "%d false positives", falsePositives)
+	assert.True(t, f.Cap() > 0)
+
+	assert.False(t, f.TestAndAddString("new"))
+	assert.True(t, f.TestString("new"))
+	assert.True(t, f.TestOrAddString("new"))
+	assert.False(t, f.TestOrAddString("newer"))
+	assert.True(t, f.TestString("newer"))
+
+	g := New(1000, 7).AddString("merged")
+	assert.Equal(t, nil, f.Merge(g))
+	assert.True(t, f.TestString("merged"))
+	c := f.Copy()
+	f.ClearAll()
+	assert.False(t, f.TestString("merged"))
+	assert.True(t, c.TestString("merged"))
+	assert.Equal(t, uint32(0), f.ApproximatedSize())
+}
+
+func TestBloomFilterTestAndAdd(t *testing.T) {
+	f := New(0, 0)
+	for i := 0; i < 100000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if f.TestAndAddString(key) {
+			// A false positive of the filter, not of the pending keys.
+			assert.True(t, f.built > 0)
+		}
+		assert.True(t, f.TestString(key))
+		pending := len(f.keys) - f.built
+		assert.True(t, pending <= minPending || pending <= f.built, "%d pending keys", pending)
+	}
+	assert.True(t, f.built > 0)
+	assert.True(t, len(f.pending) > 0)
+	f.Build()
+	assert.Equal(t, len(f.keys), f.built)
+	assert.Equal(t, 0, len(f.pending))
+	for i := 0; i < 100000; i++ {
+		assert.True(t, f.TestString(fmt.Sprintf("key-%d", i)))
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	m, k := EstimateParameters(100000, 0.01)
+	assert.Equal(t, uint(958506), m)
+	assert.Equal(t, uint(7), k)
+
+	keys := make([]uint64, 100000)
+	for i := range keys {
+		keys[i] = xorfilter.HashBytes([]byte(fmt.Sprintf("key-%d", i)))
+	}
+	filter, report, err := Migrate(append(keys, keys[0]), m, k)
+	assert.Equal(t, nil, err)
+	for _, key := range keys {
+		assert.True(t, filter.Contains(key))
+	}
+	assert.Equal(t, 100000, report.Keys)
+	assert.Equal(t, uint64(119814), report.BloomBytes)
+	assert.InDelta(t, 0.01, report.BloomFalsePositiveRate, 0.0005)
+	assert.Equal(t, filter.SizeInBytes(), report.FuseBytes)
+	assert.True(t, report.SavedBytes() > 0)
+	assert.InDelta(t, 1.0/256, report.MeasuredFalsePositiveRate, 0.0005)
+}
@@ -0,0 +1,102 @@
+package bloom
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/FastFilter/xorfilter"
+)
+
+// EstimateParameters returns the number of bits m and of hash functions k of
+// a Bloom filter of n keys with a false positive rate of fp, as
+// bits-and-blooms/bloom sizes them.
+func EstimateParameters(n uint, fp float64) (m uint, k uint) {
+	m = uint(math.Ceil(-1 * float64(n) * math.Log(fp) / (math.Ln2 * math.Ln2)))
+	k = uint(math.Ceil(math.Ln2 * float64(m) / float64(n)))
+	return m, k
+}
+
+// A Migration compares a Bloom filter with the binary fuse filter of the same
+// keys that replaces it.
+type Migration struct {
+	// Keys is the number of distinct keys.
+	Keys int
+	// BloomBytes and BloomFalsePositiveRate are the size of the bits of the
+	// Bloom filter, and its false positive rate for Keys keys.
+	BloomBytes             uint64
+	BloomFalsePositiveRate float64
+	// FuseBytes and FuseFalsePositiveRate are the size and the false
+	// positive rate of the binary fuse filter.
+	FuseBytes             uint64
+	FuseFalsePositiveRate float64
+	// MeasuredFalsePositiveRate is the fraction of absent keys that the binary
+	// fuse filter contains, out of migrationProbes random keys.
+	MeasuredFalsePositiveRate float64
+}
+
+// migrationProbes is the number of absent keys that measure the false
+// positive rate of the binary fuse filter.
+const migrationProbes = 1 << 20
+
+// Migrate builds the binary fuse filter of keys, and compares it with a Bloom
+// filter of m bits and k hash functions of the same keys. The keys are the
+// 64-bit keys of the filter: those of byte strings are given by HashBytes, as
+// the BloomFilter of this package hashes them. They need not be distinct.
+func Migrate(keys []uint64, m uint, k uint, opts ...xorfilter.Option) (*xorfilter.BinaryFuse8, *Migration, error) {
+	filter, err := xorfilter.PopulateBinaryFuse8(keys, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The construction removes the duplicate keys, which are not counted
+	// by the Bloom filter either.
+	distinct := make(map[uint64]struct{}, len(keys))
+	for _, key := range keys {
+		distinct[key] = struct{}{}
+	}
+	n := len(distinct)
+	report := &Migration{
+		Keys:                  n,
+		BloomBytes:            (uint64(m) + 7) / 8,
+		FuseBytes:             filter.SizeInBytes(),
+		FuseFalsePositiveRate: filter.FalsePositiveRate(),
+	}
+	if m > 0 {
+		report.BloomFalsePositiveRate = math.Pow(1-math.Exp(-float64(k)*float64(n)/float64(m)), float64(k))
+	}
+	// The random keys come from a fixed seed, so that the report is
+	// reproducible.
+	rng := rand.New(rand.NewSource(1))
+	positives, probes := 0, 0
+	for probes < migrationProbes {
+		key := rng.Uint64()
+		if _, ok := distinct[key]; ok {
+			continue
+		}
+		probes++
+		if filter.Contains(key) {
+			positives++
+		}
+	}
+	report.MeasuredFalsePositiveRate = float64(positives) / float64(probes)
+	return filter, report, nil
+}
+
+// SavedBytes returns the number of bytes saved by the binary fuse filter,
+// negative if it is larger than the Bloom filter.
+func (r *Migration) SavedBytes() int64 {
+	return int64(r.BloomBytes) - int64(r.FuseBytes)
+}
+
+// String returns a summary of the comparison, such as:
+//
+//	100000 keys: bloom 117.0 KiB, fpp 1.0039%; binary fuse 116.0 KiB (-0.8%), fpp 0.3906% (measured 0.3786%)
+func (r *Migration) String() string {
+	change := 0.0
+	if r.BloomBytes > 0 {
+		change = 100 * (float64(r.FuseBytes) - float64(r.BloomBytes)) / float64(r.BloomBytes)
+	}
+	return fmt.Sprintf("%d keys: bloom %.1f KiB, fpp %.4f%%; binary fuse %.1f KiB (%+.1f%%), fpp %.4f%% (measured %.4f%%)",
+		r.Keys, float64(r.BloomBytes)/1024, 100*r.BloomFalsePositiveRate,
+		float64(r.FuseBytes)/1024, change, 100*r.FuseFalsePositiveRate, 100*r.MeasuredFalsePositiveRate)
+}
@@ -0,0 +1,58 @@
+package xorfilter
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	l2CacheOnce sync.Once
+	l2Cache     uint64
+)
+
+// l2CacheSize returns the size of the L2 cache of the CPU, as reported by
+// Linux, or a typical size elsewhere.
+func l2CacheSize() uint64 {
+	l2CacheOnce.Do(func() {
+		l2Cache = 1 << 20
+		for i := 0; i < 8; i++ {
+			dir := fmt.Sprintf("/sys/devices/system/cpu/cpu0/cache/index%d/", i)
+			level, err := ioutil.ReadFile(dir + "level")
+			if err != nil {
+				return
+			}
+			if strings.TrimSpace(string(level)) != "2" {
+				continue
+			}
+			if size, ok := parseCacheSize(dir + "size"); ok {
+				l2Cache = size
+			}
+			return
+		}
+	})
+	return l2Cache
+}
+
+// parseCacheSize reads a cache size such as "2048K" from the file at path.
+func parseCacheSize(path string) (uint64, bool) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	s := strings.TrimSpace(string(data))
+	unit := uint64(1)
+	switch {
+	case strings.HasSuffix(s, "K"):
+		unit, s = 1<<10, strings.TrimSuffix(s, "K")
+	case strings.HasSuffix(s, "M"):
+		unit, s = 1<<20, strings.TrimSuffix(s, "M")
+	}
+	size, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || size == 0 {
+		return 0, false
+	}
+	return size * unit, true
+}
@@ -0,0 +1,198 @@
+// Command capi is the C interface of the package, to build as a shared
+// library for the programs in C, C++, Python, Ruby and other languages with
+// a foreign function interface:
+//
+//	go build -buildmode=c-shared -o libxorfilter.so ./capi
+//
+// The functions are declared in xorfilter.h, rather than in the header that
+// go build writes next to the library, which mentions the types of Go.
+package main
+
+/*
+#include <stdint.h>
+#include <stdlib.h>
+#include <string.h>
+*/
+import "C"
+
+import (
+	"reflect"
+	"sync"
+	"unsafe"
+
+	"github.com/FastFilter/xorfilter"
+)
+
+// The return codes of xorfilter.h.
+const (
+	xfOK         = 0
+	xfErrBuild   = -1
+	xfErrHandle  = -2
+	xfErrInvalid = -3
+	xfErrNoMem   = -4
+)
+
+// The filters of the handles. C cannot hold Go pointers, so it holds the
+// handles of the filters instead.
+var (
+	mu         sync.RWMutex
+	filters    = make(map[uint64]*xorfilter.BinaryFuse8)
+	nextHandle uint64
+)
+
+func newHandle(filter *xorfilter.BinaryFuse8) C.uint64_t {
+	mu.Lock()
+	defer mu.Unlock()
+	nextHandle++
+	filters[nextHandle] = filter
+	return C.uint64_t(nextHandle)
+}
+
+func lookup(handle C.uint64_t) *xorfilter.BinaryFuse8 {
+	mu.RLock()
+	defer mu.RUnlock()
+	return filters[uint64(handle)]
+}
+
+// uint64s returns the n uint64 at p, in C memory, as a slice, without
+// copying them.
+func uint64s(p *C.uint64_t, n C.size_t) []uint64 {
+	var s []uint64
+	if n > 0 {
+		header := (*reflect.SliceHeader)(unsafe.Pointer(&s))
+		header.Data = uintptr(unsafe.Pointer(p))
+		header.Len = int(n)
+		header.Cap = int(n)
+	}
+	return s
+}
+
+// bytes is uint64s for bytes.
+func bytes(p unsafe.Pointer, n C.size_t) []byte {
+	var s []byte
+	if n > 0 {
+		header := (*reflect.SliceHeader)(unsafe.Pointer(&s))
+		header.Data = uintptr(p)
+		header.Len = int(n)
+		header.Cap = int(n)
+	}
+	return s
+}
+
+//export xf_build
+func xf_build(keys *C.uint64_t, n C.size_t, out *C.uint64_t) C.int {
+	filter, err := xorfilter.PopulateBinaryFuse8(uint64s(keys, n))
+	if err != nil {
+		return xfErrBuild
+	}
+	*out = newHandle(filter)
+	return xfOK
+}
+
+//export xf_build_bytes
+func xf_build_bytes(keys **C.char, lens *C.size_t, n C.size_t, out *C.uint64_t) C.int {
+	hashes := make([]uint64, int(n))
+	for i := range hashes {
+		key := *(**C.char)(unsafe.Pointer(uintptr(unsafe.Pointer(keys)) + uintptr(i)*unsafe.Sizeof(*keys)))
+		length := *(*C.size_t)(unsafe.Pointer(uintptr(unsafe.Pointer(lens)) + uintptr(i)*unsafe.Sizeof(*lens)))
+		hashes[i] = xorfilter.HashBytes(bytes(unsafe.Pointer(key), length))
+	}
+	filter, err := xorfilter.PopulateBinaryFuse8(hashes)
+	if err != nil {
+		return xfErrBuild
+	}
+	*out = newHandle(filter)
+	return xfOK
+}
+
+//export xf_contains
+func xf_contains(handle C.uint64_t, key C.uint64_t) C.int {
+	filter := lookup(handle)
+	if filter == nil {
+		return xfErrHandle
+	}
+	if filter.Contains(uint64(key)) {
+		return 1
+	}
+	return 0
+}
+
+//export xf_contains_bytes
+func xf_contains_bytes(handle C.uint64_t, key *C.char, n C.size_t) C.int {
+	filter := lookup(handle)
+	if filter == nil {
+		return xfErrHandle
+	}
+	if filter.ContainsBytes(bytes(unsafe.Pointer(key), n)) {
+		return 1
+	}
+	return 0
+}
+
+//export xf_contains_batch
+func xf_contains_batch(handle C.uint64_t, keys *C.uint64_t, n C.size_t, out *C.uint8_t) C.int {
+	filter := lookup(handle)
+	if filter == nil {
+		return xfErrHandle
+	}
+	// The uint8_t of C are 0 or 1, as the bools of Go.
+	results := bytes(unsafe.Pointer(out), n)
+	filter.ContainsBatch(uint64s(keys, n), *(*[]bool)(unsafe.Pointer(&results)))
+	return xfOK
+}
+
+//export xf_serialize
+func xf_serialize(handle C.uint64_t, data **C.uint8_t, n *C.size_t) C.int {
+	filter := lookup(handle)
+	if filter == nil {
+		return xfErrHandle
+	}
+	encoded, err := filter.MarshalBinary()
+	if err != nil {
+		return xfErrInvalid
+	}
+	buf := C.malloc(C.size_t(len(encoded)))
+	if buf == nil {
+		return xfErrNoMem
+	}
+	copy(bytes(buf, C.size_t(len(encoded))), encoded)
+	*data = (*C.uint8_t)(buf)
+	*n = C.size_t(len(encoded))
+	return xfOK
+}
+
+//export xf_deserialize
+func xf_deserialize(data *C.uint8_t, n C.size_t, out *C.uint64_t) C.int {
+	var filter xorfilter.BinaryFuse8
+	// UnmarshalBinary copies the fingerprints out of the C buffer.
+	if err := filter.UnmarshalBinary(bytes(unsafe.Pointer(data), n)); err != nil {
+		return xfErrInvalid
+	}
+	*out = newHandle(&filter)
+	return xfOK
+}
+
+//export xf_size
+func xf_size(handle C.uint64_t) C.int64_t {
+	filter := lookup(handle)
+	if filter == nil {
+		return xfErrHandle
+	}
+	// The binary form holds the algorithm version, 4 bytes, after the
+	// exported fields.
+	return C.int64_t(filter.SizeInBytes() + 4)
+}
+
+//export xf_free
+func xf_free(handle C.uint64_t) {
+	mu.Lock()
+	delete(filters, uint64(handle))
+	mu.Unlock()
+}
+
+//export xf_free_buffer
+func xf_free_buffer(data *C.uint8_t) {
+	C.free(unsafe.Pointer(data))
+}
+
+func main() {}
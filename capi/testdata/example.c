@@ -0,0 +1,65 @@
+// An example of the C interface, built and run by the tests:
+//
+//     go build -buildmode=c-shared -o libxorfilter.so ./capi
+//     cc -o example capi/testdata/example.c -Icapi -L. -lxorfilter
+#include <stdio.h>
+#include <string.h>
+
+#include "xorfilter.h"
+
+int main(void) {
+	uint64_t keys[1000];
+	for (int i = 0; i < 1000; i++) {
+		keys[i] = (uint64_t)i * i;
+	}
+	xf_filter f;
+	if (xf_build(keys, 1000, &f) != XF_OK) {
+		fprintf(stderr, "xf_build failed\n");
+		return 1;
+	}
+	for (int i = 0; i < 1000; i++) {
+		if (xf_contains(f, keys[i]) != 1) {
+			fprintf(stderr, "missing key %d\n", i);
+			return 1;
+		}
+	}
+	uint8_t found[2];
+	uint64_t batch[2] = {4, 999999999};
+	if (xf_contains_batch(f, batch, 2, found) != XF_OK || found[0] != 1) {
+		fprintf(stderr, "xf_contains_batch failed\n");
+		return 1;
+	}
+
+	uint8_t *data;
+	size_t len;
+	if (xf_serialize(f, &data, &len) != XF_OK || (int64_t)len != xf_size(f)) {
+		fprintf(stderr, "xf_serialize failed\n");
+		return 1;
+	}
+	xf_free(f);
+	if (xf_contains(f, keys[0]) != XF_ERR_HANDLE) {
+		fprintf(stderr, "the handle was not freed\n");
+		return 1;
+	}
+	xf_filter g;
+	if (xf_deserialize(data, len, &g) != XF_OK || xf_contains(g, keys[999]) != 1) {
+		fprintf(stderr, "xf_deserialize failed\n");
+		return 1;
+	}
+	xf_free_buffer(data);
+	if (xf_deserialize((const uint8_t *)"bad", 3, &g) != XF_ERR_INVALID) {
+		fprintf(stderr, "xf_deserialize accepted an invalid filter\n");
+		return 1;
+	}
+
+	const char *names[] = {"alice", "bob"};
+	size_t lens[] = {5, 3};
+	xf_filter h;
+	if (xf_build_bytes(names, lens, 2, &h) != XF_OK || xf_contains_bytes(h, "bob", 3) != 1) {
+		fprintf(stderr, "xf_build_bytes failed\n");
+		return 1;
+	}
+	xf_free(h);
+	printf("ok\n");
+	return 0;
+}
@@ -0,0 +1,76 @@
+/*
+ * The C interface of libxorfilter, the BinaryFuse8 filters of
+ * github.com/FastFilter/xorfilter built as a shared library:
+ *
+ *     go build -buildmode=c-shared -o libxorfilter.so ./capi
+ *
+ * A filter is referred to by a handle, which xf_free releases. The functions
+ * are safe to call from several threads at once.
+ */
+#ifndef XORFILTER_H
+#define XORFILTER_H
+
+#include <stddef.h>
+#include <stdint.h>
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+/* A handle of a filter; 0 is never a valid handle. */
+typedef uint64_t xf_filter;
+
+/* The return codes of the functions. */
+#define XF_OK 0
+/* The construction failed, which is unlikely but for many duplicate keys. */
+#define XF_ERR_BUILD (-1)
+/* The handle is not that of a filter. */
+#define XF_ERR_HANDLE (-2)
+/* The serialized filter is invalid. */
+#define XF_ERR_INVALID (-3)
+/* The memory could not be allocated. */
+#define XF_ERR_NOMEM (-4)
+
+/* Builds the filter of n keys, and stores its handle in *out. */
+int xf_build(const uint64_t *keys, size_t n, xf_filter *out);
+
+/*
+ * Builds the filter of n binary keys, such as strings, where keys[i] points
+ * to the lens[i] bytes of key i, and stores its handle in *out. The keys are
+ * hashed as the Go PopulateBinaryFuse8FromBytes hashes them.
+ */
+int xf_build_bytes(const char *const *keys, const size_t *lens, size_t n, xf_filter *out);
+
+/* Returns 1 if key may be in the filter, 0 if it is not, or XF_ERR_HANDLE. */
+int xf_contains(xf_filter f, uint64_t key);
+
+/* Is xf_contains for the len bytes of a binary key. */
+int xf_contains_bytes(xf_filter f, const char *key, size_t len);
+
+/* Sets out[i] to xf_contains(f, keys[i]) for the n keys. */
+int xf_contains_batch(xf_filter f, const uint64_t *keys, size_t n, uint8_t *out);
+
+/*
+ * Serializes the filter, in the format of the Go MarshalBinary, into a buffer
+ * allocated with malloc, stored in *data, of *len bytes. The caller frees
+ * the buffer, with free or xf_free_buffer.
+ */
+int xf_serialize(xf_filter f, uint8_t **data, size_t *len);
+
+/* Restores a filter serialized by xf_serialize, and stores its handle in *out. */
+int xf_deserialize(const uint8_t *data, size_t len, xf_filter *out);
+
+/* Returns the size of the serialized filter, in bytes, or XF_ERR_HANDLE. */
+int64_t xf_size(xf_filter f);
+
+/* Releases the filter of a handle. */
+void xf_free(xf_filter f);
+
+/* Frees a buffer of xf_serialize. */
+void xf_free_buffer(uint8_t *data);
+
+#ifdef __cplusplus
+}
+#endif
+
+#endif
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"runtime"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/FastFilter/xorfilter"
+)
+
+// benchFilter is a filter type compared by bench. build returns the filter
+// of keys and, if it reuses pooled arrays, which the allocations do not
+// show, the size of its temporary arrays.
+type benchFilter struct {
+	name  string
+	build func(keys []uint64) (xorfilter.Filter, uint64, error)
+}
+
+var benchFilters = []benchFilter{
+	{"Xor8", func(keys []uint64) (xorfilter.Filter, uint64, error) {
+		filter, err := xorfilter.Populate(keys)
+		return filter, 0, err
+	}},
+	{"Fuse8", func(keys []uint64) (xorfilter.Filter, uint64, error) {
+		filter, err := xorfilter.PopulateFuse8(keys)
+		return filter, 0, err
+	}},
+	{"BinaryFuse8", func(keys []uint64) (xorfilter.Filter, uint64, error) {
+		var stats xorfilter.BuildStats
+		filter, err := xorfilter.PopulateBinaryFuse8(keys, xorfilter.WithStats(&stats))
+		return filter, stats.ScratchBytes, err
+	}},
+	{"BinaryFuse8 (low memory)", func(keys []uint64) (xorfilter.Filter, uint64, error) {
+		var stats xorfilter.BuildStats
+		filter, err := xorfilter.PopulateBinaryFuse8(keys, xorfilter.WithLowMemory(), xorfilter.WithStats(&stats))
+		return filter, stats.ScratchBytes, err
+	}},
+}
+
+// bench compares the filter types on the keys of a file, or of stdin.
+func bench(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "decimal", formats)
+	queries := flags.Int("queries", 1000000, "the number of keys absent from the set to query")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: xorfilter bench [-format f] [-queries n] [keys]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil || flags.NArg() > 1 || *queries < 0 {
+		if err == nil {
+			flags.Usage()
+		}
+		return errUsage
+	}
+	in, err := openInput(flags.Arg(0), stdin)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	var keys []uint64
+	err = readKeys(in, *format, func(text string, key uint64) error {
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// Xor8 and Fuse8 need distinct keys; the sorted keys also tell the
+	// absent keys apart.
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	n := 0
+	for i, key := range keys {
+		if i == 0 || key != keys[n-1] {
+			keys[n] = key
+			n++
+		}
+	}
+	keys = keys[:n]
+	absent := absentKeys(keys, *queries)
+	fmt.Fprintf(stdout, "%d distinct keys, %d absent keys queried\n\n", len(keys), len(absent))
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "filter\tbuild\tmemory MB\tbits/key\tns/query\tFPP %\texpected FPP %\t")
+	for _, f := range benchFilters {
+		// The keys are copied, as a construction may reorder them.
+		input := append([]uint64(nil), keys...)
+		runtime.GC()
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		start := time.Now()
+		filter, scratch, err := f.build(input)
+		elapsed := time.Since(start)
+		runtime.ReadMemStats(&after)
+		if err != nil {
+			fmt.Fprintf(w, "%s\t%v\t\t\t\t\t\t\n", f.name, err)
+			continue
+		}
+		for _, key := range keys {
+			if !filter.Contains(key) {
+				return fmt.Errorf("%s: missing key %d", f.name, key)
+			}
+		}
+		start = time.Now()
+		positives := 0
+		for _, key := range absent {
+			if filter.Contains(key) {
+				positives++
+			}
+		}
+		query := time.Since(start)
+		memory := after.TotalAlloc - before.TotalAlloc
+		if scratch > 0 {
+			memory = scratch + filter.SizeInBytes()
+		}
+		fmt.Fprintf(w, "%s\t%v\t%.1f\t%s\t%s\t%s\t%.3f\t\n", f.name,
+			elapsed.Round(time.Microsecond),
+			float64(memory)/(1<<20),
+			ratio(float64(8*filter.SizeInBytes()), len(keys), 1),
+			ratio(float64(query.Nanoseconds()), len(absent), 1),
+			ratio(float64(positives), len(absent), 100),
+			100*filter.FalsePositiveRate())
+	}
+	return w.Flush()
+}
+
+// ratio formats scale*x/n, or "-" if n is 0.
+func ratio(x float64, n int, scale float64) string {
+	if n == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.3f", scale*x/float64(n))
+}
+
+// absentKeys returns n random keys that are not among the sorted keys, the
+// same from one run to the next.
+func absentKeys(keys []uint64, n int) []uint64 {
+	rng := rand.New(rand.NewSource(1))
+	absent := make([]uint64, 0, n)
+	for len(absent) < n {
+		key := rng.Uint64()
+		i := sort.Search(len(keys), func(i int) bool { return keys[i] >= key })
+		if i < len(keys) && keys[i] == key {
+			continue
+		}
+		absent = append(absent, key)
+	}
+	return absent
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/FastFilter/xorfilter"
+)
+
+// build builds the filter of the keys of a file, or of stdin.
+func build(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("build", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "decimal", formats)
+	output := flags.String("o", "-", "the file of the filter, or - for the standard output")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: xorfilter build [-format f] [-o filter.bin] [keys]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil || flags.NArg() > 1 {
+		if err == nil {
+			flags.Usage()
+		}
+		return errUsage
+	}
+	in, err := openInput(flags.Arg(0), stdin)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	var keys []uint64
+	err = readKeys(in, *format, func(text string, key uint64) error {
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	filter, err := xorfilter.PopulateBinaryFuse8(keys)
+	if err != nil {
+		return err
+	}
+	data, err := filter.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if *output == "-" {
+		_, err = stdout.Write(data)
+		return err
+	}
+	// The filter is written next to its file, and renamed over it, so that
+	// the readers of the file never see a partial filter.
+	tmp := *output + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, *output)
+}
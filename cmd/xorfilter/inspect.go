@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/FastFilter/xorfilter"
+)
+
+// inspect prints the parameters of a filter.
+func inspect(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: xorfilter inspect filter.bin")
+	}
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		if err == nil {
+			flags.Usage()
+		}
+		return errUsage
+	}
+	filter, err := loadFilter(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	keys := estimateKeys(uint64(len(filter.Fingerprints)))
+	fmt.Fprintf(stdout, "type:                 BinaryFuse8\n")
+	fmt.Fprintf(stdout, "size:                 %d bytes\n", filter.SizeInBytes())
+	fmt.Fprintf(stdout, "seed:                 %#x\n", filter.Seed)
+	fmt.Fprintf(stdout, "segment length:       %d\n", filter.SegmentLength)
+	fmt.Fprintf(stdout, "segment count:        %d\n", filter.SegmentCount)
+	fmt.Fprintf(stdout, "fingerprints:         %d\n", len(filter.Fingerprints))
+	fmt.Fprintf(stdout, "false positive rate:  %.4f%%\n", 100*filter.FalsePositiveRate())
+	if keys > 0 {
+		fmt.Fprintf(stdout, "keys (from the size): about %d\n", keys)
+		fmt.Fprintf(stdout, "bits per key:         about %.2f\n", float64(8*len(filter.Fingerprints))/float64(keys))
+	}
+	return nil
+}
+
+// estimateKeys returns the largest number of keys for which a filter has the
+// given number of fingerprints, as the number of keys is not saved with the
+// filter. It returns 0 if none has.
+func estimateKeys(fingerprints uint64) uint64 {
+	// The number of fingerprints grows with the number of keys: find the
+	// first number of keys with more fingerprints.
+	lo, hi := uint64(0), uint64(xorfilter.MaxBinaryFuse8Keys)+1
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		if xorfilter.EstimateBinaryFuse8Memory(mid).FilterBytes > fingerprints {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	if lo == 0 || xorfilter.EstimateBinaryFuse8Memory(lo-1).FilterBytes != fingerprints {
+		return 0
+	}
+	return lo - 1
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/FastFilter/xorfilter"
+)
+
+// errUsage is returned for invalid arguments, once the usage is printed.
+var errUsage = errors.New("usage")
+
+// formats are the formats of the keys, for the help of the -format flag.
+const formats = "the format of the keys: decimal, hex, raw or text"
+
+// parseKey returns the uint64 key of s in the given format, other than raw.
+func parseKey(s string, format string) (uint64, error) {
+	switch format {
+	case "decimal":
+		return strconv.ParseUint(s, 10, 64)
+	case "hex":
+		return strconv.ParseUint(strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X"), 16, 64)
+	case "text":
+		return xorfilter.HashBytes([]byte(s)), nil
+	}
+	return 0, fmt.Errorf("unknown format %q", format)
+}
+
+// readKeys calls fn for each key of r in the given format, with the key as
+// written, for the messages, and as a uint64.
+func readKeys(r io.Reader, format string, fn func(text string, key uint64) error) error {
+	if format == "raw" {
+		br := bufio.NewReader(r)
+		var word [8]byte
+		for {
+			if _, err := io.ReadFull(br, word[:]); err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				if err == io.ErrUnexpectedEOF {
+					return errors.New("the raw keys are not a multiple of 8 bytes")
+				}
+				return err
+			}
+			key := binary.LittleEndian.Uint64(word[:])
+			if err := fn(strconv.FormatUint(key, 10), key); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := parseKey("0", format); err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if format != "text" {
+			text = strings.TrimSpace(text)
+			if text == "" {
+				continue
+			}
+		}
+		key, err := parseKey(text, format)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+		if err := fn(text, key); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// openInput returns the file of name, or stdin if name is empty or "-".
+func openInput(name string, stdin io.Reader) (io.ReadCloser, error) {
+	if name == "" || name == "-" {
+		return ioutil.NopCloser(stdin), nil
+	}
+	return os.Open(name)
+}
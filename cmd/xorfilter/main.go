@@ -0,0 +1,80 @@
+// Command xorfilter builds, queries and inspects BinaryFuse8 filters saved
+// in files, for shell pipelines and cron jobs:
+//
+//	xorfilter build [-format f] [-o filter.bin] [keys]
+//	xorfilter query [-format f] [-v] filter.bin [key ...]
+//	xorfilter inspect filter.bin
+//	xorfilter bench [-format f] [-queries n] [keys]
+//	xorfilter vectors [-verify file]
+//
+// The keys are read from a file, or from the standard input without one, in
+// one of the formats:
+//
+//	decimal  a uint64 per line, in decimal
+//	hex      a uint64 per line, in hexadecimal, with or without 0x
+//	raw      little-endian uint64 words of 8 bytes, as PopulateBinaryFuse8FromReader reads
+//	text     a string per line, as PopulateBinaryFuse8FromStrings hashes it
+//
+// The filter is saved in the format of MarshalBinary. Query prints the keys
+// that may be in the set, or with -v those that are not, and exits with 0
+// if it printed any key, 1 if it did not, and 2 on error, as grep does.
+// Bench builds each type of filter from the keys, and compares the time and
+// the memory of the constructions, and the speed and the false positive
+// rate of the queries of random keys absent from the set. Vectors writes the
+// test vectors of CanonicalTestVectors in JSON, or verifies those of a file,
+// for the ports of the package to other languages.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+const usage = `usage:
+  xorfilter build [-format f] [-o filter.bin] [keys]
+  xorfilter query [-format f] [-v] filter.bin [key ...]
+  xorfilter inspect filter.bin
+  xorfilter bench [-format f] [-queries n] [keys]
+  xorfilter vectors [-verify file]
+run "xorfilter <command> -h" for the options of a command
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command of args, and returns its exit status.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	var err error
+	switch args[0] {
+	case "build":
+		err = build(args[1:], stdin, stdout, stderr)
+	case "query":
+		var found bool
+		found, err = query(args[1:], stdin, stdout, stderr)
+		if err == nil && !found {
+			return 1
+		}
+	case "inspect":
+		err = inspect(args[1:], stdout, stderr)
+	case "bench":
+		err = bench(args[1:], stdin, stdout, stderr)
+	case "vectors":
+		err = vectors(args[1:], stdout, stderr)
+	default:
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	if err != nil {
+		if err != errUsage {
+			fmt.Fprintln(stderr, "xorfilter:", err)
+		}
+		return 2
+	}
+	return 0
+}
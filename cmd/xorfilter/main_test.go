@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func runCommand(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	status := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+func TestBuildQueryInspect(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xorfilter")
+	assert.Equal(t, nil, err)
+	defer os.RemoveAll(dir)
+	filter := filepath.Join(dir, "filter.bin")
+
+	status, _, stderr := runCommand("1\n2\n\n0x3\n", "build", "-format", "hex", "-o", filter)
+	assert.Equal(t, 0, status, stderr)
+
+	status, stdout, _ := runCommand("", "query", "-format", "hex", filter, "1", "0x2", "3")
+	assert.Equal(t, 0, status)
+	assert.Equal(t, "1\n0x2\n3\n", stdout)
+	status, stdout, _ = runCommand("1\nff\n", "query", "-format", "hex", "-v", filter)
+	assert.Equal(t, 0, status)
+	assert.Equal(t, "ff\n", stdout)
+	status, _, _ = runCommand("", "query", "-format", "hex", filter, "ff")
+	assert.Equal(t, 1, status)
+	status, _, stderr = runCommand("", "query", "-format", "hex", filter, "zz")
+	assert.Equal(t, 2, status)
+	assert.True(t, strings.Contains(stderr, "zz"))
+
+	status, stdout, _ = runCommand("", "inspect", filter)
+	assert.Equal(t, 0, status)
+	assert.True(t, strings.Contains(stdout, "keys (from the size): about"), stdout)
+
+	var raw bytes.Buffer
+	for i := uint64(0); i < 10000; i++ {
+		binary.Write(&raw, binary.LittleEndian, i*i)
+	}
+	status, data, _ := runCommand(raw.String(), "build", "-format", "raw")
+	assert.Equal(t, 0, status)
+	assert.Equal(t, nil, ioutil.WriteFile(filter, []byte(data), 0o644))
+	status, stdout, _ = runCommand("", "query", filter, "0", "1", "4", "9801")
+	assert.Equal(t, 0, status)
+	assert.Equal(t, "0\n1\n4\n9801\n", stdout)
+	status, stdout, _ = runCommand("", "inspect", filter)
+	assert.Equal(t, 0, status)
+	assert.True(t, strings.Contains(stdout, "about 10"), stdout)
+
+	status, _, _ = runCommand("", "bogus")
+	assert.Equal(t, 2, status)
+	status, _, stderr = runCommand("abc\n", "build")
+	assert.Equal(t, 2, status)
+	assert.True(t, strings.Contains(stderr, "line 1"), stderr)
+}
+
+func TestBench(t *testing.T) {
+	status, stdout, stderr := runCommand("1\n2\n3\n2\n", "bench", "-queries", "1000")
+	assert.Equal(t, 0, status, stderr)
+	assert.True(t, strings.HasPrefix(stdout, "3 distinct keys, 1000 absent keys queried"), stdout)
+	for _, f := range benchFilters {
+		assert.True(t, strings.Contains(stdout, f.name+" "), stdout)
+	}
+	status, _, _ = runCommand("", "bench", "-queries", "-1")
+	assert.Equal(t, 2, status)
+}
+
+func TestVectors(t *testing.T) {
+	status, stdout, stderr := runCommand("", "vectors")
+	assert.Equal(t, 0, status, stderr)
+	golden, err := ioutil.ReadFile(filepath.Join("..", "..", "testdata", "vectors.json"))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, string(golden), stdout)
+
+	dir, err := ioutil.TempDir("", "xorfilter")
+	assert.Equal(t, nil, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "vectors.json")
+	assert.Equal(t, nil, ioutil.WriteFile(file, golden, 0o644))
+	status, stdout, stderr = runCommand("", "vectors", "-verify", file)
+	assert.Equal(t, 0, status, stderr)
+	assert.True(t, strings.HasSuffix(stdout, " test vectors verified\n"), stdout)
+
+	corrupted := append([]byte(nil), golden...)
+	corrupted[bytes.Index(corrupted, []byte(`"seed": "`))+len(`"seed": "`)] ^= 1
+	assert.Equal(t, nil, ioutil.WriteFile(file, corrupted, 0o644))
+	status, _, stderr = runCommand("", "vectors", "-verify", file)
+	assert.Equal(t, 2, status)
+	assert.True(t, strings.Contains(stderr, "seed"), stderr)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/FastFilter/xorfilter"
+)
+
+// loadFilter reads the filter of a file written by build.
+func loadFilter(name string) (*xorfilter.BinaryFuse8, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var filter xorfilter.BinaryFuse8
+	if err := filter.UnmarshalBinary(data); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return &filter, nil
+}
+
+// query prints the keys, of the arguments or of stdin, that may be in the
+// filter, or those that are not with -v, and returns whether it printed any.
+func query(args []string, stdin io.Reader, stdout, stderr io.Writer) (bool, error) {
+	flags := flag.NewFlagSet("query", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "decimal", formats)
+	invert := flags.Bool("v", false, "print the keys that are not in the set")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: xorfilter query [-format f] [-v] filter.bin [key ...]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil || flags.NArg() < 1 {
+		if err == nil {
+			flags.Usage()
+		}
+		return false, errUsage
+	}
+	filter, err := loadFilter(flags.Arg(0))
+	if err != nil {
+		return false, err
+	}
+	w := bufio.NewWriter(stdout)
+	found := false
+	check := func(text string, key uint64) error {
+		if filter.Contains(key) != *invert {
+			found = true
+			_, err := fmt.Fprintln(w, text)
+			return err
+		}
+		return nil
+	}
+	if flags.NArg() > 1 {
+		if *format == "raw" {
+			return false, fmt.Errorf("raw keys are read from the standard input")
+		}
+		for _, text := range flags.Args()[1:] {
+			key, err := parseKey(text, *format)
+			if err != nil {
+				return false, fmt.Errorf("%q: %v", text, err)
+			}
+			if err := check(text, key); err != nil {
+				return false, err
+			}
+		}
+	} else if err := readKeys(stdin, *format, check); err != nil {
+		return false, err
+	}
+	if err := w.Flush(); err != nil {
+		return false, err
+	}
+	return found, nil
+}
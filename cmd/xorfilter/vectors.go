@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/FastFilter/xorfilter"
+)
+
+// vectors writes the canonical test vectors, or verifies those of a file.
+func vectors(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("vectors", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	verify := flags.String("verify", "", "verify the test vectors of `file` instead of writing them")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: xorfilter vectors [-verify file]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		if err == nil {
+			flags.Usage()
+		}
+		return errUsage
+	}
+	if *verify == "" {
+		vs, err := xorfilter.CanonicalTestVectors()
+		if err != nil {
+			return err
+		}
+		return xorfilter.WriteTestVectors(stdout, vs)
+	}
+	f, err := os.Open(*verify)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	vs, err := xorfilter.ReadTestVectors(f)
+	if err != nil {
+		return err
+	}
+	for _, v := range vs {
+		if err := v.Verify(); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(stdout, "%d test vectors verified\n", len(vs))
+	return nil
+}
@@ -0,0 +1,112 @@
+package xorfilter
+
+import (
+	"fmt"
+	"io"
+)
+
+// occupancy returns the number of non-zero fingerprints. A slot that is not
+// used by any key keeps a zero fingerprint, while a used slot is non-zero
+// with probability 255/256, so the occupancy estimates the number of keys.
+func occupancy(fingerprints []uint8) int {
+	used := 0
+	for _, f := range fingerprints {
+		if f != 0 {
+			used++
+		}
+	}
+	return used
+}
+
+// describeFingerprints formats the size, occupancy and estimated bits per key
+// of fingerprints.
+func describeFingerprints(fingerprints []uint8) string {
+	used := occupancy(fingerprints)
+	s := fmt.Sprintf("%d fingerprints, %.1f%% occupied", len(fingerprints), percent(used, len(fingerprints)))
+	if used > 0 {
+		keys := float64(used) * 256 / 255
+		s += fmt.Sprintf(", ~%.2f bits/key", float64(8*len(fingerprints))/keys)
+	}
+	return s
+}
+
+func percent(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(part) / float64(total)
+}
+
+// String describes the filter: its seed, its geometry and how full it is.
+func (filter *BinaryFuse8) String() string {
+	if filter == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("BinaryFuse8{seed: %#x, %d segments of length %d, %s}",
+		filter.Seed, filter.SegmentCount, filter.SegmentLength, describeFingerprints(filter.Fingerprints))
+}
+
+// Dump writes the description of the filter to w, followed by the occupancy
+// of each segment, which shows whether the keys are spread evenly.
+func (filter *BinaryFuse8) Dump(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, filter); err != nil || filter == nil {
+		return err
+	}
+	length := int(filter.SegmentLength)
+	for start, segment := 0, 0; length > 0 && start < len(filter.Fingerprints); start, segment = start+length, segment+1 {
+		end := start + length
+		if end > len(filter.Fingerprints) {
+			end = len(filter.Fingerprints)
+		}
+		if _, err := fmt.Fprintf(w, "segment %d: %.1f%% occupied\n", segment, percent(occupancy(filter.Fingerprints[start:end]), end-start)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// String describes the filter: its seed, its shards and how full they are.
+func (filter *BinaryFuse8Big) String() string {
+	if filter == nil {
+		return "<nil>"
+	}
+	fingerprints, used := 0, 0
+	for i := range filter.Shards {
+		fingerprints += len(filter.Shards[i].Fingerprints)
+		used += occupancy(filter.Shards[i].Fingerprints)
+	}
+	return fmt.Sprintf("BinaryFuse8Big{seed: %#x, %d shards, %d fingerprints, %.1f%% occupied}",
+		filter.Seed, len(filter.Shards), fingerprints, percent(used, fingerprints))
+}
+
+// Dump writes the description of the filter to w, followed by the
+// description of each shard.
+func (filter *BinaryFuse8Big) Dump(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, filter); err != nil || filter == nil {
+		return err
+	}
+	for i := range filter.Shards {
+		if _, err := fmt.Fprintf(w, "shard %d: %v\n", i, &filter.Shards[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// String describes the filter: its seed, its geometry and how full it is.
+func (filter *Xor8) String() string {
+	if filter == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("Xor8{seed: %#x, 3 blocks of length %d, %s}",
+		filter.Seed, filter.BlockLength, describeFingerprints(filter.Fingerprints))
+}
+
+// String describes the filter: its seed, its geometry and how full it is.
+func (filter *Fuse8) String() string {
+	if filter == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("Fuse8{seed: %#x, %d segments of length %d, %s}",
+		filter.Seed, SLOTS, filter.SegmentLength, describeFingerprints(filter.Fingerprints))
+}
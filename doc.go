@@ -0,0 +1,32 @@
+// Package xorfilter implements xor filters and binary fuse filters, which
+// answer whether a key is likely in a set, in less memory than Bloom filters.
+//
+// BinaryFuse8 is the filter to use: it is the smallest and the fastest to
+// build. Xor8 and Fuse8 remain for the users of their formats. There is a
+// single construction of the BinaryFuse8 filters, and a function per form of
+// the keys:
+//
+//	PopulateBinaryFuse8            a []uint64 of keys
+//	PopulateBinaryFuse8FromStrings strings, hashed for ContainsString
+//	PopulateBinaryFuse8FromBytes   byte slices, hashed for ContainsBytes
+//	PopulateBinaryFuse8From128     128-bit keys, hashed for Contains128
+//	PopulateBinaryFuse8FromUUIDs   UUIDs, hashed for ContainsUUID
+//	PopulateBinaryFuse8FromUint32  a []uint32 of keys
+//	PopulateBinaryFuse8Hashed      keys that are already good 64-bit hashes
+//	PopulateBinaryFuse8FromReader  little-endian keys read from an io.Reader
+//	PopulateBinaryFuse8FromFunc    keys emitted a chunk at a time by a function
+//
+// The construction adapts to the size of the set on its own: the hashes of
+// the keys of a large set are binned by region of the filter before they are
+// added to it, so that the construction arrays are visited one region at a
+// time, and those of a set whose arrays fit in the L2 cache are not. The
+// options, rather than other functions, trade the speed of the construction
+// for its memory: WithParallelism spreads it over several goroutines,
+// WithLowMemory and WithMaxScratchMemory cut its temporary arrays, and a
+// Populator recycles them from one construction to the next.
+// PopulateBinaryFuse8Ctx can be canceled, and PopulateBinaryFuse8Race cuts
+// the latency of the unlucky constructions with concurrent ones. The sets of
+// more than MaxBinaryFuse8Keys keys go into a BinaryFuse8Big filter, built by
+// PopulateBinaryFuse8Big, or by PopulateBinaryFuse8External when the keys do
+// not fit in memory.
+package xorfilter
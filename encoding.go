@@ -0,0 +1,358 @@
+package xorfilter
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidFilter is the error, possibly wrapped with the details, returned
+// for a filter whose fields are not consistent, for example because its
+// serialized form was corrupted.
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// maxSegmentLength is the largest segment length of a BinaryFuse8 filter.
+const maxSegmentLength = 262144
+
+// errNilFilter is returned by the methods of a nil filter that cannot treat
+// it as an empty one.
+var errNilFilter = fmt.Errorf("%w: nil filter", ErrInvalidFilter)
+
+func invalidFilter(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: "+format, append([]interface{}{ErrInvalidFilter}, args...)...)
+}
+
+// Validate checks that the fields of the filter are consistent, so that
+// Contains cannot index out of the fingerprints.
+func (filter *BinaryFuse8) Validate() error {
+	if filter == nil {
+		return errNilFilter
+	}
+	if filter.SegmentLength == 0 || filter.SegmentLength&(filter.SegmentLength-1) != 0 {
+		return invalidFilter("segment length %d is not a power of two", filter.SegmentLength)
+	}
+	if filter.SegmentLength > maxSegmentLength {
+		return invalidFilter("segment length %d is larger than %d", filter.SegmentLength, maxSegmentLength)
+	}
+	if filter.SegmentLengthMask != filter.SegmentLength-1 {
+		return invalidFilter("segment length mask %#x does not match segment length %d", filter.SegmentLengthMask, filter.SegmentLength)
+	}
+	if filter.SegmentCount == 0 {
+		return invalidFilter("segment count is zero")
+	}
+	if uint64(filter.SegmentCountLength) != uint64(filter.SegmentCount)*uint64(filter.SegmentLength) {
+		return invalidFilter("segment count length %d is not %d segments of length %d", filter.SegmentCountLength, filter.SegmentCount, filter.SegmentLength)
+	}
+	expected := binaryFuse8ArrayLength(filter.SegmentCount, filter.SegmentLength)
+	if expected > maxFingerprints {
+		// The three indexes of a key would overflow their 32 bits.
+		return invalidFilter("%d fingerprints are more than %d", expected, uint64(maxFingerprints))
+	}
+	if uint64(len(filter.Fingerprints)) != expected {
+		return invalidFilter("%d fingerprints instead of %d", len(filter.Fingerprints), expected)
+	}
+	return nil
+}
+
+// maxFingerprints is the largest number of fingerprints of a filter, whose
+// indexes are 32-bit integers.
+const maxFingerprints = 1 << 32
+
+// binaryFuse8ArrayLength returns the number of fingerprints of a BinaryFuse8
+// filter with the given segments.
+func binaryFuse8ArrayLength(segmentCount, segmentLength uint32) uint64 {
+	return (uint64(segmentCount) + 2) * uint64(segmentLength)
+}
+
+// binaryFuse8HeaderSize is the size of the fields of a BinaryFuse8 filter
+// that precede the fingerprints in its binary form: the exported fields and
+// the algorithm version.
+const binaryFuse8HeaderSize = 8 + 4*4 + 4
+
+// binarySize returns the size of the binary form of the filter.
+func (filter *BinaryFuse8) binarySize() uint64 {
+	return binaryFuse8HeaderSize + uint64(len(filter.Fingerprints))
+}
+
+// MarshalBinary encodes the filter as its exported fields and its algorithm
+// version, or 0 if it is not known, as 32-bit integers, in little-endian
+// order, followed by the fingerprints.
+func (filter *BinaryFuse8) MarshalBinary() ([]byte, error) {
+	if filter == nil {
+		return nil, errNilFilter
+	}
+	return filter.appendBinary(make([]byte, 0, filter.binarySize())), nil
+}
+
+func (filter *BinaryFuse8) appendBinary(data []byte) []byte {
+	var header [binaryFuse8HeaderSize]byte
+	binary.LittleEndian.PutUint64(header[0:], filter.Seed)
+	binary.LittleEndian.PutUint32(header[8:], filter.SegmentLength)
+	binary.LittleEndian.PutUint32(header[12:], filter.SegmentLengthMask)
+	binary.LittleEndian.PutUint32(header[16:], filter.SegmentCount)
+	binary.LittleEndian.PutUint32(header[20:], filter.SegmentCountLength)
+	binary.LittleEndian.PutUint32(header[24:], uint32(filter.version))
+	data = append(data, header[:]...)
+	return append(data, filter.Fingerprints...)
+}
+
+// UnmarshalBinary decodes a filter encoded by MarshalBinary, and validates it.
+// It fails with an error wrapping ErrInvalidFilter for an algorithm version
+// that this release does not know. The fingerprints are copied out of data. The Hasher of the filter, if it was
+// set, is kept.
+func (filter *BinaryFuse8) UnmarshalBinary(data []byte) error {
+	if filter == nil {
+		return errNilFilter
+	}
+	rest, err := filter.decodeBinary(data, false)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return invalidFilter("%d trailing bytes", len(rest))
+	}
+	return nil
+}
+
+// ViewBinaryFuse8 decodes a filter encoded by MarshalBinary, and validates
+// it, like UnmarshalBinary, but the Fingerprints of the filter are data
+// itself rather than a copy: data must not be modified while the filter is
+// used. It suits the devices that only query a filter built elsewhere, since
+// the filter can stay in read-only memory, such as a byte array embedded in
+// the program, and nothing proportional to its size is allocated.
+func ViewBinaryFuse8(data []byte) (*BinaryFuse8, error) {
+	filter := &BinaryFuse8{}
+	rest, err := filter.decodeBinary(data, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, invalidFilter("%d trailing bytes", len(rest))
+	}
+	return filter, nil
+}
+
+// decodeBinary decodes a filter at the start of data, and returns the bytes
+// that follow it. If view is true, the fingerprints are not copied out of
+// data.
+func (filter *BinaryFuse8) decodeBinary(data []byte, view bool) ([]byte, error) {
+	if len(data) < binaryFuse8HeaderSize {
+		return nil, invalidFilter("%d bytes are too short for a BinaryFuse8 filter", len(data))
+	}
+	version := binary.LittleEndian.Uint32(data[24:])
+	if version > uint32(AlgorithmV2) {
+		return nil, invalidFilter("%v %d", errUnknownVersion, version)
+	}
+	decoded := BinaryFuse8{
+		Seed:               binary.LittleEndian.Uint64(data[0:]),
+		SegmentLength:      binary.LittleEndian.Uint32(data[8:]),
+		SegmentLengthMask:  binary.LittleEndian.Uint32(data[12:]),
+		SegmentCount:       binary.LittleEndian.Uint32(data[16:]),
+		SegmentCountLength: binary.LittleEndian.Uint32(data[20:]),
+		version:            AlgorithmVersion(version),
+	}
+	data = data[binaryFuse8HeaderSize:]
+	// Check the size before the allocation of the fingerprints.
+	length := binaryFuse8ArrayLength(decoded.SegmentCount, decoded.SegmentLength)
+	if uint64(len(data)) < length {
+		return nil, invalidFilter("%d bytes are too short for %d fingerprints", len(data), length)
+	}
+	if view {
+		decoded.Fingerprints = data[:length:length]
+	} else {
+		decoded.Fingerprints = copyFingerprints(data[:length])
+	}
+	decoded.hasher = filter.hasher
+	decoded.fingerprinter = filter.fingerprinter
+	decoded.salt, decoded.salted = filter.salt, filter.salted
+	if err := decoded.Validate(); err != nil {
+		return nil, err
+	}
+	*filter = decoded
+	return data[length:], nil
+}
+
+// Validate checks that the fields of the filter are consistent, so that
+// Contains cannot index out of the fingerprints.
+func (filter *BinaryFuse8Big) Validate() error {
+	if filter == nil {
+		return errNilFilter
+	}
+	if len(filter.Shards) == 0 {
+		return invalidFilter("no shards")
+	}
+	for i := range filter.Shards {
+		if err := filter.Shards[i].Validate(); err != nil {
+			return fmt.Errorf("shard %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// binarySize returns the size of the binary form of the filter.
+func (filter *BinaryFuse8Big) binarySize() uint64 {
+	size := uint64(8 + 4) // the seed and the number of shards
+	for i := range filter.Shards {
+		size += filter.Shards[i].binarySize()
+	}
+	return size
+}
+
+// MarshalBinary encodes the filter as its seed and number of shards, in
+// little-endian order, followed by the binary form of each shard.
+func (filter *BinaryFuse8Big) MarshalBinary() ([]byte, error) {
+	if filter == nil {
+		return nil, errNilFilter
+	}
+	data := make([]byte, 12, filter.binarySize())
+	binary.LittleEndian.PutUint64(data[0:], filter.Seed)
+	binary.LittleEndian.PutUint32(data[8:], uint32(len(filter.Shards)))
+	for i := range filter.Shards {
+		data = filter.Shards[i].appendBinary(data)
+	}
+	return data, nil
+}
+
+// UnmarshalBinary decodes a filter encoded by MarshalBinary, and validates it.
+// It fails with an error wrapping ErrInvalidFilter for an algorithm version
+// that this release does not know. The fingerprints are copied out of data.
+func (filter *BinaryFuse8Big) UnmarshalBinary(data []byte) error {
+	if filter == nil {
+		return errNilFilter
+	}
+	if len(data) < 12 {
+		return invalidFilter("%d bytes are too short for a BinaryFuse8Big filter", len(data))
+	}
+	seed := binary.LittleEndian.Uint64(data[0:])
+	count := binary.LittleEndian.Uint32(data[8:])
+	data = data[12:]
+	if count == 0 {
+		return invalidFilter("no shards")
+	}
+	if uint64(count) > uint64(len(data))/binaryFuse8HeaderSize {
+		return invalidFilter("%d bytes are too short for %d shards", len(data), count)
+	}
+	shards := make([]BinaryFuse8, count)
+	for i := range shards {
+		var err error
+		if data, err = shards[i].decodeBinary(data, false); err != nil {
+			return fmt.Errorf("shard %d: %w", i, err)
+		}
+	}
+	if len(data) != 0 {
+		return invalidFilter("%d trailing bytes", len(data))
+	}
+	filter.Seed = seed
+	filter.Shards = shards
+	return nil
+}
+
+// Validate checks that the fields of the filter are consistent, so that
+// Contains cannot index out of the fingerprints.
+func (filter *Xor8) Validate() error {
+	if filter == nil {
+		return errNilFilter
+	}
+	if filter.BlockLength == 0 {
+		return invalidFilter("block length is zero")
+	}
+	expected := 3 * uint64(filter.BlockLength)
+	if expected > maxFingerprints {
+		return invalidFilter("%d fingerprints are more than %d", expected, uint64(maxFingerprints))
+	}
+	if uint64(len(filter.Fingerprints)) != expected {
+		return invalidFilter("%d fingerprints instead of %d", len(filter.Fingerprints), expected)
+	}
+	return nil
+}
+
+// MarshalBinary encodes the filter as its seed and block length, in
+// little-endian order, followed by the fingerprints.
+func (filter *Xor8) MarshalBinary() ([]byte, error) {
+	if filter == nil {
+		return nil, errNilFilter
+	}
+	data := make([]byte, 12, filter.SizeInBytes())
+	binary.LittleEndian.PutUint64(data[0:], filter.Seed)
+	binary.LittleEndian.PutUint32(data[8:], filter.BlockLength)
+	return append(data, filter.Fingerprints...), nil
+}
+
+// UnmarshalBinary decodes a filter encoded by MarshalBinary, and validates it.
+// It fails with an error wrapping ErrInvalidFilter for an algorithm version
+// that this release does not know. The fingerprints are copied out of data.
+func (filter *Xor8) UnmarshalBinary(data []byte) error {
+	if filter == nil {
+		return errNilFilter
+	}
+	if len(data) < 12 {
+		return invalidFilter("%d bytes are too short for a Xor8 filter", len(data))
+	}
+	decoded := Xor8{
+		Seed:         binary.LittleEndian.Uint64(data[0:]),
+		BlockLength:  binary.LittleEndian.Uint32(data[8:]),
+		Fingerprints: data[12:],
+	}
+	// Check the size before the copy of the fingerprints.
+	if err := decoded.Validate(); err != nil {
+		return err
+	}
+	decoded.Fingerprints = copyFingerprints(decoded.Fingerprints)
+	*filter = decoded
+	return nil
+}
+
+// Validate checks that the fields of the filter are consistent, so that
+// Contains cannot index out of the fingerprints.
+func (filter *Fuse8) Validate() error {
+	if filter == nil {
+		return errNilFilter
+	}
+	if filter.SegmentLength == 0 {
+		return invalidFilter("segment length is zero")
+	}
+	expected := SLOTS * uint64(filter.SegmentLength)
+	if expected > maxFingerprints {
+		return invalidFilter("%d fingerprints are more than %d", expected, uint64(maxFingerprints))
+	}
+	if uint64(len(filter.Fingerprints)) != expected {
+		return invalidFilter("%d fingerprints instead of %d", len(filter.Fingerprints), expected)
+	}
+	return nil
+}
+
+// MarshalBinary encodes the filter as its seed and segment length, in
+// little-endian order, followed by the fingerprints.
+func (filter *Fuse8) MarshalBinary() ([]byte, error) {
+	if filter == nil {
+		return nil, errNilFilter
+	}
+	data := make([]byte, 12, filter.SizeInBytes())
+	binary.LittleEndian.PutUint64(data[0:], filter.Seed)
+	binary.LittleEndian.PutUint32(data[8:], filter.SegmentLength)
+	return append(data, filter.Fingerprints...), nil
+}
+
+// UnmarshalBinary decodes a filter encoded by MarshalBinary, and validates it.
+// It fails with an error wrapping ErrInvalidFilter for an algorithm version
+// that this release does not know. The fingerprints are copied out of data.
+func (filter *Fuse8) UnmarshalBinary(data []byte) error {
+	if filter == nil {
+		return errNilFilter
+	}
+	if len(data) < 12 {
+		return invalidFilter("%d bytes are too short for a Fuse8 filter", len(data))
+	}
+	decoded := Fuse8{
+		Seed:          binary.LittleEndian.Uint64(data[0:]),
+		SegmentLength: binary.LittleEndian.Uint32(data[8:]),
+		Fingerprints:  data[12:],
+	}
+	// Check the size before the copy of the fingerprints.
+	if err := decoded.Validate(); err != nil {
+		return err
+	}
+	decoded.Fingerprints = copyFingerprints(decoded.Fingerprints)
+	*filter = decoded
+	return nil
+}
@@ -0,0 +1,164 @@
+package xorfilter
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// ErrScratchTooSmall is returned when the temporary memory allowed for a
+// construction, by WithMaxScratchMemory or WithScratchBuffer, cannot hold its
+// arrays, or when that of an external construction cannot hold the
+// construction of even a small shard.
+var ErrScratchTooSmall = errors.New("scratch memory too small for the construction")
+
+// spillBufferSize is the largest write buffer of a temporary file of an
+// external construction.
+const spillBufferSize = 1 << 16
+
+// PopulateBinaryFuse8External fills a BinaryFuse8Big filter with n keys read
+// from r, each encoded as a little-endian uint64, with about scratchBytes
+// bytes of temporary memory. The keys are split among shards small enough for
+// their construction to fit in scratchBytes, and spilled to a temporary file
+// per shard, in dir or in the default directory for temporary files if dir
+// is empty. The shards are then built one at a time from their files, which
+// are removed. The key set can thus be much larger than the memory: only the
+// filter itself, about 9 bits per key, has to fit in it.
+func PopulateBinaryFuse8External(r io.Reader, n uint64, dir string, scratchBytes uint64, opts ...Option) (*BinaryFuse8Big, error) {
+	if uint64(int(n)) != n {
+		return nil, ErrTooManyKeys
+	}
+	if r == nil && n > 0 {
+		return nil, errors.New("nil reader")
+	}
+	cfg := newBuildConfig(opts)
+	shardKeys, err := externalShardKeys(n, scratchBytes, opts)
+	if err != nil {
+		return nil, err
+	}
+	rngcounter := cfg.rngCounter
+	filter := &BinaryFuse8Big{Seed: splitmix64(&rngcounter)}
+	shards := (n + shardKeys - 1) / shardKeys
+	if shards == 0 {
+		shards = 1
+	}
+	filter.Shards = make([]BinaryFuse8, shards)
+
+	files := make([]*os.File, shards)
+	defer func() {
+		for _, f := range files {
+			if f != nil {
+				f.Close()
+				os.Remove(f.Name())
+			}
+		}
+	}()
+	counts, err := filter.spill(r, int(n), dir, files, scratchBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	var p Populator
+	for i, f := range files {
+		if counts[i] > MaxBinaryFuse8Keys {
+			return nil, ErrTooManyKeys
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		src, err := newReaderSource(f, int(counts[i]))
+		if err != nil {
+			return nil, err
+		}
+		shard, err := p.populateBinaryFuse8(context.Background(), int(counts[i]), src, cfg)
+		if err != nil {
+			return nil, err
+		}
+		filter.Shards[i] = *shard
+		f.Close()
+		os.Remove(f.Name())
+		files[i] = nil
+	}
+	return filter, nil
+}
+
+// externalShardKeys returns the number of keys per shard of an external
+// construction: the largest number whose construction fits in scratchBytes,
+// with room for the shards that get more keys than the average and for the
+// write buffers of the temporary files.
+func externalShardKeys(n, scratchBytes uint64, opts []Option) (uint64, error) {
+	fits := func(keys uint64) bool {
+		shards := (n + keys - 1) / keys
+		buffers := shards * spillBufferSize
+		if buffers > scratchBytes/2 {
+			buffers = scratchBytes / 2
+		}
+		return EstimateBinaryFuse8Memory(keys+keys/8, opts...).ScratchBytes+buffers <= scratchBytes
+	}
+	lo, hi := uint64(1024), uint64(bigShardKeys)
+	if !fits(lo) {
+		return 0, ErrScratchTooSmall
+	}
+	for lo < hi {
+		mid := lo + (hi-lo+1)/2
+		if fits(mid) {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return lo, nil
+}
+
+// spill reads the n keys of r and writes each of them to the temporary file
+// of its shard, created in dir. It returns the number of keys of each shard.
+func (filter *BinaryFuse8Big) spill(r io.Reader, n int, dir string, files []*os.File, scratchBytes uint64) ([]uint64, error) {
+	bufferSize := spillBufferSize
+	if perFile := scratchBytes / 2 / uint64(len(files)); perFile < uint64(bufferSize) {
+		bufferSize = int(perFile)
+	}
+	writers := make([]*bufio.Writer, len(files))
+	for i := range files {
+		f, err := ioutil.TempFile(dir, "xorfilter-shard-*")
+		if err != nil {
+			return nil, err
+		}
+		files[i] = f
+		writers[i] = bufio.NewWriterSize(f, bufferSize)
+	}
+	counts := make([]uint64, len(files))
+	chunk := make([]byte, 8*readerChunkSize)
+	var buf [8]byte
+	for read := 0; read < n; {
+		count := n - read
+		if count > readerChunkSize {
+			count = readerChunkSize
+		}
+		if _, err := io.ReadFull(r, chunk[:8*count]); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		for i := 0; i < count; i++ {
+			key := binary.LittleEndian.Uint64(chunk[8*i:])
+			shard := filter.shard(key)
+			binary.LittleEndian.PutUint64(buf[:], key)
+			if _, err := writers[shard].Write(buf[:]); err != nil {
+				return nil, err
+			}
+			counts[shard]++
+		}
+		read += count
+	}
+	for _, w := range writers {
+		if err := w.Flush(); err != nil {
+			return nil, err
+		}
+	}
+	return counts, nil
+}
@@ -0,0 +1,69 @@
+package xorfilter
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrTooManyIterations is the error, wrapped in a ConstructionError, returned
+// when the construction of a filter gives up.
+var ErrTooManyIterations = errors.New("too many iterations")
+
+// A FailureVerdict is a guess at the cause of a failed construction.
+type FailureVerdict int
+
+const (
+	// VerdictBadLuck means that the keys looked distinct: another attempt,
+	// or more of them, should succeed.
+	VerdictBadLuck FailureVerdict = iota
+	// VerdictLikelyDuplicates means that many keys had the same hash, either
+	// because they are duplicates or because the Hasher collides.
+	VerdictLikelyDuplicates
+)
+
+func (v FailureVerdict) String() string {
+	switch v {
+	case VerdictBadLuck:
+		return "bad luck"
+	case VerdictLikelyDuplicates:
+		return "likely duplicates"
+	}
+	return "unknown"
+}
+
+// A ConstructionError describes a construction that gave up after too many
+// attempts. It wraps ErrTooManyIterations.
+type ConstructionError struct {
+	// Attempts is the number of construction attempts.
+	Attempts int
+	// Seeds are the seeds of the attempts, in order.
+	Seeds []uint64
+	// Keys is the number of keys of the construction, after the removal of
+	// the duplicates if there was one.
+	Keys uint32
+	// BestPeeled is the largest number of keys peeled by an attempt.
+	BestPeeled uint32
+	// Duplicates is set when the attempts found keys with the same hash.
+	Duplicates bool
+	// Overflow is set when an attempt had more keys in a slot than the
+	// construction can count, which only happens with massively repeated hashes.
+	Overflow bool
+}
+
+// Verdict guesses why the construction failed.
+func (e *ConstructionError) Verdict() FailureVerdict {
+	if e.Duplicates || e.Overflow {
+		return VerdictLikelyDuplicates
+	}
+	return VerdictBadLuck
+}
+
+func (e *ConstructionError) Error() string {
+	return fmt.Sprintf("too many iterations (%d attempts, at best %d of %d keys peeled): %v",
+		e.Attempts, e.BestPeeled, e.Keys, e.Verdict())
+}
+
+// Unwrap returns ErrTooManyIterations.
+func (e *ConstructionError) Unwrap() error {
+	return ErrTooManyIterations
+}
@@ -0,0 +1,58 @@
+package xorfilter
+
+// A Fingerprinter derives the 8-bit fingerprint of a key from its 64-bit hash.
+// It takes the place of the default derivation, which xors the high half of
+// the hash into its low half, for example to agree with the filters of
+// another implementation or to try another mixing of the bits. The three
+// slots of a key are picked by the same hash, by its high bits and by its low
+// bits from 0 and from 18: a fingerprint that does not depend on other bits as
+// well is more often the same for the keys of the same slots, which raises the
+// false positive rate. The fingerprints of the BinaryFuse8 filters are 8-bit
+// wide; a narrower fingerprint can be had by leaving bits of the result at 0,
+// at the cost of the false positive rate.
+type Fingerprinter interface {
+	Fingerprint(hash uint64) uint8
+}
+
+// FingerprinterFunc adapts a function to the Fingerprinter interface.
+type FingerprinterFunc func(hash uint64) uint8
+
+// Fingerprint returns f(hash).
+func (f FingerprinterFunc) Fingerprint(hash uint64) uint8 {
+	return f(hash)
+}
+
+// fingerprintOf returns the fingerprint of the hash of a key, with the
+// Fingerprinter of the filter if it has one.
+func (filter *BinaryFuse8) fingerprintOf(hash uint64) uint8 {
+	if filter.fingerprinter != nil {
+		return filter.fingerprinter.Fingerprint(hash)
+	}
+	return uint8(fingerprint(hash))
+}
+
+// Fingerprinter returns the Fingerprinter the filter was built with, or nil
+// if it uses the default derivation.
+func (filter *BinaryFuse8) Fingerprinter() Fingerprinter {
+	if filter == nil {
+		return nil
+	}
+	return filter.fingerprinter
+}
+
+// SetFingerprinter sets the Fingerprinter of the filter. Like the Hasher, it
+// is not part of the exported fields, so a filter restored from them must be
+// given the Fingerprinter it was built with again before it is queried.
+func (filter *BinaryFuse8) SetFingerprinter(f Fingerprinter) {
+	filter.fingerprinter = f
+}
+
+// containsFingerprinted is containsHash for the filters with a
+// Fingerprinter, which the inlined containsHash leaves out.
+func (filter *BinaryFuse8) containsFingerprinted(hash uint64) bool {
+	if len(filter.Fingerprints) == 0 {
+		return false
+	}
+	h0, h1, h2 := filter.getHashFromHash(hash)
+	return filter.fingerprinter.Fingerprint(hash)^filter.Fingerprints[h0]^filter.Fingerprints[h1]^filter.Fingerprints[h2] == 0
+}
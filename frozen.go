@@ -0,0 +1,92 @@
+package xorfilter
+
+// A FrozenBinaryFuse8 is a BinaryFuse8 filter that cannot be modified. A
+// BinaryFuse8 filter is safe for concurrent queries as long as nothing
+// modifies its exported fields, but nothing prevents it: a filter shared
+// between goroutines may still be changed by one of them, and the
+// fingerprints of a filter returned by ViewBinaryFuse8 are the bytes of the
+// caller, which may be reused. The fields of a FrozenBinaryFuse8 are
+// unexported, and its fingerprints are its own copy: it is safe for
+// concurrent use without further care. As it was validated once and for
+// all, its queries read the fingerprints without bounds checks.
+type FrozenBinaryFuse8 struct {
+	filter BinaryFuse8
+}
+
+// Freeze returns a FrozenBinaryFuse8 with a copy of the filter, which can then
+// be modified or released without affecting it. It fails if the filter does
+// not pass Validate.
+func (filter *BinaryFuse8) Freeze() (*FrozenBinaryFuse8, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	return &FrozenBinaryFuse8{filter: *filter.Clone()}, nil
+}
+
+// Contains returns `true` if key is part of the set with a false positive
+// probability of <0.4%.
+func (frozen *FrozenBinaryFuse8) Contains(key uint64) bool {
+	return frozen.filter.ContainsUnchecked(key)
+}
+
+// ContainsUint32 is the ContainsUint32 method of the filter.
+func (frozen *FrozenBinaryFuse8) ContainsUint32(key uint32) bool {
+	return frozen.Contains(uint64(key))
+}
+
+// ContainsString is the ContainsString method of the filter.
+func (frozen *FrozenBinaryFuse8) ContainsString(key string) bool {
+	return frozen.Contains(hashString(key, stringSeed))
+}
+
+// ContainsBytes is the ContainsBytes method of the filter.
+func (frozen *FrozenBinaryFuse8) ContainsBytes(key []byte) bool {
+	return frozen.Contains(hashBytes(key, stringSeed))
+}
+
+// Contains128 is the Contains128 method of the filter.
+func (frozen *FrozenBinaryFuse8) Contains128(key [16]byte) bool {
+	return frozen.filter.Contains128(key)
+}
+
+// ContainsUUID is the ContainsUUID method of the filter.
+func (frozen *FrozenBinaryFuse8) ContainsUUID(uuid [16]byte) bool {
+	return frozen.filter.ContainsUUID(uuid)
+}
+
+// ContainsHashed is the ContainsHashed method of the filter.
+func (frozen *FrozenBinaryFuse8) ContainsHashed(hash uint64) bool {
+	return frozen.filter.ContainsHashed(hash)
+}
+
+// ContainsBatch is the ContainsBatch method of the filter.
+func (frozen *FrozenBinaryFuse8) ContainsBatch(keys []uint64, out []bool) {
+	frozen.filter.ContainsBatch(keys, out)
+}
+
+// CountContained is the CountContained method of the filter.
+func (frozen *FrozenBinaryFuse8) CountContained(keys []uint64) int {
+	return frozen.filter.CountContained(keys)
+}
+
+// FalsePositiveRate returns the theoretical probability that Contains returns
+// true for a key that is not in the set: one in 2^8 with 8-bit fingerprints.
+func (frozen *FrozenBinaryFuse8) FalsePositiveRate() float64 {
+	return frozen.filter.FalsePositiveRate()
+}
+
+// SizeInBytes returns the size of the serialized filter.
+func (frozen *FrozenBinaryFuse8) SizeInBytes() uint64 {
+	return frozen.filter.SizeInBytes()
+}
+
+// MarshalBinary encodes the filter as BinaryFuse8.MarshalBinary does.
+func (frozen *FrozenBinaryFuse8) MarshalBinary() ([]byte, error) {
+	return frozen.filter.MarshalBinary()
+}
+
+// BinaryFuse8 returns a copy of the filter, which can be modified without
+// affecting frozen.
+func (frozen *FrozenBinaryFuse8) BinaryFuse8() *BinaryFuse8 {
+	return frozen.filter.Clone()
+}
@@ -1,7 +1,9 @@
 package xorfilter
 
 import (
+	"bytes"
 	"errors"
+	"unsafe"
 )
 
 // The Fuse8 xor filter uses 8-bit fingerprints. It offers the same <0.4% false-positive probability
@@ -31,6 +33,9 @@ const SLOTS = SEGMENT_COUNT + ARITY - 1
 
 // Contains returns `true` if key is part of the set with a false positive probability of <0.4%.
 func (filter *Fuse8) Contains(key uint64) bool {
+	if filter == nil || len(filter.Fingerprints) == 0 {
+		return false
+	}
 	hash := mixsplit(key, filter.Seed)
 	f := uint8(fingerprint(hash))
 	r0 := uint32(hash)
@@ -73,27 +78,40 @@ func (filter *Fuse8) geth012(hash uint64) h012 {
 	return answer
 }
 
+// MaxFuse8Keys is the largest number of keys of a Fuse8 filter, whose sizes
+// are 32-bit. Larger sets go into a BinaryFuse8Big filter.
+const MaxFuse8Keys = 3 << 30
+
+// fuse8Capacity returns the number of fingerprints of a Fuse8 filter of size
+// keys, a multiple of SLOTS, or ErrTooManyKeys if there are more than
+// MaxFuse8Keys keys.
+func fuse8Capacity(size int) (uint32, error) {
+	const FUSE_OVERHEAD = 1.0 / 0.879
+	const FUSE_CONSTANT = 1024 // todo: determine value
+	if size < 0 || uint64(size) > MaxFuse8Keys {
+		return 0, ErrTooManyKeys
+	}
+	capacity := uint32(FUSE_OVERHEAD*float64(size) + FUSE_CONSTANT)
+	return capacity / SLOTS * SLOTS, nil
+}
+
 // Populate fills a Fuse8 filter with provided keys.
 // The caller is responsible for ensuring there are no duplicate keys provided.
 // The function may return an error after too many iterations: it is almost
 // surely an indication that you have duplicate keys.
 func PopulateFuse8(keys []uint64) (*Fuse8, error) {
 
-	const FUSE_OVERHEAD = 1.0 / 0.879
-	const FUSE_CONSTANT = 1024 // todo: determine value
 	// ref: Algorithm 3
 	size := len(keys)
-	if size == 0 {
-		return nil, errors.New("provide a non-empty set")
+	capacity, err := fuse8Capacity(size)
+	if err != nil {
+		return nil, err
 	}
-
-	capacity := uint32(FUSE_OVERHEAD*float64(size) + FUSE_CONSTANT)
-	capacity = capacity / SLOTS * SLOTS
 	rngcounter := uint64(1)
 
 	filter := &Fuse8{}
 	filter.SegmentLength = capacity / SLOTS
-	filter.Fingerprints = make([]uint8, capacity, capacity)
+	filter.Fingerprints = makeFingerprints(int(capacity), cacheLineSize)
 	filter.Seed = splitmix64(&rngcounter)
 
 	H := make([]xorset, capacity, capacity)
@@ -199,3 +217,55 @@ func PopulateFuse8(keys []uint64) (*Fuse8, error) {
 
 	return filter, nil
 }
+
+// SizeInBytes returns the size of the exported fields of the filter, which
+// is what needs to be serialized.
+func (filter *Fuse8) SizeInBytes() uint64 {
+	if filter == nil {
+		return 0
+	}
+	return 8 + 4 + uint64(len(filter.Fingerprints))
+}
+
+// MemoryBytes returns the memory held by the filter.
+func (filter *Fuse8) MemoryBytes() uint64 {
+	if filter == nil {
+		return 0
+	}
+	return uint64(unsafe.Sizeof(*filter)) + uint64(cap(filter.Fingerprints))
+}
+
+// BitsPerEntry returns the size of the fingerprints, in bits per key, for a
+// filter built from n distinct keys.
+func (filter *Fuse8) BitsPerEntry(n int) float64 {
+	if filter == nil {
+		return 0
+	}
+	return float64(8*len(filter.Fingerprints)) / float64(n)
+}
+
+// FalsePositiveRate returns the theoretical probability that Contains returns
+// true for a key that is not in the set: one in 2^8 with 8-bit fingerprints.
+func (filter *Fuse8) FalsePositiveRate() float64 {
+	return 1.0 / 256
+}
+
+// Clone returns a copy of the filter that does not share its fingerprints.
+func (filter *Fuse8) Clone() *Fuse8 {
+	if filter == nil {
+		return nil
+	}
+	clone := *filter
+	clone.Fingerprints = copyFingerprints(filter.Fingerprints)
+	return &clone
+}
+
+// Equal reports whether the filters have the same seed, parameters and
+// fingerprints.
+func (filter *Fuse8) Equal(other *Fuse8) bool {
+	if filter == nil || other == nil {
+		return filter == other
+	}
+	return filter.Seed == other.Seed && filter.SegmentLength == other.SegmentLength &&
+		bytes.Equal(filter.Fingerprints, other.Fingerprints)
+}
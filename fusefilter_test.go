@@ -150,3 +150,47 @@ func BenchmarkFuse8Contains50000000(b *testing.B) {
 		fusedbig.Contains(rand.Uint64())
 	}
 }
+
+func TestFuse8Tiny(t *testing.T) {
+	for n := 0; n < 8; n++ {
+		keys := make([]uint64, n)
+		for i := range keys {
+			keys[i] = rand.Uint64()
+		}
+		filter, err := PopulateFuse8(keys)
+		assert.Equal(t, nil, err)
+		for _, v := range keys {
+			assert.Equal(t, true, filter.Contains(v))
+		}
+	}
+}
+
+func TestFuse8Validate(t *testing.T) {
+	keys := make([]uint64, SMALL_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, err := PopulateFuse8(keys)
+	assert.Equal(t, nil, err)
+	data, err := filter.MarshalBinary()
+	assert.Equal(t, nil, err)
+	var decoded Fuse8
+	assert.Equal(t, nil, decoded.UnmarshalBinary(data))
+	assert.True(t, filter.Equal(&decoded))
+	assert.NotNil(t, decoded.UnmarshalBinary(data[:len(data)-1]))
+	filter.SegmentLength++
+	assert.NotNil(t, filter.Validate())
+}
+
+func TestFuse8TooManyKeys(t *testing.T) {
+	_, err := fuse8Capacity(-1)
+	assert.Equal(t, ErrTooManyKeys, err)
+	if n := uint64(MaxFuse8Keys); int(n+1) > 0 {
+		capacity, err := fuse8Capacity(int(n))
+		assert.Equal(t, nil, err)
+		assert.True(t, uint64(capacity) > n)
+		assert.Equal(t, uint32(0), capacity%SLOTS)
+		_, err = fuse8Capacity(int(n + 1))
+		assert.Equal(t, ErrTooManyKeys, err)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build go1.18
+// +build go1.18
+
+package hash
+
+import (
+	"encoding/binary"
+	"net/netip"
+)
+
+// Addr returns the 64-bit key of an IP address, the same as IP for the
+// net.IP of the address. An IPv4 address has the same key as its
+// IPv4-mapped IPv6 form, the zone of an address is ignored, and the zero
+// Addr has the key of a nil IP.
+func Addr(addr netip.Addr) uint64 {
+	if !addr.IsValid() {
+		return WyHashBytes(nil, ipSeed)
+	}
+	ip := addr.As16()
+	return WyHash128(binary.BigEndian.Uint64(ip[:8]), binary.BigEndian.Uint64(ip[8:]), ipSeed)
+}
@@ -0,0 +1,22 @@
+//go:build go1.18
+// +build go1.18
+
+package hash_test
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/FastFilter/xorfilter/hash"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddr(t *testing.T) {
+	for _, s := range []string{"192.0.2.1", "::ffff:192.0.2.1", "2001:db8::1", "::"} {
+		assert.Equal(t, hash.IP(net.ParseIP(s)), hash.Addr(netip.MustParseAddr(s)), s)
+	}
+	assert.Equal(t, hash.Addr(netip.MustParseAddr("fe80::1")), hash.Addr(netip.MustParseAddr("fe80::1%eth0")))
+	assert.Equal(t, hash.IP(nil), hash.Addr(netip.Addr{}))
+	assert.NotEqual(t, hash.Addr(netip.MustParseAddr("::")), hash.Addr(netip.Addr{}))
+}
@@ -0,0 +1,176 @@
+// Package hash holds the hash functions of the xorfilter package, for the
+// applications that hash their keys themselves and need the same results as
+// the filters.
+//
+// The filters take 64-bit keys. A key is mixed with the seed of a filter by
+// MixSplit, or by MixHashed for the filters built from keys that are already
+// hashes, and the seeds of the successive attempts of a construction are
+// drawn from SplitMix64. The string, byte and 128-bit keys are first hashed
+// to 64-bit keys by String, Bytes and Uint128: a filter built from the keys
+// String(s) contains s for ContainsString, and likewise for the others.
+//
+// UUID, IP, Addr, Int64Pair and Tenant encode the keys of common types, each
+// with its own seed, so that the keys of different types do not collide
+// where their bytes are the same. UUID is the key of Contains128.
+//
+// These functions are part of the format of the filters: their results never
+// change from one release to the next.
+package hash
+
+import (
+	"math/bits"
+	"unsafe"
+)
+
+// Murmur64 is the 64-bit finalizer of MurmurHash3: every bit of h affects
+// every bit of the result.
+func Murmur64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// SplitMix64 advances the state of the SplitMix64 generator and returns its
+// next number. The filters draw their seeds from it, the state starting at
+// the counter of WithRNGCounter.
+func SplitMix64(state *uint64) uint64 {
+	*state = *state + 0x9E3779B97F4A7C15
+	z := *state
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+	return z ^ (z >> 31)
+}
+
+// MixSplit mixes key with seed into the 64-bit hash from which a filter picks
+// the slots and the fingerprint of the key. It is the default Hasher of the
+// filters.
+func MixSplit(key, seed uint64) uint64 {
+	return Murmur64(key + seed)
+}
+
+// MixHashed is the mixing function of the filters built from keys that are
+// already hashes: a single xor-shift-multiply round of Murmur64, which is
+// enough to spread the seed over the bits used by the filter.
+func MixHashed(h, seed uint64) uint64 {
+	h ^= seed
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	return h
+}
+
+// Fingerprint returns the default 8-bit fingerprint of the hash of a key: its
+// high half xored into its low half, truncated.
+func Fingerprint(h uint64) uint8 {
+	return uint8(h ^ h>>32)
+}
+
+// The constants of wyhash, see https://github.com/wangyi-fudan/wyhash.
+const (
+	wyp0 = 0xa0761d6478bd642f
+	wyp1 = 0xe7037ed1a0b428db
+	wyp2 = 0x8ebc6af09c88c6e3
+	wyp3 = 0x589965cc75374cc3
+	wyp4 = 0x1d8e4e27c47d124f
+)
+
+// StringSeed is the seed with which the string, byte and 128-bit keys are
+// hashed to 64-bit keys.
+const StringSeed = 0
+
+// WyMix multiplies a and b to 128 bits and folds the product in 64 bits, the
+// mixing step of wyhash.
+func WyMix(a, b uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	return hi ^ lo
+}
+
+func wyr8(s string, i int) uint64 {
+	return uint64(s[i]) | uint64(s[i+1])<<8 | uint64(s[i+2])<<16 | uint64(s[i+3])<<24 |
+		uint64(s[i+4])<<32 | uint64(s[i+5])<<40 | uint64(s[i+6])<<48 | uint64(s[i+7])<<56
+}
+
+func wyr4(s string, i int) uint64 {
+	return uint64(s[i]) | uint64(s[i+1])<<8 | uint64(s[i+2])<<16 | uint64(s[i+3])<<24
+}
+
+// WyHash hashes s to 64 bits with the wyhash variant used by the Go runtime.
+// The result only depends on the bytes of s and on seed.
+func WyHash(s string, seed uint64) uint64 {
+	var a, b uint64
+	n := len(s)
+	seed ^= wyp0
+	switch {
+	case n == 0:
+		return seed
+	case n < 4:
+		a = uint64(s[0]) | uint64(s[n>>1])<<8 | uint64(s[n-1])<<16
+	case n == 4:
+		a = wyr4(s, 0)
+		b = a
+	case n < 8:
+		a = wyr4(s, 0)
+		b = wyr4(s, n-4)
+	case n == 8:
+		a = wyr8(s, 0)
+		b = a
+	case n <= 16:
+		a = wyr8(s, 0)
+		b = wyr8(s, n-8)
+	default:
+		i, l := 0, n
+		if l > 48 {
+			seed1, seed2 := seed, seed
+			for ; l > 48; l -= 48 {
+				seed = WyMix(wyr8(s, i)^wyp1, wyr8(s, i+8)^seed)
+				seed1 = WyMix(wyr8(s, i+16)^wyp2, wyr8(s, i+24)^seed1)
+				seed2 = WyMix(wyr8(s, i+32)^wyp3, wyr8(s, i+40)^seed2)
+				i += 48
+			}
+			seed ^= seed1 ^ seed2
+		}
+		for ; l > 16; l -= 16 {
+			seed = WyMix(wyr8(s, i)^wyp1, wyr8(s, i+8)^seed)
+			i += 16
+		}
+		a = wyr8(s, i+l-16)
+		b = wyr8(s, i+l-8)
+	}
+	return WyMix(wyp4^uint64(n), WyMix(a^wyp1, b^seed))
+}
+
+// WyHashBytes is WyHash for a byte slice: a slice and a string with the same
+// bytes have the same hash. b is not copied.
+func WyHashBytes(b []byte, seed uint64) uint64 {
+	return WyHash(*(*string)(unsafe.Pointer(&b)), seed)
+}
+
+// WyHash128 is WyHashBytes for the 16 bytes of hi and lo in big-endian order,
+// that is the byte order of a UUID, without the bytes.
+func WyHash128(hi, lo, seed uint64) uint64 {
+	a := bits.ReverseBytes64(hi)
+	b := bits.ReverseBytes64(lo)
+	return WyMix(wyp4^16, WyMix(a^wyp1, b^seed^wyp0))
+}
+
+// String returns the 64-bit key of the string key s, as hashed by
+// PopulateBinaryFuse8FromStrings and ContainsString.
+func String(s string) uint64 {
+	return WyHash(s, StringSeed)
+}
+
+// Bytes returns the 64-bit key of the binary key b, as hashed by
+// PopulateBinaryFuse8FromBytes and ContainsBytes. It is String for the same
+// bytes.
+func Bytes(b []byte) uint64 {
+	return WyHashBytes(b, StringSeed)
+}
+
+// Uint128 returns the 64-bit key of the 128-bit key of big-endian halves hi
+// and lo, as hashed by PopulateBinaryFuse8From128 and Contains128. It is
+// Bytes of the 16 bytes of the key.
+func Uint128(hi, lo uint64) uint64 {
+	return WyHash128(hi, lo, StringSeed)
+}
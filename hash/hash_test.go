@@ -0,0 +1,119 @@
+package hash_test
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/FastFilter/xorfilter"
+	"github.com/FastFilter/xorfilter/hash"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixedResults(t *testing.T) {
+	// The results are part of the format of the filters and must never
+	// change.
+	state := uint64(0)
+	assert.Equal(t, uint64(0xe220a8397b1dcdaf), hash.SplitMix64(&state))
+	assert.Equal(t, uint64(0x9e3779b97f4a7c15), state)
+	assert.Equal(t, uint64(0xb456bcfc34c2cb2c), hash.Murmur64(1))
+	assert.Equal(t, hash.Murmur64(3), hash.MixSplit(1, 2))
+	assert.Equal(t, uint64(0xfdf50f87c800a667), hash.MixHashed(1, 2))
+	assert.Equal(t, uint64(0xa0761d6478bd642f), hash.String(""))
+	assert.Equal(t, uint64(0xf74919ca5282e936), hash.String("xorfilter"))
+}
+
+func TestStringKeys(t *testing.T) {
+	keys := make([]string, 1000)
+	hashes := make([]uint64, len(keys))
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+		hashes[i] = hash.String(keys[i])
+		assert.Equal(t, hashes[i], hash.Bytes([]byte(keys[i])))
+	}
+	filter, err := xorfilter.PopulateBinaryFuse8(hashes)
+	assert.Equal(t, nil, err)
+	expected, err := xorfilter.PopulateBinaryFuse8FromStrings(keys)
+	assert.Equal(t, nil, err)
+	assert.True(t, filter.Equal(expected))
+	for _, key := range keys {
+		assert.True(t, filter.ContainsString(key))
+		assert.True(t, filter.ContainsBytes([]byte(key)))
+	}
+}
+
+func TestUint128(t *testing.T) {
+	var key [16]byte
+	for i := range key {
+		key[i] = byte(i)
+	}
+	hi, lo := binary.BigEndian.Uint64(key[:8]), binary.BigEndian.Uint64(key[8:])
+	assert.Equal(t, hash.Bytes(key[:]), hash.Uint128(hi, lo))
+	filter, err := xorfilter.PopulateBinaryFuse8([]uint64{hash.Uint128(hi, lo)})
+	assert.Equal(t, nil, err)
+	assert.True(t, filter.Contains128(key))
+}
+
+func TestDefaults(t *testing.T) {
+	keys := make([]uint64, 1000)
+	for i := range keys {
+		keys[i] = uint64(i) * 0x9e3779b97f4a7c15
+	}
+	expected, err := xorfilter.PopulateBinaryFuse8(keys)
+	assert.Equal(t, nil, err)
+	filter, err := xorfilter.PopulateBinaryFuse8(keys,
+		xorfilter.WithHasher(xorfilter.HasherFunc(hash.MixSplit)),
+		xorfilter.WithFingerprinter(xorfilter.FingerprinterFunc(hash.Fingerprint)))
+	assert.Equal(t, nil, err)
+	assert.True(t, filter.Equal(expected))
+	state := uint64(1)
+	assert.Equal(t, hash.SplitMix64(&state), expected.Seed)
+}
+
+func TestKeyEncodings(t *testing.T) {
+	var id [16]byte
+	for i := range id {
+		id[i] = byte(i)
+	}
+	assert.Equal(t, hash.Bytes(id[:]), hash.UUID(id))
+	filter, err := xorfilter.PopulateBinaryFuse8([]uint64{hash.UUID(id)})
+	assert.Equal(t, nil, err)
+	assert.True(t, filter.Contains128(id))
+
+	v4 := net.ParseIP("192.0.2.1")
+	assert.Equal(t, hash.IP(v4), hash.IP(v4.To4()))
+	assert.Equal(t, hash.IP(v4), hash.IP(net.ParseIP("::ffff:192.0.2.1")))
+	assert.NotEqual(t, hash.IP(v4), hash.IP(net.ParseIP("192.0.2.2")))
+	assert.NotEqual(t, hash.IP(v4), hash.IP(net.ParseIP("2001:db8::1")))
+	assert.NotEqual(t, hash.IP(v4), hash.IP(nil))
+	// The same 16 bytes as a UUID and an IPv6 address are different keys.
+	assert.NotEqual(t, hash.UUID(id), hash.IP(net.IP(id[:])))
+
+	assert.NotEqual(t, hash.Int64Pair(1, 2), hash.Int64Pair(2, 1))
+	assert.NotEqual(t, hash.Int64Pair(-1, 0), hash.Int64Pair(0, -1))
+	assert.NotEqual(t, hash.Int64Pair(1, 2), hash.Uint128(1, 2))
+
+	assert.NotEqual(t, hash.Tenant("a", 1), hash.Tenant("b", 1))
+	assert.NotEqual(t, hash.Tenant("a", 1), hash.Tenant("a", 2))
+	assert.NotEqual(t, hash.Tenant("ab", 1), hash.Tenant("a", 1))
+	assert.NotEqual(t, hash.Tenant("", 0), hash.Int64Pair(0, 0))
+
+	// The encodings are part of the format of the filters.
+	assert.Equal(t, uint64(0x7cd174e94ddebce1), hash.IP(v4))
+	assert.Equal(t, uint64(0xeb8dd5e94bc3f6ee), hash.Int64Pair(1, 2))
+	assert.Equal(t, uint64(0x255c62c7d3e33921), hash.Tenant("acme", 42))
+
+	// Distinct values of each type make distinct keys.
+	keys := make(map[uint64]bool)
+	for i := 0; i < 1000; i++ {
+		for _, key := range []uint64{
+			hash.IP(net.IPv4(10, 0, byte(i>>8), byte(i))),
+			hash.Int64Pair(int64(i), int64(-i)),
+			hash.Tenant(fmt.Sprintf("tenant-%d", i%10), uint64(i)),
+		} {
+			assert.False(t, keys[key])
+			keys[key] = true
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package hash
+
+import (
+	"encoding/binary"
+	"net"
+)
+
+// The seeds of the key encodings other than UUID. Each encoding hashes under
+// its own seed, so that the keys of different types do not collide when
+// their bytes happen to be the same. They are part of the format of the
+// filters built from such keys and must never change.
+const (
+	ipSeed     = 1
+	pairSeed   = 2
+	tenantSeed = 3
+)
+
+// UUID returns the 64-bit key of a UUID, or any 16-byte identifier, as hashed
+// by PopulateBinaryFuse8From128 and Contains128. It is Bytes(id[:]).
+func UUID(id [16]byte) uint64 {
+	return Uint128(binary.BigEndian.Uint64(id[:8]), binary.BigEndian.Uint64(id[8:]))
+}
+
+// IP returns the 64-bit key of an IP address. An IPv4 address has the same key
+// in its 4-byte and its 16-byte forms, and the same as the IPv4-mapped IPv6
+// address ::ffff:a.b.c.d, which net.ParseIP does not tell apart. A slice of
+// another length, such as a nil IP, is hashed as its bytes.
+func IP(ip net.IP) uint64 {
+	if ip16 := ip.To16(); ip16 != nil {
+		return WyHash128(binary.BigEndian.Uint64(ip16[:8]), binary.BigEndian.Uint64(ip16[8:]), ipSeed)
+	}
+	return WyHashBytes(ip, ipSeed)
+}
+
+// Int64Pair returns the 64-bit key of the ordered pair (a, b), such as a
+// composite primary key: the pairs (a, b) and (b, a) have different keys.
+func Int64Pair(a, b int64) uint64 {
+	return WyHash128(uint64(a), uint64(b), pairSeed)
+}
+
+// Tenant returns the 64-bit key of the identifier id within tenant, for the
+// filters that hold the keys of several tenants, whose identifiers overlap.
+// The tenant is hashed to 64 bits before it is mixed with id, so that the
+// composites do not collide when a tenant is a prefix of another, as they do
+// when the tenant and the identifier are concatenated.
+func Tenant(tenant string, id uint64) uint64 {
+	return WyHash128(WyHash(tenant, tenantSeed), id, tenantSeed)
+}
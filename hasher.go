@@ -0,0 +1,72 @@
+package xorfilter
+
+import "github.com/FastFilter/xorfilter/hash"
+
+// A Hasher mixes a key with the seed of a filter into a 64-bit hash. It takes
+// the place of the default mixing function, for example to use a keyed hash
+// or to agree with the hashes computed by another system. Both the seed and
+// the key must affect every bit of the result: the construction retries with
+// new seeds until the hashes of the keys fit.
+type Hasher interface {
+	Hash(key, seed uint64) uint64
+}
+
+// HasherFunc adapts a function to the Hasher interface.
+type HasherFunc func(key, seed uint64) uint64
+
+// Hash returns f(key, seed).
+func (f HasherFunc) Hash(key, seed uint64) uint64 {
+	return f(key, seed)
+}
+
+// hash mixes key with the seed of the filter, with its Hasher if it has one,
+// after the salt of its namespace if it has one.
+func (filter *BinaryFuse8) hash(key uint64) uint64 {
+	if filter.salted {
+		key = filter.saltKey(key)
+	}
+	if filter.hasher != nil {
+		return filter.hasher.Hash(key, filter.Seed)
+	}
+	return mixsplit(key, filter.Seed)
+}
+
+// hashKeys sets hashes[i] to the hash of keys[i], as hash does. The default
+// mixing function is vectorized on the CPUs that support it.
+func (filter *BinaryFuse8) hashKeys(hashes, keys []uint64) {
+	hashes = hashes[:len(keys)]
+	if filter.hasher != nil || filter.salted {
+		for i, key := range keys {
+			hashes[i] = filter.hash(key)
+		}
+		return
+	}
+	i := mixsplitKernel(hashes, keys, filter.Seed)
+	for ; i < len(keys); i++ {
+		hashes[i] = mixsplit(keys[i], filter.Seed)
+	}
+}
+
+// Hasher returns the Hasher the filter was built with, or nil if it uses the
+// default mixing function.
+func (filter *BinaryFuse8) Hasher() Hasher {
+	if filter == nil {
+		return nil
+	}
+	return filter.hasher
+}
+
+// SetHasher sets the Hasher of the filter. The Hasher is not part of the
+// exported fields, so a filter restored from them must be given the Hasher it
+// was built with again before it is queried.
+func (filter *BinaryFuse8) SetHasher(h Hasher) {
+	filter.hasher = h
+}
+
+// mixhashed is the mixing function of the keys that are already hashes.
+func mixhashed(h, seed uint64) uint64 {
+	return hash.MixHashed(h, seed)
+}
+
+// hashedHasher is the Hasher of the filters built from hashes.
+var hashedHasher = HasherFunc(mixhashed)
@@ -0,0 +1,202 @@
+// Package hibp builds filters of the password hashes of Have I Been Pwned,
+// https://haveibeenpwned.com/Passwords, to check passwords against the
+// breached ones offline, in about 9 bits per hash instead of the 40 GB of the
+// lists:
+//
+//	filter, err := hibp.Load(file, hibp.SHA1) // pwnedpasswords.txt
+//	if filter.ContainsPassword("hunter2") { ... }
+//
+// The lists are text, with a line per hash, in hexadecimal, and its count,
+// such as "000000005AD76BD555C1D6D771DE417A4B87E4B4:10". The hashes are
+// uniformly distributed, so the key of a hash is its first 64 bits: a
+// password is hashed the same way to query the filter.
+package hibp
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"unicode/utf16"
+
+	"github.com/FastFilter/xorfilter"
+)
+
+// HashType is the type of the hashes of a list.
+type HashType int
+
+const (
+	// SHA1 is the SHA-1 of the UTF-8 password.
+	SHA1 HashType = iota
+	// NTLM is the MD4 of the UTF-16LE password, the hash of Windows.
+	NTLM
+)
+
+// hexLength returns the length of a hash of the type, in hexadecimal.
+func (t HashType) hexLength() int {
+	if t == NTLM {
+		return 32
+	}
+	return 40
+}
+
+func (t HashType) String() string {
+	switch t {
+	case SHA1:
+		return "SHA1"
+	case NTLM:
+		return "NTLM"
+	}
+	return "HashType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// PasswordKey returns the key of a password, the first 64 bits of its hash.
+func (t HashType) PasswordKey(password string) uint64 {
+	if t == NTLM {
+		units := utf16.Encode([]rune(password))
+		data := make([]byte, 2*len(units))
+		for i, u := range units {
+			binary.LittleEndian.PutUint16(data[2*i:], u)
+		}
+		sum := md4Sum(data)
+		return binary.BigEndian.Uint64(sum[:8])
+	}
+	sum := sha1.Sum([]byte(password))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// HashKey returns the key of a hash of the type, in hexadecimal, the first
+// 64 bits of the hash.
+func (t HashType) HashKey(hash string) (uint64, error) {
+	if len(hash) != t.hexLength() || !isHex(hash) {
+		return 0, fmt.Errorf("hibp: %q is not a %v hash", hash, t)
+	}
+	return strconv.ParseUint(hash[:16], 16, 64)
+}
+
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// ReadKeys calls fn with the key of each hash of a list of the type, read
+// from r. The keys of the hashes that follow one another with the same first
+// 64 bits are only passed once: the lists are sorted by hash, so that fn gets
+// distinct keys, in increasing order.
+func ReadKeys(r io.Reader, t HashType, fn func(key uint64) error) error {
+	scanner := bufio.NewScanner(r)
+	line := 0
+	first := true
+	prev := uint64(0)
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		if i := bytes.IndexByte(text, ':'); i >= 0 {
+			text = text[:i]
+		}
+		key, err := t.HashKey(string(text))
+		if err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+		if !first && key == prev {
+			continue
+		}
+		first = false
+		prev = key
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// A Filter is a filter of the hashes of a list.
+type Filter struct {
+	*xorfilter.BinaryFuse8Big
+	// Type is the type of the hashes.
+	Type HashType
+}
+
+// ContainsPassword returns whether the hash of password may be in the list:
+// it is true if it is, and false otherwise but for about 0.4% of the
+// passwords.
+func (f *Filter) ContainsPassword(password string) bool {
+	return f.Contains(f.Type.PasswordKey(password))
+}
+
+// ContainsHash is ContainsPassword for the hash of a password, in
+// hexadecimal.
+func (f *Filter) ContainsHash(hash string) (bool, error) {
+	key, err := f.Type.HashKey(hash)
+	if err != nil {
+		return false, err
+	}
+	return f.Contains(key), nil
+}
+
+// Load builds the filter of a list of the type, read from r. It holds the
+// keys in memory, 8 bytes per hash: LoadExternal needs much less.
+func Load(r io.Reader, t HashType, opts ...xorfilter.Option) (*Filter, error) {
+	var keys []uint64
+	err := ReadKeys(r, t, func(key uint64) error {
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	filter, err := xorfilter.PopulateBinaryFuse8Big(keys, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{BinaryFuse8Big: filter, Type: t}, nil
+}
+
+// LoadExternal is Load with about scratchBytes bytes of temporary memory: the
+// keys are written to a temporary file in dir, or in the default directory
+// for temporary files if dir is empty, and the filter is built from the file
+// by PopulateBinaryFuse8External, which spills them to more files in dir.
+func LoadExternal(r io.Reader, t HashType, dir string, scratchBytes uint64, opts ...xorfilter.Option) (*Filter, error) {
+	file, err := ioutil.TempFile(dir, "hibp-*.keys")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	w := bufio.NewWriter(file)
+	n := uint64(0)
+	var word [8]byte
+	err = ReadKeys(r, t, func(key uint64) error {
+		binary.LittleEndian.PutUint64(word[:], key)
+		n++
+		_, err := w.Write(word[:])
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	filter, err := xorfilter.PopulateBinaryFuse8External(bufio.NewReader(file), n, dir, scratchBytes, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{BinaryFuse8Big: filter, Type: t}, nil
+}
@@ -0,0 +1,86 @@
+package hibp
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMD4(t *testing.T) {
+	// The test suite of RFC 1320.
+	for in, out := range map[string]string{
+		"":                           "31d6cfe0d16ae931b73c59d7e0c089c0",
+		"a":                          "bde52cb31de33e46245e05fbdbd6fb24",
+		"abc":                        "a448017aaf21d8525fc10ae87aa6729d",
+		"message digest":             "d9130a8164549fe818874806e1c7014b",
+		"abcdefghijklmnopqrstuvwxyz": "d79e1c308aa5bbcdeea8ed63df412da9",
+		"12345678901234567890123456789012345678901234567890123456789012345678901234567890": "e33b4ddc9c38f2199c3e7b164fcc0536",
+	} {
+		sum := md4Sum([]byte(in))
+		assert.Equal(t, out, hex.EncodeToString(sum[:]), in)
+	}
+}
+
+func TestPasswordKey(t *testing.T) {
+	// The NTLM hash of "password".
+	key, err := NTLM.HashKey("8846F7EAEE8FB117AD06BDD830B7586C")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, key, NTLM.PasswordKey("password"))
+	key, err = SHA1.HashKey("5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, key, SHA1.PasswordKey("password"))
+	for _, hash := range []string{"", "5BAA61E4", "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FDZ", "8846F7EAEE8FB117AD06BDD830B7586C"} {
+		_, err := SHA1.HashKey(hash)
+		assert.NotEqual(t, nil, err, hash)
+	}
+}
+
+// list returns a list of the SHA-1 hashes of n passwords, sorted by hash.
+func list(n int) ([]string, string) {
+	passwords := make([]string, n)
+	lines := make([]string, n)
+	for i := range passwords {
+		passwords[i] = fmt.Sprintf("password%d", i)
+		sum := sha1.Sum([]byte(passwords[i]))
+		lines[i] = strings.ToUpper(hex.EncodeToString(sum[:])) + fmt.Sprintf(":%d\r\n", i+1)
+	}
+	sort.Strings(lines)
+	return passwords, strings.Join(lines, "")
+}
+
+func TestLoad(t *testing.T) {
+	passwords, text := list(10000)
+	filter, err := Load(strings.NewReader(text), SHA1)
+	assert.Equal(t, nil, err)
+	dir, err := ioutil.TempDir("", "hibp")
+	assert.Equal(t, nil, err)
+	defer os.RemoveAll(dir)
+	external, err := LoadExternal(strings.NewReader(text), SHA1, dir, 1<<16)
+	assert.Equal(t, nil, err)
+	for _, password := range passwords {
+		assert.True(t, filter.ContainsPassword(password))
+		assert.True(t, external.ContainsPassword(password))
+	}
+	found, err := filter.ContainsHash(text[:40])
+	assert.Equal(t, nil, err)
+	assert.True(t, found)
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if filter.ContainsPassword(fmt.Sprintf("other%d", i)) {
+			falsePositives++
+		}
+	}
+	assert.True(t, falsePositives < 100, "%d false positives", falsePositives)
+	files, _ := ioutil.ReadDir(dir)
+	assert.Equal(t, 0, len(files))
+
+	_, err = Load(strings.NewReader("5BAA61E4:1\n"), SHA1)
+	assert.NotEqual(t, nil, err)
+}
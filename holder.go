@@ -0,0 +1,71 @@
+package xorfilter
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Holder holds the filter of a long-running service, which it replaces
+// without stopping the queries: Load returns the current filter without a
+// lock, and Swap replaces it atomically, so that a query answers from either
+// the old or the new filter, never from a mix of both. The filters are
+// FrozenBinaryFuse8 filters, which nothing can modify once they are held.
+// Hooks registered with OnSwap are called after each swap, for example to
+// log it or to update metrics. The zero Holder holds no filter; a Holder
+// must not be copied once used.
+type Holder struct {
+	// value holds a holderValue, as an atomic.Value cannot hold nil.
+	value atomic.Value
+
+	// mu orders the swaps and their hooks.
+	mu    sync.Mutex
+	hooks []func(old, next *FrozenBinaryFuse8)
+}
+
+// holderValue is the value of a Holder.
+type holderValue struct {
+	filter *FrozenBinaryFuse8
+}
+
+// NewHolder returns a Holder of filter, which may be nil.
+func NewHolder(filter *FrozenBinaryFuse8) *Holder {
+	h := &Holder{}
+	h.value.Store(holderValue{filter})
+	return h
+}
+
+// Load returns the filter held, or nil.
+func (h *Holder) Load() *FrozenBinaryFuse8 {
+	v, _ := h.value.Load().(holderValue)
+	return v.filter
+}
+
+// Swap replaces the filter held by filter, which may be nil, and returns the
+// previous one, after it has called the hooks. The queries in progress
+// complete with the previous filter. The swaps are ordered: the hooks of one
+// swap return before the next swap takes place, and must not call Swap.
+func (h *Holder) Swap(filter *FrozenBinaryFuse8) *FrozenBinaryFuse8 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	old := h.Load()
+	h.value.Store(holderValue{filter})
+	for _, hook := range h.hooks {
+		hook(old, filter)
+	}
+	return old
+}
+
+// OnSwap registers a hook that Swap calls with the previous and the new
+// filter, after the new one has replaced the previous one.
+func (h *Holder) OnSwap(hook func(old, next *FrozenBinaryFuse8)) {
+	h.mu.Lock()
+	h.hooks = append(h.hooks, hook)
+	h.mu.Unlock()
+}
+
+// Contains returns `true` if key is part of the set of the filter held, and
+// `false` if the Holder holds no filter.
+func (h *Holder) Contains(key uint64) bool {
+	filter := h.Load()
+	return filter != nil && filter.Contains(key)
+}
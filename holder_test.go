@@ -0,0 +1,64 @@
+package xorfilter
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHolder(t *testing.T) {
+	var zero Holder
+	assert.Nil(t, zero.Load())
+	assert.False(t, zero.Contains(1))
+
+	var filters [2]*FrozenBinaryFuse8
+	for i := range filters {
+		filter, err := PopulateBinaryFuse8([]uint64{uint64(i)})
+		assert.Equal(t, nil, err)
+		filters[i], err = filter.Freeze()
+		assert.Equal(t, nil, err)
+	}
+	h := NewHolder(filters[0])
+	assert.Equal(t, filters[0], h.Load())
+	assert.True(t, h.Contains(0))
+	var swaps [][2]*FrozenBinaryFuse8
+	h.OnSwap(func(old, next *FrozenBinaryFuse8) {
+		// The new filter is held when the hooks are called.
+		assert.Equal(t, next, h.Load())
+		swaps = append(swaps, [2]*FrozenBinaryFuse8{old, next})
+	})
+	assert.Equal(t, filters[0], h.Swap(filters[1]))
+	assert.True(t, h.Contains(1))
+	assert.Equal(t, filters[1], h.Swap(nil))
+	assert.False(t, h.Contains(1))
+	assert.Nil(t, h.Swap(filters[0]))
+	assert.Equal(t, [][2]*FrozenBinaryFuse8{{filters[0], filters[1]}, {filters[1], nil}, {nil, filters[0]}}, swaps)
+
+	// The queries run concurrently with the swaps.
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				filter := h.Load()
+				if !filter.Contains(0) && !filter.Contains(1) {
+					t.Error("no filter")
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 1000; i++ {
+		h.Swap(filters[i%2])
+	}
+	close(stop)
+	wg.Wait()
+}
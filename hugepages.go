@@ -0,0 +1,11 @@
+package xorfilter
+
+// hugePageSize is the size of the huge pages of Linux on amd64 and arm64.
+const hugePageSize = 2 << 20
+
+// A mapping is memory mapped outside of the Go heap, which holds the
+// fingerprints of a filter built with WithHugePages. It is unmapped once it
+// is unreachable, that is once no copy of the filter references it.
+type mapping struct {
+	data []byte
+}
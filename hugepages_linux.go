@@ -0,0 +1,38 @@
+//go:build linux && (amd64 || arm64) && !tinygo
+// +build linux
+// +build amd64 arm64
+// +build !tinygo
+
+package xorfilter
+
+import (
+	"runtime"
+	"syscall"
+)
+
+// mapHugePages maps n fingerprints onto explicit huge pages if the system has
+// some in reserve, or else onto pages that the kernel is advised to back with
+// transparent huge pages. It returns nil if n is smaller than a huge page or
+// if the memory cannot be mapped.
+func mapHugePages(n int) ([]uint8, *mapping) {
+	if n < hugePageSize {
+		return nil, nil
+	}
+	size := (n + hugePageSize - 1) &^ (hugePageSize - 1)
+	const prot = syscall.PROT_READ | syscall.PROT_WRITE
+	data, err := syscall.Mmap(-1, 0, size, prot, syscall.MAP_PRIVATE|syscall.MAP_ANON|syscall.MAP_HUGETLB)
+	if err != nil {
+		data, err = syscall.Mmap(-1, 0, size, prot, syscall.MAP_PRIVATE|syscall.MAP_ANON)
+		if err != nil {
+			return nil, nil
+		}
+		// Without transparent huge pages, this fails and the pages are
+		// regular ones.
+		syscall.Madvise(data, syscall.MADV_HUGEPAGE)
+	}
+	m := &mapping{data: data}
+	runtime.SetFinalizer(m, func(m *mapping) {
+		syscall.Munmap(m.data)
+	})
+	return data[:n:n], m
+}
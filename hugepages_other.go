@@ -0,0 +1,9 @@
+//go:build !linux || (!amd64 && !arm64) || tinygo
+// +build !linux !amd64,!arm64 tinygo
+
+package xorfilter
+
+// mapHugePages returns nil: huge pages are only supported on Linux.
+func mapHugePages(n int) ([]uint8, *mapping) {
+	return nil, nil
+}
@@ -0,0 +1,64 @@
+package xorfilter
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestContainsPaths checks that the default path of Contains answers as
+// containsSlow, which hashes through filter.hash, for the filters without a
+// Hasher, a Fingerprinter or a namespace, and that the other filters take
+// the slow path.
+func TestContainsPaths(t *testing.T) {
+	keys := make([]uint64, MID_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, err := PopulateBinaryFuse8(keys[:MID_NUM_KEYS/2])
+	assert.Equal(t, nil, err)
+	namespaced, err := PopulateBinaryFuse8(keys[:MID_NUM_KEYS/2], WithNamespace("paths"))
+	assert.Equal(t, nil, err)
+	for _, key := range keys {
+		assert.Equal(t, filter.containsSlow(key), filter.Contains(key))
+		assert.Equal(t, namespaced.containsSlow(key), namespaced.Contains(key))
+	}
+	for _, key := range keys[:MID_NUM_KEYS/2] {
+		assert.True(t, filter.Contains(key))
+		assert.True(t, namespaced.Contains(key))
+	}
+	var nilFilter *BinaryFuse8
+	assert.False(t, nilFilter.Contains(1))
+}
+
+// BenchmarkBinaryFuse8ContainsInlined runs the body of Contains with the
+// default hash inlined in the loop, which is the path of the calls to
+// Contains that profile-guided optimization inlines.
+func BenchmarkBinaryFuse8ContainsInlined1000000(b *testing.B) {
+	keys := make([]uint64, NUM_KEYS, NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, _ := PopulateBinaryFuse8(keys)
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		filter.containsHash(mixsplit(keys[n%len(keys)], filter.Seed))
+	}
+}
+
+// BenchmarkBinaryFuse8ContainsSlowPath1000000 queries a filter with a
+// namespace, which Contains answers out of line, in containsSlow.
+func BenchmarkBinaryFuse8ContainsSlowPath1000000(b *testing.B) {
+	keys := make([]uint64, NUM_KEYS, NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, _ := PopulateBinaryFuse8(keys, WithNamespace("bench"))
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		filter.Contains(keys[n%len(keys)])
+	}
+}
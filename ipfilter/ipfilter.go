@@ -0,0 +1,105 @@
+//go:build go1.18
+// +build go1.18
+
+// Package ipfilter screens IP addresses against a filter of IPv4 and IPv6
+// addresses, such as the denylists of a firewall:
+//
+//	var b ipfilter.Builder
+//	b.Add(netip.MustParseAddr("192.0.2.1"))
+//	b.AddPrefix(netip.MustParsePrefix("198.51.100.0/24"))
+//	filter, err := b.Build()
+//	filter.ContainsAddr(netip.MustParseAddr("198.51.100.7")) // true
+//
+// The key of an address is hash.Addr: an IPv4 address and its IPv4-mapped
+// IPv6 form are the same key, and the zone of an address is ignored. A
+// filter only holds addresses, so the small prefixes are expanded to all of
+// their addresses, up to MaxPrefixAddrs of them; the larger ones belong in
+// an exact structure, such as a radix tree, in front of the filter.
+package ipfilter
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+
+	"github.com/FastFilter/xorfilter"
+	"github.com/FastFilter/xorfilter/hash"
+)
+
+// MaxPrefixAddrs is the largest number of addresses of a prefix that
+// AddPrefix expands: those of an IPv4 /16, or of an IPv6 /112.
+const MaxPrefixAddrs = 1 << 16
+
+// ErrPrefixTooLarge is returned by AddPrefix for the prefixes of more than
+// MaxPrefixAddrs addresses.
+var ErrPrefixTooLarge = errors.New("ipfilter: the prefix has too many addresses to expand")
+
+// ErrInvalidPrefix is returned by AddPrefix for an invalid prefix, such as
+// the zero Prefix.
+var ErrInvalidPrefix = errors.New("ipfilter: invalid prefix")
+
+// A Builder collects the keys of the addresses of a filter. The zero Builder
+// is empty and ready to use.
+type Builder struct {
+	keys []uint64
+}
+
+// Add adds an address to the filter.
+func (b *Builder) Add(addr netip.Addr) {
+	b.keys = append(b.keys, hash.Addr(addr))
+}
+
+// AddIP adds the address of a net.IP to the filter.
+func (b *Builder) AddIP(ip net.IP) {
+	b.keys = append(b.keys, hash.IP(ip))
+}
+
+// AddPrefix adds all the addresses of a prefix to the filter. The bits of the
+// address of the prefix past its length are ignored, as by Prefix.Masked.
+func (b *Builder) AddPrefix(prefix netip.Prefix) error {
+	if !prefix.IsValid() {
+		return ErrInvalidPrefix
+	}
+	hostBits := prefix.Addr().BitLen() - prefix.Bits()
+	if hostBits > 16 {
+		return ErrPrefixTooLarge
+	}
+	addr := prefix.Masked().Addr()
+	for i := 0; i < 1<<uint(hostBits); i++ {
+		b.keys = append(b.keys, hash.Addr(addr))
+		addr = addr.Next()
+	}
+	return nil
+}
+
+// Len returns the number of keys added so far, which counts twice the
+// addresses added twice.
+func (b *Builder) Len() int {
+	return len(b.keys)
+}
+
+// Build returns the filter of the addresses added to b. An address may have
+// been added more than once. The builder can be reused afterwards: its
+// addresses are kept.
+func (b *Builder) Build(opts ...xorfilter.Option) (*Filter, error) {
+	filter, err := xorfilter.PopulateBinaryFuse8(b.keys, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{BinaryFuse8: filter}, nil
+}
+
+// A Filter is a filter of IP addresses.
+type Filter struct {
+	*xorfilter.BinaryFuse8
+}
+
+// ContainsAddr returns whether the address may be in the filter.
+func (f *Filter) ContainsAddr(addr netip.Addr) bool {
+	return f.Contains(hash.Addr(addr))
+}
+
+// ContainsIP returns whether the address of a net.IP may be in the filter.
+func (f *Filter) ContainsIP(ip net.IP) bool {
+	return f.Contains(hash.IP(ip))
+}
@@ -0,0 +1,54 @@
+//go:build go1.18
+// +build go1.18
+
+package ipfilter
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilter(t *testing.T) {
+	var b Builder
+	b.Add(netip.MustParseAddr("192.0.2.1"))
+	b.Add(netip.MustParseAddr("2001:db8::1"))
+	b.AddIP(net.ParseIP("203.0.113.9"))
+	assert.Equal(t, nil, b.AddPrefix(netip.MustParsePrefix("198.51.100.77/24")))
+	assert.Equal(t, nil, b.AddPrefix(netip.MustParsePrefix("2001:db8:1::/120")))
+	// Overlapping prefixes and addresses are fine.
+	assert.Equal(t, nil, b.AddPrefix(netip.MustParsePrefix("198.51.100.0/28")))
+	assert.Equal(t, 3+256+256+16, b.Len())
+	filter, err := b.Build()
+	assert.Equal(t, nil, err)
+
+	for _, s := range []string{"192.0.2.1", "::ffff:192.0.2.1", "2001:db8::1", "203.0.113.9", "198.51.100.0", "198.51.100.255", "2001:db8:1::ab"} {
+		assert.True(t, filter.ContainsAddr(netip.MustParseAddr(s)), s)
+		assert.True(t, filter.ContainsIP(net.ParseIP(s)), s)
+	}
+	falsePositives := 0
+	for i := 0; i < 1<<16; i++ {
+		if filter.ContainsAddr(netip.AddrFrom4([4]byte{10, 0, byte(i >> 8), byte(i)})) {
+			falsePositives++
+		}
+	}
+	assert.True(t, falsePositives < 2*(1<<16)/256, "%d false positives", falsePositives)
+}
+
+func TestAddPrefix(t *testing.T) {
+	var b Builder
+	assert.Equal(t, ErrInvalidPrefix, b.AddPrefix(netip.Prefix{}))
+	assert.Equal(t, ErrPrefixTooLarge, b.AddPrefix(netip.MustParsePrefix("10.0.0.0/15")))
+	assert.Equal(t, ErrPrefixTooLarge, b.AddPrefix(netip.MustParsePrefix("2001:db8::/64")))
+	assert.Equal(t, 0, b.Len())
+	assert.Equal(t, nil, b.AddPrefix(netip.MustParsePrefix("10.0.0.0/16")))
+	assert.Equal(t, MaxPrefixAddrs, b.Len())
+	assert.Equal(t, nil, b.AddPrefix(netip.MustParsePrefix("10.1.2.3/32")))
+	assert.Equal(t, MaxPrefixAddrs+1, b.Len())
+	filter, err := b.Build()
+	assert.Equal(t, nil, err)
+	assert.True(t, filter.ContainsAddr(netip.MustParseAddr("10.0.255.255")))
+	assert.True(t, filter.ContainsAddr(netip.MustParseAddr("10.1.2.3")))
+}
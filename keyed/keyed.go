@@ -0,0 +1,214 @@
+// Package keyed builds filters whose keys are hashed with a secret 128-bit
+// key, for the filters whose answers an attacker can observe.
+//
+// The hashes of an ordinary filter only depend on its seed, which is part of
+// the filter: whoever holds a copy of the filter can search offline for keys
+// that are false positives, one in 256 of the keys tried, and submit them.
+// The keys of a keyed filter are hashed with SipHash-2-4 under the secret
+// key, mixed with the seed: without the secret key, the filter tells nothing
+// of which keys it contains, and the false positives can only be found by
+// querying the service that holds it, at the same rate of one in 256, which
+// rate limits can hold back.
+//
+//	key, err := keyed.NewKey()
+//	filter, err := keyed.PopulateFromStrings(key, banned)
+//	filter.ContainsString(name)
+//
+// The secret key is part of the binary form of the filter, either as is, by
+// MarshalBinary, or encrypted with AES-GCM under another secret, by
+// MarshalEncrypted, for the filters stored where they could be read.
+package keyed
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+
+	"github.com/FastFilter/xorfilter"
+)
+
+// A Key is the secret key of a keyed filter.
+type Key [16]byte
+
+// NewKey returns a random Key.
+func NewKey() (Key, error) {
+	var key Key
+	_, err := rand.Read(key[:])
+	return key, err
+}
+
+// Hasher returns the Hasher of the filters keyed with k: the SipHash-2-4 of
+// the key under k, whose first half is mixed with the seed of the filter.
+func (k Key) Hasher() xorfilter.Hasher {
+	k0 := binary.LittleEndian.Uint64(k[:8])
+	k1 := binary.LittleEndian.Uint64(k[8:])
+	return xorfilter.HasherFunc(func(key, seed uint64) uint64 {
+		return sipHash24(k0^seed, k1, key)
+	})
+}
+
+// A Filter is a BinaryFuse8 filter keyed with a secret Key. Its Contains,
+// ContainsString and ContainsBytes methods query the keys given to Populate,
+// PopulateFromStrings and PopulateFromBytes.
+type Filter struct {
+	*xorfilter.BinaryFuse8
+	key Key
+}
+
+// Populate returns the filter of keys, keyed with key.
+func Populate(key Key, keys []uint64, opts ...xorfilter.Option) (*Filter, error) {
+	filter, err := xorfilter.PopulateBinaryFuse8(keys, withKey(key, opts)...)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{BinaryFuse8: filter, key: key}, nil
+}
+
+// PopulateFromStrings returns the filter of the string keys, keyed with key.
+func PopulateFromStrings(key Key, keys []string, opts ...xorfilter.Option) (*Filter, error) {
+	filter, err := xorfilter.PopulateBinaryFuse8FromStrings(keys, withKey(key, opts)...)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{BinaryFuse8: filter, key: key}, nil
+}
+
+// PopulateFromBytes returns the filter of the binary keys, keyed with key.
+func PopulateFromBytes(key Key, keys [][]byte, opts ...xorfilter.Option) (*Filter, error) {
+	filter, err := xorfilter.PopulateBinaryFuse8FromBytes(keys, withKey(key, opts)...)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{BinaryFuse8: filter, key: key}, nil
+}
+
+// withKey returns opts followed by the Hasher of key, which takes precedence
+// over a Hasher of opts.
+func withKey(key Key, opts []xorfilter.Option) []xorfilter.Option {
+	return append(opts[:len(opts):len(opts)], xorfilter.WithHasher(key.Hasher()))
+}
+
+// Key returns the secret key of the filter.
+func (f *Filter) Key() Key {
+	return f.key
+}
+
+// The first byte of the binary form of a filter tells how the key is stored.
+const (
+	plainKey     = 1
+	encryptedKey = 2
+)
+
+// ErrEncrypted is returned by UnmarshalBinary for a filter encoded by
+// MarshalEncrypted.
+var ErrEncrypted = errors.New("keyed: the key of the filter is encrypted")
+
+// errFormat is returned for data that is not the binary form of a keyed
+// filter.
+var errFormat = errors.New("keyed: invalid keyed filter")
+
+// MarshalBinary encodes the filter as a byte, 1, the secret key, and the
+// binary form of the BinaryFuse8 filter. Whoever reads it can search for
+// false positives: MarshalEncrypted protects the key.
+func (f *Filter) MarshalBinary() ([]byte, error) {
+	filter, err := f.BinaryFuse8.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, 0, 1+len(f.key)+len(filter))
+	data = append(data, plainKey)
+	data = append(data, f.key[:]...)
+	return append(data, filter...), nil
+}
+
+// MarshalEncrypted encodes the filter as a byte, 2, the secret key encrypted
+// with AES-GCM under secret, and the binary form of the BinaryFuse8 filter,
+// which is authenticated with the key but not encrypted. secret is an AES key
+// of 16, 24 or 32 bytes.
+func (f *Filter) MarshalEncrypted(secret []byte) ([]byte, error) {
+	aead, err := newAEAD(secret)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, 1+aead.NonceSize(), 1+aead.NonceSize()+len(f.key)+aead.Overhead()+int(f.SizeInBytes()))
+	data[0] = encryptedKey
+	nonce := data[1:]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	filter, err := f.BinaryFuse8.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	data = aead.Seal(data, nonce, f.key[:], filter)
+	return append(data, filter...), nil
+}
+
+// UnmarshalBinary decodes a filter encoded by MarshalBinary. It returns
+// ErrEncrypted for a filter encoded by MarshalEncrypted.
+func (f *Filter) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		return errFormat
+	}
+	switch data[0] {
+	case plainKey:
+	case encryptedKey:
+		return ErrEncrypted
+	default:
+		return errFormat
+	}
+	var key Key
+	if len(data) < 1+len(key) {
+		return errFormat
+	}
+	copy(key[:], data[1:])
+	return f.decode(key, data[1+len(key):])
+}
+
+// UnmarshalEncrypted decodes a filter encoded by MarshalEncrypted with the
+// same secret, or by MarshalBinary. It fails if the filter or its key were
+// modified.
+func (f *Filter) UnmarshalEncrypted(data []byte, secret []byte) error {
+	if len(data) > 0 && data[0] == plainKey {
+		return f.UnmarshalBinary(data)
+	}
+	if len(data) == 0 || data[0] != encryptedKey {
+		return errFormat
+	}
+	aead, err := newAEAD(secret)
+	if err != nil {
+		return err
+	}
+	var key Key
+	sealed := 1 + aead.NonceSize() + len(key) + aead.Overhead()
+	if len(data) < sealed {
+		return errFormat
+	}
+	nonce := data[1 : 1+aead.NonceSize()]
+	filter := data[sealed:]
+	if _, err := aead.Open(key[:0], nonce, data[1+aead.NonceSize():sealed], filter); err != nil {
+		return err
+	}
+	return f.decode(key, filter)
+}
+
+func (f *Filter) decode(key Key, data []byte) error {
+	var filter xorfilter.BinaryFuse8
+	if err := filter.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	filter.SetHasher(key.Hasher())
+	f.BinaryFuse8 = &filter
+	f.key = key
+	return nil
+}
+
+func newAEAD(secret []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
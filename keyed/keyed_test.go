@@ -0,0 +1,65 @@
+package keyed
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/FastFilter/xorfilter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSipHash24(t *testing.T) {
+	// The reference vector of the 8-byte message 00 01 ... 07, with the key
+	// 00 01 ... 0f.
+	assert.Equal(t, uint64(0x93f5f5799a932462), sipHash24(0x0706050403020100, 0x0f0e0d0c0b0a0908, 0x0706050403020100))
+}
+
+func TestKeyedFilter(t *testing.T) {
+	key, err := NewKey()
+	assert.Equal(t, nil, err)
+	names := make([]string, 10000)
+	for i := range names {
+		names[i] = fmt.Sprintf("name-%d", i)
+	}
+	filter, err := PopulateFromStrings(key, names)
+	assert.Equal(t, nil, err)
+	for _, name := range names {
+		assert.True(t, filter.ContainsString(name))
+	}
+	assert.Equal(t, key, filter.Key())
+
+	// The same keys under another key have other fingerprints.
+	other, err := PopulateFromStrings(Key{1}, names)
+	assert.Equal(t, nil, err)
+	assert.False(t, filter.BinaryFuse8.Equal(other.BinaryFuse8))
+
+	data, err := filter.MarshalBinary()
+	assert.Equal(t, nil, err)
+	var decoded Filter
+	assert.Equal(t, nil, decoded.UnmarshalBinary(data))
+	assert.Equal(t, key, decoded.Key())
+	for _, name := range names {
+		assert.True(t, decoded.ContainsString(name))
+	}
+
+	secret := make([]byte, 32)
+	secret[0] = 7
+	data, err = filter.MarshalEncrypted(secret)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, ErrEncrypted, decoded.UnmarshalBinary(data))
+	decoded = Filter{}
+	assert.Equal(t, nil, decoded.UnmarshalEncrypted(data, secret))
+	assert.Equal(t, key, decoded.Key())
+	for _, name := range names {
+		assert.True(t, decoded.ContainsString(name))
+	}
+	wrong := make([]byte, 32)
+	assert.NotEqual(t, nil, decoded.UnmarshalEncrypted(data, wrong))
+	data[len(data)-1] ^= 1
+	assert.NotEqual(t, nil, decoded.UnmarshalEncrypted(data, secret))
+
+	numbers, err := Populate(key, []uint64{1, 2, 3}, xorfilter.WithHasher(nil))
+	assert.Equal(t, nil, err)
+	assert.True(t, numbers.Contains(2))
+	assert.True(t, numbers.Hasher() != nil)
+}
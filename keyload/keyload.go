@@ -0,0 +1,154 @@
+// Package keyload reads the keys of a filter from data dumps: the lines of a
+// text file, a column of a CSV file, or a field of NDJSON records. The
+// strings are hashed as PopulateBinaryFuse8FromStrings hashes them, so that
+// ContainsString queries the filter:
+//
+//	keys, err := keyload.Lines(file)
+//	filter, err := xorfilter.PopulateBinaryFuse8(keys)
+//	filter.ContainsString("alice")
+//
+// The keys are streamed: the Each functions pass them one at a time, and the
+// others only hold the 8 bytes of each key.
+package keyload
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/FastFilter/xorfilter"
+)
+
+// maxLineBytes is the longest line read by EachLine.
+const maxLineBytes = 1 << 20
+
+// EachLine calls fn with the key of each line of r, without its line break.
+// The empty lines are skipped.
+func EachLine(r io.Reader, fn func(key uint64) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineBytes)
+	for scanner.Scan() {
+		line := bytes.TrimSuffix(scanner.Bytes(), []byte("\r"))
+		if len(line) == 0 {
+			continue
+		}
+		if err := fn(xorfilter.HashBytes(line)); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// EachCSVField calls fn with the key of the field of each record of r in the
+// named column, found in the header, the first record. The fields of r, such
+// as its separator, are set by the caller.
+func EachCSVField(r *csv.Reader, column string, fn func(key uint64) error) error {
+	r.ReuseRecord = true
+	header, err := r.Read()
+	if err == io.EOF {
+		return fmt.Errorf("keyload: no header")
+	}
+	if err != nil {
+		return err
+	}
+	index := -1
+	for i, name := range header {
+		if name == column {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("keyload: no column %q", column)
+	}
+	for n := 2; ; n++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if index >= len(record) {
+			return fmt.Errorf("keyload: record %d: no column %q", n, column)
+		}
+		if err := fn(xorfilter.HashBytes([]byte(record[index]))); err != nil {
+			return err
+		}
+	}
+}
+
+// EachNDJSONField calls fn with the key of the named field of each JSON
+// object of r. A string is hashed; an integer from 0 to 2^64-1 is the key
+// itself, as Contains queries it. Another value, or a missing field, is an
+// error.
+func EachNDJSONField(r io.Reader, field string, fn func(key uint64) error) error {
+	decoder := json.NewDecoder(r)
+	for record := 1; ; record++ {
+		var object map[string]json.RawMessage
+		if err := decoder.Decode(&object); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("keyload: record %d: %v", record, err)
+		}
+		value, ok := object[field]
+		if !ok {
+			return fmt.Errorf("keyload: record %d: no field %q", record, field)
+		}
+		key, err := jsonKey(value)
+		if err != nil {
+			return fmt.Errorf("keyload: record %d: field %q: %v", record, field, err)
+		}
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+}
+
+// jsonKey returns the key of a JSON string or integer.
+func jsonKey(value json.RawMessage) (uint64, error) {
+	if len(value) > 0 && value[0] == '"' {
+		var s string
+		if err := json.Unmarshal(value, &s); err != nil {
+			return 0, err
+		}
+		return xorfilter.HashBytes([]byte(s)), nil
+	}
+	key, err := strconv.ParseUint(string(value), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s is neither a string nor a uint64", value)
+	}
+	return key, nil
+}
+
+// collect returns the keys passed by each.
+func collect(each func(fn func(key uint64) error) error) ([]uint64, error) {
+	var keys []uint64
+	err := each(func(key uint64) error {
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Lines returns the keys of EachLine.
+func Lines(r io.Reader) ([]uint64, error) {
+	return collect(func(fn func(uint64) error) error { return EachLine(r, fn) })
+}
+
+// CSVField returns the keys of EachCSVField.
+func CSVField(r *csv.Reader, column string) ([]uint64, error) {
+	return collect(func(fn func(uint64) error) error { return EachCSVField(r, column, fn) })
+}
+
+// NDJSONField returns the keys of EachNDJSONField.
+func NDJSONField(r io.Reader, field string) ([]uint64, error) {
+	return collect(func(fn func(uint64) error) error { return EachNDJSONField(r, field, fn) })
+}
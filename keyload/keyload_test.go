@@ -0,0 +1,50 @@
+package keyload
+
+import (
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"github.com/FastFilter/xorfilter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLines(t *testing.T) {
+	keys, err := Lines(strings.NewReader("alice\r\nbob\n\ncarol"))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 3, len(keys))
+	filter, err := xorfilter.PopulateBinaryFuse8(keys)
+	assert.Equal(t, nil, err)
+	for _, name := range []string{"alice", "bob", "carol"} {
+		assert.True(t, filter.ContainsString(name))
+	}
+}
+
+func TestCSVField(t *testing.T) {
+	r := csv.NewReader(strings.NewReader("id;name\n1;alice\n2;\"bob; jr\"\n"))
+	r.Comma = ';'
+	keys, err := CSVField(r, "name")
+	assert.Equal(t, nil, err)
+	expected, _ := Lines(strings.NewReader("alice\nbob; jr\n"))
+	assert.Equal(t, expected, keys)
+
+	_, err = CSVField(csv.NewReader(strings.NewReader("id,name\n1,alice\n")), "email")
+	assert.NotEqual(t, nil, err)
+	_, err = CSVField(csv.NewReader(strings.NewReader("")), "name")
+	assert.NotEqual(t, nil, err)
+}
+
+func TestNDJSONField(t *testing.T) {
+	keys, err := NDJSONField(strings.NewReader(`{"user":"alice","n":1}
+{"user":18446744073709551615}
+{"user":"böb"}
+`), "user")
+	assert.Equal(t, nil, err)
+	expected, _ := Lines(strings.NewReader("alice\nböb\n"))
+	assert.Equal(t, []uint64{expected[0], 18446744073709551615, expected[1]}, keys)
+
+	for _, input := range []string{`{"name":"alice"}`, `{"user":1.5}`, `{"user":null}`, `{"user":-1}`, `{"user":`} {
+		_, err := NDJSONField(strings.NewReader(input), "user")
+		assert.NotEqual(t, nil, err, input)
+	}
+}
@@ -0,0 +1,445 @@
+package xorfilter
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// keySource supplies the keys to a construction in chunks, so that the keys
+// do not need to be resident in memory all at once.
+type keySource interface {
+	// rewind restarts the iteration at the first key.
+	rewind() error
+	// next returns the next chunk of keys, or an empty chunk once all the
+	// keys have been returned.
+	next() ([]uint64, error)
+}
+
+// sliceChunkSize is the number of keys of a slice returned at a time.
+const sliceChunkSize = 1 << 16
+
+// sliceSource returns the keys of a slice, without copying them.
+type sliceSource struct {
+	keys []uint64
+	pos  int
+}
+
+func (s *sliceSource) rewind() error {
+	s.pos = 0
+	return nil
+}
+
+func (s *sliceSource) next() ([]uint64, error) {
+	end := s.pos + sliceChunkSize
+	if end > len(s.keys) {
+		end = len(s.keys)
+	}
+	chunk := s.keys[s.pos:end]
+	s.pos = end
+	return chunk, nil
+}
+
+// collectKeys returns a copy of all the keys of src. If wipe is true, the
+// arrays outgrown by the copy are zeroed.
+func collectKeys(src keySource, wipe bool) ([]uint64, error) {
+	if err := src.rewind(); err != nil {
+		return nil, err
+	}
+	var keys []uint64
+	for {
+		chunk, err := src.next()
+		if err != nil {
+			if wipe {
+				wipeKeys(keys)
+			}
+			return nil, err
+		}
+		if len(chunk) == 0 {
+			return keys, nil
+		}
+		if wipe && len(keys)+len(chunk) > cap(keys) {
+			grown := make([]uint64, len(keys), 2*(len(keys)+len(chunk)))
+			copy(grown, keys)
+			wipeKeys(keys)
+			keys = grown
+		}
+		keys = append(keys, chunk...)
+	}
+}
+
+// wipeKeys zeroes keys, up to their capacity.
+func wipeKeys(keys []uint64) {
+	keys = keys[:cap(keys)]
+	for i := range keys {
+		keys[i] = 0
+	}
+}
+
+// A wiper is a keySource with buffers of its own, holding keys or their
+// hashes, that wipe zeroes once the source is no longer used.
+type wiper interface {
+	wipe()
+}
+
+// wipeSource zeroes the buffers of src, if it has any.
+func wipeSource(src keySource) {
+	if w, ok := src.(wiper); ok {
+		w.wipe()
+	}
+}
+
+// cachedOnRetry reports whether the keys of src are worth keeping in memory
+// once a construction retries with another seed: they are hashed from
+// strings or bytes, or picked out of the keys of all the shards of a
+// BinaryFuse8Big filter, at every pass.
+func cachedOnRetry(src keySource) bool {
+	switch src.(type) {
+	case *stringSource, *bytesSource, *uint128Source, *shardSource:
+		return true
+	}
+	return false
+}
+
+// readerChunkSize is the number of keys decoded from a reader at a time.
+const readerChunkSize = 4096
+
+// readerSource decodes little-endian uint64 keys from a reader. When the
+// reader cannot be rewound, the keys are spilled to a temporary file during
+// the first pass, and read back from it afterwards, rather than retained in
+// memory; close removes the file.
+type readerSource struct {
+	r       io.Reader
+	seeker  io.Seeker
+	start   int64
+	n       int
+	read    int
+	passes  int
+	buf     []byte
+	chunk   []uint64
+	spill   *os.File
+	spilled int
+}
+
+func newReaderSource(r io.Reader, n int) (*readerSource, error) {
+	src := &readerSource{
+		r:     r,
+		n:     n,
+		buf:   make([]byte, 8*readerChunkSize),
+		chunk: make([]uint64, readerChunkSize),
+	}
+	if seeker, ok := r.(io.Seeker); ok {
+		start, err := seeker.Seek(0, io.SeekCurrent)
+		if err == nil {
+			src.seeker = seeker
+			src.start = start
+		}
+	}
+	return src, nil
+}
+
+func (s *readerSource) rewind() error {
+	if s.passes > 0 && s.seeker != nil {
+		if _, err := s.seeker.Seek(s.start, io.SeekStart); err != nil {
+			return err
+		}
+	}
+	s.passes++
+	s.read = 0
+	return nil
+}
+
+func (s *readerSource) next() ([]uint64, error) {
+	if s.read == s.n {
+		return nil, nil
+	}
+	count := s.n - s.read
+	if count > readerChunkSize {
+		count = readerChunkSize
+	}
+	buf := s.buf[:8*count]
+	if s.read < s.spilled {
+		// The keys were spilled by a previous pass. The file is read at an
+		// offset, so that its position stays at its end for the spill.
+		if count > s.spilled-s.read {
+			count = s.spilled - s.read
+			buf = buf[:8*count]
+		}
+		if _, err := s.spill.ReadAt(buf, 8*int64(s.read)); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := io.ReadFull(s.r, buf); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if s.seeker == nil {
+			if err := s.spillKeys(buf); err != nil {
+				return nil, err
+			}
+			s.spilled += count
+		}
+	}
+	chunk := s.chunk[:count]
+	for i := range chunk {
+		chunk[i] = binary.LittleEndian.Uint64(buf[8*i:])
+	}
+	s.read += count
+	return chunk, nil
+}
+
+// spillKeys appends the encoded keys of buf to the temporary file, which it
+// creates on the first call.
+func (s *readerSource) spillKeys(buf []byte) error {
+	if s.spill == nil {
+		f, err := ioutil.TempFile("", "xorfilter-keys-*")
+		if err != nil {
+			return err
+		}
+		s.spill = f
+	}
+	_, err := s.spill.Write(buf)
+	return err
+}
+
+// close removes the temporary file of the keys, if any.
+func (s *readerSource) close() {
+	if s.spill != nil {
+		s.spill.Close()
+		os.Remove(s.spill.Name())
+		s.spill = nil
+		s.spilled = 0
+	}
+}
+
+func (s *readerSource) wipe() {
+	for i := range s.buf {
+		s.buf[i] = 0
+	}
+	wipeKeys(s.chunk)
+}
+
+// uint32Source widens the keys of a []uint32 slice, a chunk at a time.
+type uint32Source struct {
+	keys  []uint32
+	pos   int
+	chunk []uint64
+}
+
+func (s *uint32Source) rewind() error {
+	s.pos = 0
+	return nil
+}
+
+func (s *uint32Source) next() ([]uint64, error) {
+	end := s.pos + readerChunkSize
+	if end > len(s.keys) {
+		end = len(s.keys)
+	}
+	if s.chunk == nil {
+		s.chunk = make([]uint64, readerChunkSize)
+	}
+	chunk := s.chunk[:end-s.pos]
+	for i, key := range s.keys[s.pos:end] {
+		chunk[i] = uint64(key)
+	}
+	s.pos = end
+	return chunk, nil
+}
+
+func (s *uint32Source) wipe() {
+	wipeKeys(s.chunk)
+}
+
+// stringSource hashes the keys of a []string slice, a chunk at a time.
+type stringSource struct {
+	keys  []string
+	pos   int
+	chunk []uint64
+}
+
+func (s *stringSource) rewind() error {
+	s.pos = 0
+	return nil
+}
+
+func (s *stringSource) next() ([]uint64, error) {
+	end := s.pos + readerChunkSize
+	if end > len(s.keys) {
+		end = len(s.keys)
+	}
+	if s.chunk == nil {
+		s.chunk = make([]uint64, readerChunkSize)
+	}
+	chunk := s.chunk[:end-s.pos]
+	for i, key := range s.keys[s.pos:end] {
+		chunk[i] = hashString(key, stringSeed)
+	}
+	s.pos = end
+	return chunk, nil
+}
+
+func (s *stringSource) wipe() {
+	wipeKeys(s.chunk)
+}
+
+// bytesSource hashes the keys of a [][]byte slice, a chunk at a time.
+type bytesSource struct {
+	keys  [][]byte
+	pos   int
+	chunk []uint64
+}
+
+func (s *bytesSource) rewind() error {
+	s.pos = 0
+	return nil
+}
+
+func (s *bytesSource) next() ([]uint64, error) {
+	end := s.pos + readerChunkSize
+	if end > len(s.keys) {
+		end = len(s.keys)
+	}
+	if s.chunk == nil {
+		s.chunk = make([]uint64, readerChunkSize)
+	}
+	chunk := s.chunk[:end-s.pos]
+	for i, key := range s.keys[s.pos:end] {
+		chunk[i] = hashBytes(key, stringSeed)
+	}
+	s.pos = end
+	return chunk, nil
+}
+
+func (s *bytesSource) wipe() {
+	wipeKeys(s.chunk)
+}
+
+// uint128Source hashes the keys of a [][16]byte slice, a chunk at a time.
+type uint128Source struct {
+	keys  [][16]byte
+	pos   int
+	chunk []uint64
+}
+
+func (s *uint128Source) rewind() error {
+	s.pos = 0
+	return nil
+}
+
+func (s *uint128Source) next() ([]uint64, error) {
+	end := s.pos + readerChunkSize
+	if end > len(s.keys) {
+		end = len(s.keys)
+	}
+	if s.chunk == nil {
+		s.chunk = make([]uint64, readerChunkSize)
+	}
+	chunk := s.chunk[:end-s.pos]
+	for i, key := range s.keys[s.pos:end] {
+		chunk[i] = hashUint128(binary.BigEndian.Uint64(key[:8]), binary.BigEndian.Uint64(key[8:]), stringSeed)
+	}
+	s.pos = end
+	return chunk, nil
+}
+
+func (s *uint128Source) wipe() {
+	wipeKeys(s.chunk)
+}
+
+// errStopped is returned to the key function of a funcSource whose pass was
+// abandoned by the construction.
+var errStopped = errors.New("the construction stopped reading the keys")
+
+// funcSource returns the keys that a function emits on each of its calls.
+// Each pass over the keys runs the function in a goroutine, which hands the
+// chunks over one at a time: a chunk is only acknowledged, and the function
+// resumed, once the construction asks for the next one, so the function can
+// reuse its buffers.
+type funcSource struct {
+	fn      func(emit func(keys []uint64) error) error
+	n       int
+	count   int
+	running bool
+	pending bool
+	chunks  chan []uint64
+	ack     chan struct{}
+	stopped chan struct{}
+	result  chan error
+}
+
+func (s *funcSource) rewind() error {
+	s.stop()
+	chunks := make(chan []uint64)
+	ack := make(chan struct{})
+	stopped := make(chan struct{})
+	result := make(chan error, 1)
+	s.chunks, s.ack, s.stopped, s.result = chunks, ack, stopped, result
+	s.running, s.pending, s.count = true, false, 0
+	go func() {
+		result <- s.fn(func(keys []uint64) error {
+			if len(keys) == 0 {
+				return nil
+			}
+			select {
+			case chunks <- keys:
+			case <-stopped:
+				return errStopped
+			}
+			select {
+			case <-ack:
+				return nil
+			case <-stopped:
+				return errStopped
+			}
+		})
+		close(chunks)
+	}()
+	return nil
+}
+
+func (s *funcSource) next() ([]uint64, error) {
+	if !s.running {
+		return nil, nil
+	}
+	if s.pending {
+		s.ack <- struct{}{}
+		s.pending = false
+	}
+	keys, ok := <-s.chunks
+	if ok {
+		s.pending = true
+		s.count += len(keys)
+		if s.count > s.n {
+			s.stop()
+			return nil, fmt.Errorf("the key function emitted more than %d keys", s.n)
+		}
+		return keys, nil
+	}
+	s.running = false
+	if err := <-s.result; err != nil {
+		return nil, err
+	}
+	if s.count != s.n {
+		return nil, fmt.Errorf("the key function emitted %d keys instead of %d", s.count, s.n)
+	}
+	return nil, nil
+}
+
+// stop abandons the current pass, if any, and waits for the function to
+// return.
+func (s *funcSource) stop() {
+	if !s.running {
+		return
+	}
+	close(s.stopped)
+	for range s.chunks {
+	}
+	<-s.result
+	s.running = false
+}
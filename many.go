@@ -0,0 +1,49 @@
+package xorfilter
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// BuildMany builds a BinaryFuse8 filter for each set of keySets, as
+// PopulateBinaryFuse8 does, with the given number of goroutines, or
+// GOMAXPROCS goroutines if parallelism <= 0: this is the pattern of the
+// stores that keep a filter per file or per partition. filters[i] is the
+// filter of keySets[i], or nil if its construction failed with errs[i]. The
+// goroutines take the sets in turn, and each of them recycles its temporary
+// arrays from one set to the next. The options apply to every construction,
+// so a progress function or a Hasher must be safe for concurrent use; the
+// WithStats and WithScratchBuffer options, which cannot be shared, are
+// ignored.
+func BuildMany(keySets [][]uint64, parallelism int, opts ...Option) (filters []*BinaryFuse8, errs []error) {
+	filters = make([]*BinaryFuse8, len(keySets))
+	errs = make([]error, len(keySets))
+	if parallelism <= 0 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+	if parallelism > len(keySets) {
+		parallelism = len(keySets)
+	}
+	var next int64
+	var wg sync.WaitGroup
+	wg.Add(parallelism)
+	for w := 0; w < parallelism; w++ {
+		go func() {
+			defer wg.Done()
+			var p Populator
+			for {
+				i := int(atomic.AddInt64(&next, 1) - 1)
+				if i >= len(keySets) {
+					return
+				}
+				cfg := newBuildConfig(opts)
+				cfg.stats, cfg.scratch = nil, nil
+				filters[i], errs[i] = p.populateBinaryFuse8(context.Background(), len(keySets[i]), &sliceSource{keys: keySets[i]}, cfg)
+			}
+		}()
+	}
+	wg.Wait()
+	return filters, errs
+}
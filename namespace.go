@@ -0,0 +1,45 @@
+package xorfilter
+
+// namespaceSeed is the seed with which the namespaces are hashed to the salts
+// of the keys. It is part of the format of the namespaced filters and must
+// never change.
+const namespaceSeed = 0x6e616d6573706163
+
+// WithNamespace mixes a namespace, such as the name of a tenant, into the
+// hashes of the keys of the filter. The same key then has uncorrelated
+// hashes, and so fingerprints, in filters of different namespaces: the keys
+// found to be false positives of one filter are false positives of another
+// only by chance, so that probing the filter of one tenant tells nothing of
+// the others. A key is first mixed with a 64-bit salt derived from the
+// namespace, by a bijection, so that distinct keys remain distinct, and then
+// hashed as usual. The namespace is not a secret, see the keyed package for
+// that. Like the Hasher, it is not part of the exported fields of the filter:
+// a filter restored from them must be given it again by SetNamespace.
+func WithNamespace(namespace string) Option {
+	return func(cfg *buildConfig) {
+		cfg.salt = namespaceSalt(namespace)
+		cfg.salted = true
+	}
+}
+
+// namespaceSalt returns the salt of the keys of a namespace.
+func namespaceSalt(namespace string) uint64 {
+	return hashString(namespace, namespaceSeed)
+}
+
+// SetNamespace sets the namespace of the filter, that it was built with by
+// WithNamespace, before it is queried.
+func (filter *BinaryFuse8) SetNamespace(namespace string) {
+	filter.salt = namespaceSalt(namespace)
+	filter.salted = true
+}
+
+// Namespaced returns whether the filter has a namespace.
+func (filter *BinaryFuse8) Namespaced() bool {
+	return filter != nil && filter.salted
+}
+
+// saltKey mixes key with the salt of the namespace of the filter.
+func (filter *BinaryFuse8) saltKey(key uint64) uint64 {
+	return mixsplit(key, filter.salt)
+}
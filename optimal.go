@@ -0,0 +1,112 @@
+package xorfilter
+
+import (
+	"errors"
+	"unsafe"
+)
+
+// A Filter answers membership queries on a set of uint64 keys. The filters of
+// this package all implement it.
+type Filter interface {
+	Contains(key uint64) bool
+	FalsePositiveRate() float64
+	SizeInBytes() uint64
+}
+
+// ErrUnreachableFalsePositiveRate is returned when no filter offers a false
+// positive rate as low as requested.
+var ErrUnreachableFalsePositiveRate = errors.New("no filter reaches the target false positive rate")
+
+// FilterChoice describes the filter picked by ChooseFilter.
+type FilterChoice struct {
+	// Type is the name of the filter type, such as "BinaryFuse8".
+	Type string
+	// FingerprintBits is the width of the fingerprints.
+	FingerprintBits int
+	// FalsePositiveRate is the theoretical false positive rate of the filter.
+	FalsePositiveRate float64
+	// Memory is the estimated memory of the construction and of the filter.
+	Memory MemoryEstimate
+}
+
+// fingerprintFalsePositiveRate is the false positive rate of the filters of
+// this package, which all have 8-bit fingerprints.
+const fingerprintFalsePositiveRate = 1.0 / 256
+
+// ChooseFilter picks the filter for n distinct keys whose false positive rate
+// is at most targetFPP and whose fingerprints take the least memory, the
+// fastest one among equals. All the filters of this package have 8-bit
+// fingerprints, so that none reaches a rate below 1/256; their sizes differ:
+//
+//   - a Xor8 filter has 1.23n + 32 fingerprints, fewer than a BinaryFuse8
+//     filter up to tens of thousands of keys, whose segments are then
+//     proportionally larger; it is a candidate without opts only, since
+//     Populate takes none;
+//   - a BinaryFuse8 filter has about 1.13n fingerprints or fewer for the
+//     larger sets, and is faster to query; opts, such as WithSizeFactor, are
+//     taken into account;
+//   - a BinaryFuse8Big filter replaces it beyond MaxBinaryFuse8Keys keys.
+//
+// A Fuse8 filter is never smaller than a BinaryFuse8 filter, and is not a
+// candidate.
+func ChooseFilter(n uint64, targetFPP float64, opts ...Option) (FilterChoice, error) {
+	if targetFPP < fingerprintFalsePositiveRate {
+		return FilterChoice{}, ErrUnreachableFalsePositiveRate
+	}
+	choice := FilterChoice{
+		Type:              "BinaryFuse8",
+		FingerprintBits:   8,
+		FalsePositiveRate: fingerprintFalsePositiveRate,
+		Memory:            EstimateBinaryFuse8Memory(n, opts...),
+	}
+	if n > MaxBinaryFuse8Keys {
+		choice.Type = "BinaryFuse8Big"
+	}
+	if len(opts) == 0 && n <= MaxXor8Keys {
+		if memory := estimateXor8Memory(n); memory.FilterBytes < choice.Memory.FilterBytes {
+			choice.Type = "Xor8"
+			choice.Memory = memory
+		}
+	}
+	return choice, nil
+}
+
+// estimateXor8Memory returns the memory needed to build and to hold a Xor8
+// filter of n distinct keys, at most MaxXor8Keys.
+func estimateXor8Memory(n uint64) MemoryEstimate {
+	capacity, _ := xor8Capacity(int(n))
+	keyIndex := uint64(unsafe.Sizeof(keyindex{}))
+	return MemoryEstimate{
+		FilterBytes: uint64(capacity),
+		// The stack of the keys, and the queues and sets of the slots.
+		ScratchBytes: n*keyIndex + uint64(capacity)*(keyIndex+uint64(unsafe.Sizeof(xorset{}))),
+	}
+}
+
+// NewOptimalFilter builds the filter picked by ChooseFilter for keys and
+// targetFPP, and returns it along with the choice.
+func NewOptimalFilter(keys []uint64, targetFPP float64, opts ...Option) (Filter, FilterChoice, error) {
+	choice, err := ChooseFilter(uint64(len(keys)), targetFPP, opts...)
+	if err != nil {
+		return nil, choice, err
+	}
+	switch choice.Type {
+	case "Xor8":
+		filter, err := Populate(keys)
+		if err != nil {
+			return nil, choice, err
+		}
+		return filter, choice, nil
+	case "BinaryFuse8Big":
+		filter, err := PopulateBinaryFuse8Big(keys, opts...)
+		if err != nil {
+			return nil, choice, err
+		}
+		return filter, choice, nil
+	}
+	filter, err := PopulateBinaryFuse8(keys, opts...)
+	if err != nil {
+		return nil, choice, err
+	}
+	return filter, choice, nil
+}
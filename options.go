@@ -0,0 +1,357 @@
+package xorfilter
+
+import (
+	"runtime"
+	"time"
+)
+
+// An Option configures the construction of a filter.
+type Option func(*buildConfig)
+
+// buildConfig holds the settings of a construction.
+type buildConfig struct {
+	progress       func(Progress)
+	stats          *BuildStats
+	maxIterations  int
+	retryPolicy    RetryPolicy
+	rngCounter     uint64
+	hasher         Hasher
+	fingerprinter  Fingerprinter
+	salt           uint64
+	salted         bool
+	sortedUnique   bool
+	duplicateCheck bool
+	verifyKeys     bool
+	sizeFactor     float64
+	segmentLength  uint32
+	parallelism    int
+	lowMemory      bool
+	alignment      int
+	hugePages      bool
+	blockBits      int
+	scratch        []byte
+	maxScratch     uint64
+	version        AlgorithmVersion
+	seed           uint64
+	seeded         bool
+	zeroize        bool
+}
+
+func newBuildConfig(opts []Option) *buildConfig {
+	cfg := &buildConfig{rngCounter: 1, version: DefaultAlgorithm}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(cfg)
+		}
+	}
+	return cfg
+}
+
+// Phase identifies a step of the construction of a filter.
+type Phase int
+
+const (
+	// PhaseHashing is the step where the keys are hashed and sorted into blocks.
+	PhaseHashing Phase = iota
+	// PhaseAdding is the step where the hashes are added to the construction arrays.
+	PhaseAdding
+	// PhasePeeling is the step where the keys are peeled off one at a time.
+	PhasePeeling
+	// PhaseAssigning is the step where the fingerprints are computed.
+	PhaseAssigning
+)
+
+func (p Phase) String() string {
+	switch p {
+	case PhaseHashing:
+		return "hashing"
+	case PhaseAdding:
+		return "adding"
+	case PhasePeeling:
+		return "peeling"
+	case PhaseAssigning:
+		return "assigning"
+	}
+	return "unknown"
+}
+
+// Progress describes how far a construction has gone.
+type Progress struct {
+	Phase Phase
+	// Iteration is the current construction attempt, starting at 1.
+	Iteration int
+	// Fraction is the completed fraction of the current phase, between 0 and 1.
+	Fraction float64
+}
+
+// progressInterval is the number of keys processed between two progress reports.
+const progressInterval = 1 << 16
+
+// WithProgress makes the construction call fn regularly with its progress,
+// for example to drive a progress bar. fn is called from the goroutine doing
+// the construction and should return quickly.
+func WithProgress(fn func(Progress)) Option {
+	return func(cfg *buildConfig) {
+		cfg.progress = fn
+	}
+}
+
+func (cfg *buildConfig) report(phase Phase, iteration int, done, total uint32) {
+	if cfg.progress == nil {
+		return
+	}
+	fraction := 1.0
+	if total > 0 && done < total {
+		fraction = float64(done) / float64(total)
+	}
+	cfg.progress(Progress{Phase: phase, Iteration: iteration, Fraction: fraction})
+}
+
+// BuildStats describes a construction.
+type BuildStats struct {
+	// Iterations is the number of construction attempts.
+	Iterations int
+	// Seed is the final seed of the filter.
+	Seed uint64
+	// Duration is the wall time of the construction.
+	Duration time.Duration
+	// ScratchBytes is the peak size of the temporary arrays, in bytes.
+	ScratchBytes uint64
+	// BitsPerEntry is the size of the fingerprints, in bits per distinct key.
+	BitsPerEntry float64
+}
+
+// WithStats makes the construction fill stats once it returns, whether it
+// succeeds or not.
+func WithStats(stats *BuildStats) Option {
+	return func(cfg *buildConfig) {
+		cfg.stats = stats
+	}
+}
+
+// WithMaxIterations overrides MaxIterations for one construction.
+func WithMaxIterations(n int) Option {
+	return func(cfg *buildConfig) {
+		cfg.maxIterations = n
+	}
+}
+
+func (cfg *buildConfig) iterationLimit() int {
+	if cfg.maxIterations > 0 {
+		return cfg.maxIterations
+	}
+	return MaxIterations
+}
+
+// A RetryPolicy is called after each failed construction attempt with the
+// number of attempts made so far. It returns false to give up.
+type RetryPolicy func(attempts int) bool
+
+// WithRetryPolicy makes the construction consult policy before each retry.
+// The construction still gives up after the maximum number of iterations.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(cfg *buildConfig) {
+		cfg.retryPolicy = policy
+	}
+}
+
+// WithRNGCounter sets the starting state of the generator from which the
+// seeds are drawn (1 by default). Different starting states lead to different
+// sequences of seeds, and thus to different filters.
+func WithRNGCounter(counter uint64) Option {
+	return func(cfg *buildConfig) {
+		cfg.rngCounter = counter
+	}
+}
+
+// WithSortedUniqueInput promises that the keys are sorted in strictly
+// increasing order, as they come from an index scan for example. The keys
+// are then known to be distinct, and the construction skips the binning of
+// their hashes by segment. The promise is checked as the keys are read: the
+// construction fails with ErrNotSortedUnique if it does not hold. It only
+// makes sense for uint64 keys, as the other keys are hashed first.
+func WithSortedUniqueInput() Option {
+	return func(cfg *buildConfig) {
+		cfg.sortedUnique = true
+	}
+}
+
+// WithDuplicateCheck makes the construction look for duplicate keys before
+// it starts, and fail right away with ErrDuplicateKeys if there are any,
+// instead of dropping them. The check sorts a copy of the keys, in time
+// O(n log n). The keys that are hashed first, such as strings, are checked
+// after hashing.
+func WithDuplicateCheck() Option {
+	return func(cfg *buildConfig) {
+		cfg.duplicateCheck = true
+	}
+}
+
+// WithVerifyKeys makes the construction query the new filter with all the
+// keys once it is built, and fail with ErrMissingKey if one of them is not
+// contained. It is a cheap check of the construction, worth enabling in
+// tests and staging.
+func WithVerifyKeys() Option {
+	return func(cfg *buildConfig) {
+		cfg.verifyKeys = true
+	}
+}
+
+// WithSizeFactor sets the number of fingerprints per key of a BinaryFuse8
+// filter, at least 1. By default, it goes from 1.125 for large sets up to
+// several for small ones. A larger factor uses more memory but makes the
+// construction succeed at the first attempt more often, and a smaller one
+// saves memory at the cost of more attempts. It does not apply to the sets of
+// fewer than 8 keys.
+func WithSizeFactor(factor float64) Option {
+	return func(cfg *buildConfig) {
+		cfg.sizeFactor = factor
+	}
+}
+
+// WithSegmentLength sets the length of the segments of a BinaryFuse8 filter,
+// a power of two up to 262144, instead of deriving it from the number of
+// keys. The length of the segments sets how far apart the three fingerprints
+// of a key can be, which matters for the cache behavior of the queries. It
+// does not apply to the sets of fewer than 8 keys.
+func WithSegmentLength(length uint32) Option {
+	return func(cfg *buildConfig) {
+		cfg.segmentLength = length
+	}
+}
+
+// WithParallelism makes the construction of a large filter use n goroutines,
+// or GOMAXPROCS goroutines if n <= 0. The keys given as a []uint64 slice are
+// hashed and binned in parallel, which needs the Hasher, if any, to be safe
+// for concurrent use. The filter is then split into ranges of segments that
+// are peeled in parallel, leaving the keys across two ranges to a sequential
+// pass. The filter differs from the one built without the option, but with
+// AlgorithmV2, and it does not depend on n or GOMAXPROCS: the same keys give
+// the same filter, byte for byte, on every machine.
+func WithParallelism(n int) Option {
+	return func(cfg *buildConfig) {
+		if n <= 0 {
+			n = runtime.GOMAXPROCS(0)
+		}
+		cfg.parallelism = n
+	}
+}
+
+// WithLowMemory makes the construction of a BinaryFuse8 filter add the keys
+// to the filter as they are hashed, instead of first storing and binning
+// all their hashes so that the filter is filled one region at a time. This
+// cuts the temporary memory of the construction from about 23 to about 15
+// bytes per key, at the cost of a slower construction of the filters that
+// do not fit in the CPU caches. For distinct keys, the filter is the same as
+// without the option.
+func WithLowMemory() Option {
+	return func(cfg *buildConfig) {
+		cfg.lowMemory = true
+	}
+}
+
+// WithAlignment aligns the fingerprints of a BinaryFuse8 filter to align
+// bytes, a power of two, such as the 4096 bytes of a page for an array that
+// is handed to mmap or to direct I/O. By default, the fingerprints are aligned
+// to a cache line of 64 bytes.
+func WithAlignment(align int) Option {
+	return func(cfg *buildConfig) {
+		cfg.alignment = align
+	}
+}
+
+// WithBlockBits bins the hashes of the keys into 2^bits blocks, 1 <= bits <=
+// 20, before they are added to a BinaryFuse8 filter, so that the keys of a
+// block are added to a narrow region of the filter. By default, the blocks are
+// sized from the number of segments and from the L2 cache of the CPU, and the
+// hashes are not binned at all when the construction arrays fit in the
+// cache; the option pins them for other cache hierarchies. It changes the
+// speed of the construction, not the filter built from distinct keys.
+func WithBlockBits(bits int) Option {
+	return func(cfg *buildConfig) {
+		cfg.blockBits = bits
+	}
+}
+
+// WithHugePages backs the fingerprints of a BinaryFuse8 filter of several
+// megabytes with huge pages, which saves the TLB misses of the queries of a
+// giant filter. On Linux, the fingerprints are mapped onto explicit huge pages
+// if the system reserved some, or else onto memory for which transparent huge
+// pages are requested; elsewhere, or if the mapping fails, the option has no
+// effect. The mapped memory is released once the filter is unreachable: its
+// Fingerprints must not be used apart from the filter.
+func WithHugePages() Option {
+	return func(cfg *buildConfig) {
+		cfg.hugePages = true
+	}
+}
+
+// WithScratchBuffer lays the temporary arrays of the construction of a
+// BinaryFuse8 filter out in buf instead of allocating them, so that services
+// building many short-lived filters do not churn the heap. buf must hold at
+// least the ScratchBytes of EstimateBinaryFuse8Memory, called with the same
+// options, plus 7 bytes if it is not 8-byte aligned; otherwise the
+// construction fails with ErrScratchTooSmall. The construction overwrites buf
+// and does not retain it, so buf may be reused once it returns, but not by
+// constructions running at the same time. The shards of a BinaryFuse8Big
+// filter are built one at a time in buf.
+func WithScratchBuffer(buf []byte) Option {
+	return func(cfg *buildConfig) {
+		cfg.scratch = buf
+	}
+}
+
+// WithMaxScratchMemory caps the temporary arrays of the construction of a
+// BinaryFuse8 filter at bytes, as reported in the ScratchBytes of BuildStats.
+// A construction that would need more peels sequentially rather than with
+// WithParallelism, but with AlgorithmV2, whose filter would change, and then
+// falls back to WithLowMemory, which is slower but needs about a third less
+// memory. If even that does not fit, it fails with ErrScratchTooSmall rather
+// than exhausting the memory of the process: the keys can then be given to
+// PopulateBinaryFuse8External instead, which builds a BinaryFuse8Big filter
+// within a much smaller cap.
+func WithMaxScratchMemory(bytes uint64) Option {
+	return func(cfg *buildConfig) {
+		cfg.maxScratch = bytes
+	}
+}
+
+// WithZeroizeScratch makes the construction of a BinaryFuse8 filter zero its
+// temporary arrays, which hold the hashes of the keys, and the copies of the
+// keys or of their hashes that it made, before it returns, whether it
+// succeeds or not, so that they do not linger in the memory of the process,
+// where a heap dump would show them. It suits the filters of sensitive keys,
+// such as password hashes or keys derived from personal data. The keys
+// themselves, the buffers of the key functions of
+// PopulateBinaryFuse8FromFunc, and the temporary files of
+// PopulateBinaryFuse8External are left to the caller.
+func WithZeroizeScratch() Option {
+	return func(cfg *buildConfig) {
+		cfg.zeroize = true
+	}
+}
+
+// fingerprintAlignment returns the alignment of the fingerprints.
+func (cfg *buildConfig) fingerprintAlignment() int {
+	if cfg.alignment > cacheLineSize {
+		return cfg.alignment
+	}
+	return cacheLineSize
+}
+
+// WithHasher makes the filter mix its keys with h instead of the default
+// mixing function. The filter keeps h, and uses it in Contains.
+func WithHasher(h Hasher) Option {
+	return func(cfg *buildConfig) {
+		cfg.hasher = h
+	}
+}
+
+// WithFingerprinter makes the filter derive the fingerprints of its keys with
+// f instead of the default derivation. The filter keeps f, and uses it in
+// Contains.
+func WithFingerprinter(f Fingerprinter) Option {
+	return func(cfg *buildConfig) {
+		cfg.fingerprinter = f
+	}
+}
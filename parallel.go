@@ -0,0 +1,279 @@
+package xorfilter
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// minKeysPerWorker is the smallest number of keys worth a goroutine of its
+// own during the construction.
+const minKeysPerWorker = 1 << 14
+
+// workersFor returns the number of goroutines to use for n keys with the
+// parallelism set by WithParallelism.
+func workersFor(parallelism int, n int) int {
+	if max := n / minKeysPerWorker; parallelism > max {
+		parallelism = max
+	}
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	return parallelism
+}
+
+// binParallel hashes keys and stores the hashes in reverseOrder, grouped by
+// their top blockBits bits, with the given number of goroutines. Each
+// goroutine counts the hashes of its share of keys per block, and then
+// writes them at the offsets derived from all the counts, so that no
+// synchronization is needed between the goroutines. The hashes are computed
+// twice, which is cheaper than storing them.
+func binParallel(filter *BinaryFuse8, keys []uint64, reverseOrder []uint64, blockBits int, workers int) {
+	blocks := 1 << uint(blockBits)
+	shift := uint(64 - blockBits)
+	counts := make([][]uint32, workers)
+	share := func(w int) []uint64 {
+		return keys[w*len(keys)/workers : (w+1)*len(keys)/workers]
+	}
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func(w int) {
+			defer wg.Done()
+			count := make([]uint32, blocks)
+			var hashes [hashChunkSize]uint64
+			for keys := share(w); len(keys) > 0; {
+				chunk := keys
+				if len(chunk) > len(hashes) {
+					chunk = chunk[:len(hashes)]
+				}
+				keys = keys[len(chunk):]
+				filter.hashKeys(hashes[:], chunk)
+				for _, hash := range hashes[:len(chunk)] {
+					count[hash>>shift]++
+				}
+			}
+			counts[w] = count
+		}(w)
+	}
+	wg.Wait()
+	// The counts become the offsets where each goroutine writes its hashes:
+	// block by block, and within a block, goroutine by goroutine.
+	pos := uint32(0)
+	for b := 0; b < blocks; b++ {
+		for w := 0; w < workers; w++ {
+			count := counts[w][b]
+			counts[w][b] = pos
+			pos += count
+		}
+	}
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func(w int) {
+			defer wg.Done()
+			offsets := counts[w]
+			var hashes [hashChunkSize]uint64
+			for keys := share(w); len(keys) > 0; {
+				chunk := keys
+				if len(chunk) > len(hashes) {
+					chunk = chunk[:len(hashes)]
+				}
+				keys = keys[len(chunk):]
+				filter.hashKeys(hashes[:], chunk)
+				for _, hash := range hashes[:len(chunk)] {
+					block := hash >> shift
+					reverseOrder[offsets[block]] = hash
+					offsets[block]++
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+// minSegmentsPerRange is the smallest number of segments of a range peeled by
+// a goroutine of its own. The keys whose three segments straddle two ranges
+// are left to the sequential peeling, so the ranges must be much wider than
+// the three segments of a key.
+const minSegmentsPerRange = 8
+
+// A peelRange is a range of slots [lo, hi) of the construction arrays, made
+// of whole segments, that a goroutine peels on its own.
+type peelRange struct {
+	lo, hi uint32
+	// peeled is the number of keys peeled within the range; the slots where
+	// they were peeled are in the peeled array of the Populator, from lo.
+	peeled uint32
+}
+
+// maxPeelRanges is the largest number of ranges of segments peeled in
+// parallel.
+const maxPeelRanges = 32
+
+// peelRangeCount returns the number of ranges of segments into which the
+// slots of filter are split, or 0 if the construction is to be sequential.
+// It depends on the size of the filter, but not on the number of goroutines,
+// which take the ranges in turn: the filter is then the same whatever the
+// parallelism. With AlgorithmV2, the ranges are peeled even without
+// parallelism.
+func peelRangeCount(filter *BinaryFuse8, cfg *buildConfig) int {
+	if cfg.parallelism == 0 && cfg.version < AlgorithmV2 {
+		return 0
+	}
+	ranges := int((filter.SegmentCount + 2) / minSegmentsPerRange)
+	if ranges > maxPeelRanges {
+		ranges = maxPeelRanges
+	}
+	if ranges < 2 {
+		return 0
+	}
+	return ranges
+}
+
+// forEachRange calls fn for each of the ranges, with the given number of
+// goroutines.
+func forEachRange(ranges []peelRange, workers int, fn func(r *peelRange)) {
+	if workers > len(ranges) {
+		workers = len(ranges)
+	}
+	var next int64
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt64(&next, 1) - 1)
+				if i >= len(ranges) {
+					return
+				}
+				fn(&ranges[i])
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// reserveRanges splits the slots of filter into the given number of ranges
+// of segments, as returned by peelRangeCount. It returns nil if there are
+// none.
+func (p *Populator) reserveRanges(filter *BinaryFuse8, count int) []peelRange {
+	if count == 0 {
+		return nil
+	}
+	segments := filter.SegmentCount + 2
+	capacity := uint32(len(filter.Fingerprints))
+	if uint32(cap(p.peeled)) < capacity {
+		p.peeled = make([]uint32, capacity)
+	} else {
+		p.peeled = p.peeled[:capacity]
+	}
+	p.ranges = p.ranges[:0]
+	for r := 0; r < count; r++ {
+		p.ranges = append(p.ranges, peelRange{
+			lo: uint32(uint64(r)*uint64(segments)/uint64(count)) * filter.SegmentLength,
+			hi: uint32(uint64(r+1)*uint64(segments)/uint64(count)) * filter.SegmentLength,
+		})
+	}
+	return p.ranges
+}
+
+// peelRanges peels the keys whose three slots are within one of the ranges,
+// with the given number of goroutines, and returns the number of keys
+// peeled. As the slots of a key are in consecutive segments, the ranges have
+// disjoint slots. The count of a slot where a key is peeled drops to zero, so
+// that the sequential peeling that follows skips it, but its hash and index
+// bits are kept for the assignment.
+func (p *Populator) peelRanges(filter *BinaryFuse8, ranges []peelRange, workers int) uint32 {
+	if len(ranges) == 0 {
+		// Without the closures and the goroutines, which are the bulk of
+		// the setup of the construction of a small filter.
+		return 0
+	}
+	forEachRange(ranges, workers, func(r *peelRange) {
+		p.peelRange(filter, r)
+	})
+	total := uint32(0)
+	for i := range ranges {
+		total += ranges[i].peeled
+	}
+	return total
+}
+
+func (p *Populator) peelRange(filter *BinaryFuse8, r *peelRange) {
+	t2count := p.t2count
+	t2hash := p.t2hash
+	// The queue and the peeled slots of the range are within [lo, hi) of
+	// alone and peeled: a slot enters the queue at most once.
+	alone := p.alone
+	peeled := p.peeled
+	var h012 [5]uint32
+	Qsize := r.lo
+	for i := r.lo; i < r.hi; i++ {
+		alone[Qsize] = i
+		if (t2count[i] >> 2) == 1 {
+			Qsize++
+		}
+	}
+	n := r.lo
+	for Qsize > r.lo {
+		Qsize--
+		index := alone[Qsize]
+		if (t2count[index] >> 2) != 1 {
+			continue
+		}
+		hash := t2hash[index]
+		index1, index2, index3 := filter.getHashFromHash(hash)
+		if index1 < r.lo || index3 >= r.hi {
+			// The key straddles two ranges.
+			continue
+		}
+		found := t2count[index] & 3
+		t2count[index] -= 4
+		peeled[n] = index
+		n++
+
+		h012[1] = index2
+		h012[2] = index3
+		h012[3] = index1
+		h012[4] = h012[1]
+
+		other_index1 := h012[found+1]
+		alone[Qsize] = other_index1
+		if (t2count[other_index1] >> 2) == 2 {
+			Qsize++
+		}
+		t2count[other_index1] -= 4
+		t2count[other_index1] ^= mod3(found + 1)
+		t2hash[other_index1] ^= hash
+
+		other_index2 := h012[found+2]
+		alone[Qsize] = other_index2
+		if (t2count[other_index2] >> 2) == 2 {
+			Qsize++
+		}
+		t2count[other_index2] -= 4
+		t2count[other_index2] ^= mod3(found + 2)
+		t2hash[other_index2] ^= hash
+	}
+	r.peeled = n - r.lo
+}
+
+// assignRanges computes the fingerprints of the keys peeled by peelRanges,
+// in the reverse order of their peeling, with the given number of goroutines.
+func (p *Populator) assignRanges(filter *BinaryFuse8, ranges []peelRange, workers int) {
+	if len(ranges) == 0 {
+		return
+	}
+	forEachRange(ranges, workers, func(r *peelRange) {
+		var h012 [5]uint32
+		for j := r.lo + r.peeled; j > r.lo; j-- {
+			index := p.peeled[j-1]
+			hash := p.t2hash[index]
+			found := p.t2count[index] & 3
+			h012[0], h012[1], h012[2] = filter.getHashFromHash(hash)
+			h012[3] = h012[0]
+			h012[4] = h012[1]
+			filter.Fingerprints[h012[found]] = filter.fingerprintOf(hash) ^ filter.Fingerprints[h012[found+1]] ^ filter.Fingerprints[h012[found+2]]
+		}
+	})
+}
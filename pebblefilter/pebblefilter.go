@@ -0,0 +1,82 @@
+// Package pebblefilter implements the filter policy of Pebble,
+// github.com/cockroachdb/pebble, with binary fuse filters:
+//
+//	opts.Levels[i].FilterPolicy = pebblefilter.FilterPolicy{}
+//
+// A binary fuse filter takes about 9 bits per key for a false positive rate
+// of about 0.4%, where a Bloom filter takes about 10 bits per key for 1%.
+//
+// The package is a module of its own, so that the xorfilter module does not
+// depend on Pebble.
+package pebblefilter
+
+import (
+	"github.com/FastFilter/xorfilter"
+	"github.com/cockroachdb/pebble"
+)
+
+// FilterPolicy is a pebble.FilterPolicy that builds a BinaryFuse8 filter for
+// the keys of each table. The name of the policy is stored in the tables, so
+// that Pebble only queries with it the filters it built.
+type FilterPolicy struct{}
+
+var _ pebble.FilterPolicy = FilterPolicy{}
+
+// Name returns the name of the policy, "xorfilter.BinaryFuse8".
+func (FilterPolicy) Name() string {
+	return "xorfilter.BinaryFuse8"
+}
+
+// MayContain returns whether key may be one of the keys of filter. A filter
+// that cannot be decoded may contain any key.
+func (FilterPolicy) MayContain(ftype pebble.FilterType, filter, key []byte) bool {
+	if ftype != pebble.TableFilter {
+		return true
+	}
+	f, err := xorfilter.ViewBinaryFuse8(filter)
+	if err != nil {
+		return true
+	}
+	return f.ContainsBytes(key)
+}
+
+// NewWriter returns a writer of the filters of type ftype, which must be
+// pebble.TableFilter.
+func (FilterPolicy) NewWriter(ftype pebble.FilterType) pebble.FilterWriter {
+	if ftype != pebble.TableFilter {
+		panic("pebblefilter: unsupported filter type")
+	}
+	return &filterWriter{}
+}
+
+// filterWriter hashes the keys as they are added, so that it does not keep
+// them: Pebble reuses their buffers.
+type filterWriter struct {
+	hashes []uint64
+}
+
+// AddKey adds key to the filter. Pebble adds the keys in order, so that a
+// key added several times in a row is only hashed once in the filter.
+func (w *filterWriter) AddKey(key []byte) {
+	hash := xorfilter.HashBytes(key)
+	if n := len(w.hashes); n > 0 && w.hashes[n-1] == hash {
+		return
+	}
+	w.hashes = append(w.hashes, hash)
+}
+
+// Finish appends the filter of the keys added to buf, and resets the writer.
+// If the filter cannot be built, which is unlikely, it appends nothing, and
+// the empty filter may contain any key.
+func (w *filterWriter) Finish(buf []byte) []byte {
+	f, err := xorfilter.PopulateBinaryFuse8(w.hashes)
+	w.hashes = w.hashes[:0]
+	if err != nil {
+		return buf
+	}
+	data, err := f.MarshalBinary()
+	if err != nil {
+		return buf
+	}
+	return append(buf, data...)
+}
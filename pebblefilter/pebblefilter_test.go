@@ -0,0 +1,36 @@
+package pebblefilter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cockroachdb/pebble"
+)
+
+func TestFilterPolicy(t *testing.T) {
+	var policy FilterPolicy
+	w := policy.NewWriter(pebble.TableFilter)
+	for round := 0; round < 2; round++ {
+		for i := 0; i < 10000; i++ {
+			key := []byte(fmt.Sprintf("key-%d-%d", round, i))
+			w.AddKey(key)
+			w.AddKey(key)
+		}
+		filter := w.Finish([]byte("prefix"))[len("prefix"):]
+		falsePositives := 0
+		for i := 0; i < 10000; i++ {
+			if !policy.MayContain(pebble.TableFilter, filter, []byte(fmt.Sprintf("key-%d-%d", round, i))) {
+				t.Fatalf("missing key %d in round %d", i, round)
+			}
+			if policy.MayContain(pebble.TableFilter, filter, []byte(fmt.Sprintf("other-%d", i))) {
+				falsePositives++
+			}
+		}
+		if falsePositives > 100 {
+			t.Errorf("%d false positives out of 10000", falsePositives)
+		}
+	}
+	if !policy.MayContain(pebble.TableFilter, nil, []byte("key")) {
+		t.Error("an empty filter must contain any key")
+	}
+}
@@ -0,0 +1,208 @@
+package xorfilter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/bits"
+	"sync"
+)
+
+// A Populator builds BinaryFuse8 filters and keeps its temporary arrays from
+// one construction to the next, so that services rebuilding filters of
+// similar sizes do not allocate them every time. The zero value is ready to
+// use. A Populator must not be used by several goroutines at once.
+type Populator struct {
+	alone        []uint32
+	t2count      []uint8
+	t2hash       []uint64
+	reverseOrder []uint64
+	startPos     []uint
+	peeled       []uint32
+	ranges       []peelRange
+}
+
+// PopulateBinaryFuse8 is like the PopulateBinaryFuse8 function, but reuses
+// the temporary arrays of p.
+func (p *Populator) PopulateBinaryFuse8(keys []uint64, opts ...Option) (*BinaryFuse8, error) {
+	return p.populateBinaryFuse8(context.Background(), len(keys), &sliceSource{keys: keys}, newBuildConfig(opts))
+}
+
+// PopulateBinaryFuse8Ctx is like the PopulateBinaryFuse8Ctx function, but
+// reuses the temporary arrays of p.
+func (p *Populator) PopulateBinaryFuse8Ctx(ctx context.Context, keys []uint64, opts ...Option) (*BinaryFuse8, error) {
+	return p.populateBinaryFuse8(ctx, len(keys), &sliceSource{keys: keys}, newBuildConfig(opts))
+}
+
+// PopulateBinaryFuse8FromReader is like the PopulateBinaryFuse8FromReader
+// function, but reuses the temporary arrays of p.
+func (p *Populator) PopulateBinaryFuse8FromReader(r io.Reader, n int, opts ...Option) (*BinaryFuse8, error) {
+	if n < 0 {
+		return nil, errors.New("invalid number of keys")
+	}
+	if r == nil && n > 0 {
+		return nil, errors.New("nil reader")
+	}
+	if uint64(n) > MaxBinaryFuse8Keys {
+		return nil, ErrTooManyKeys
+	}
+	src, err := newReaderSource(r, n)
+	if err != nil {
+		return nil, err
+	}
+	defer src.close()
+	return p.populateBinaryFuse8(context.Background(), n, src, newBuildConfig(opts))
+}
+
+// PopulateBinaryFuse8FromUint32 is like the PopulateBinaryFuse8FromUint32
+// function, but reuses the temporary arrays of p.
+func (p *Populator) PopulateBinaryFuse8FromUint32(keys []uint32, opts ...Option) (*BinaryFuse8, error) {
+	return p.populateBinaryFuse8(context.Background(), len(keys), &uint32Source{keys: keys}, newBuildConfig(opts))
+}
+
+// PopulateBinaryFuse8FromStrings is like the PopulateBinaryFuse8FromStrings
+// function, but reuses the temporary arrays of p.
+func (p *Populator) PopulateBinaryFuse8FromStrings(keys []string, opts ...Option) (*BinaryFuse8, error) {
+	return p.populateBinaryFuse8(context.Background(), len(keys), &stringSource{keys: keys}, newBuildConfig(opts))
+}
+
+// PopulateBinaryFuse8FromBytes is like the PopulateBinaryFuse8FromBytes
+// function, but reuses the temporary arrays of p.
+func (p *Populator) PopulateBinaryFuse8FromBytes(keys [][]byte, opts ...Option) (*BinaryFuse8, error) {
+	return p.populateBinaryFuse8(context.Background(), len(keys), &bytesSource{keys: keys}, newBuildConfig(opts))
+}
+
+// PopulateBinaryFuse8From128 is like the PopulateBinaryFuse8From128
+// function, but reuses the temporary arrays of p.
+func (p *Populator) PopulateBinaryFuse8From128(keys [][16]byte, opts ...Option) (*BinaryFuse8, error) {
+	return p.populateBinaryFuse8(context.Background(), len(keys), &uint128Source{keys: keys}, newBuildConfig(opts))
+}
+
+// PopulateBinaryFuse8FromUUIDs is like the PopulateBinaryFuse8FromUUIDs
+// function, but reuses the temporary arrays of p.
+func (p *Populator) PopulateBinaryFuse8FromUUIDs(uuids [][16]byte, opts ...Option) (*BinaryFuse8, error) {
+	return p.PopulateBinaryFuse8From128(uuids, opts...)
+}
+
+// PopulateBinaryFuse8Hashed is like the PopulateBinaryFuse8Hashed function,
+// but reuses the temporary arrays of p.
+func (p *Populator) PopulateBinaryFuse8Hashed(hashes []uint64, opts ...Option) (*BinaryFuse8, error) {
+	cfg := newBuildConfig(opts)
+	cfg.hasher = hashedHasher
+	return p.populateBinaryFuse8(context.Background(), len(hashes), &sliceSource{keys: hashes}, cfg)
+}
+
+// PopulateBinaryFuse8FromFunc is like the PopulateBinaryFuse8FromFunc
+// function, but reuses the temporary arrays of p.
+func (p *Populator) PopulateBinaryFuse8FromFunc(n int, keys func(emit func(keys []uint64) error) error, opts ...Option) (*BinaryFuse8, error) {
+	if n < 0 {
+		return nil, errors.New("invalid number of keys")
+	}
+	if keys == nil {
+		return nil, errors.New("nil key function")
+	}
+	src := &funcSource{fn: keys, n: n}
+	defer src.stop()
+	return p.populateBinaryFuse8(context.Background(), n, src, newBuildConfig(opts))
+}
+
+// Reset releases the temporary arrays retained by p.
+func (p *Populator) Reset() {
+	*p = Populator{}
+}
+
+// reserve sizes the temporary arrays for a construction, reusing the
+// existing ones when they are large enough. The arrays that the construction
+// expects to be zeroed are cleared.
+func (p *Populator) reserve(size, capacity uint32, blockBits int, lowMemory bool) {
+	if uint32(cap(p.alone)) < capacity {
+		p.alone = make([]uint32, capacity)
+	} else {
+		p.alone = p.alone[:capacity]
+	}
+	if uint32(cap(p.t2count)) < capacity {
+		p.t2count = make([]uint8, capacity)
+	} else {
+		p.t2count = p.t2count[:capacity]
+		for i := range p.t2count {
+			p.t2count[i] = 0
+		}
+	}
+	if uint32(cap(p.t2hash)) < capacity {
+		p.t2hash = make([]uint64, capacity)
+	} else {
+		p.t2hash = p.t2hash[:capacity]
+		for i := range p.t2hash {
+			p.t2hash[i] = 0
+		}
+	}
+	if lowMemory {
+		// The keys are hashed a chunk at a time, and not binned.
+		if cap(p.reverseOrder) < lowMemoryChunkSize {
+			p.reverseOrder = make([]uint64, lowMemoryChunkSize)
+		} else {
+			p.reverseOrder = p.reverseOrder[:lowMemoryChunkSize]
+		}
+		return
+	}
+	if uint64(cap(p.reverseOrder)) < uint64(size)+1 {
+		p.reverseOrder = make([]uint64, uint64(size)+1)
+	} else {
+		p.reverseOrder = p.reverseOrder[:uint64(size)+1]
+		for i := range p.reverseOrder {
+			p.reverseOrder[i] = 0
+		}
+	}
+	if blocks := 1 << uint(blockBits); cap(p.startPos) < blocks {
+		p.startPos = make([]uint, blocks)
+	} else {
+		p.startPos = p.startPos[:blocks]
+	}
+}
+
+// wipe zeroes the temporary arrays of p, up to their capacity, as they hold
+// the hashes of the keys of the last construction.
+func (p *Populator) wipe() {
+	wipeKeys(p.t2hash)
+	wipeKeys(p.reverseOrder)
+	t2count := p.t2count[:cap(p.t2count)]
+	for i := range t2count {
+		t2count[i] = 0
+	}
+	alone := p.alone[:cap(p.alone)]
+	for i := range alone {
+		alone[i] = 0
+	}
+	peeled := p.peeled[:cap(p.peeled)]
+	for i := range peeled {
+		peeled[i] = 0
+	}
+	startPos := p.startPos[:cap(p.startPos)]
+	for i := range startPos {
+		startPos[i] = 0
+	}
+}
+
+// populatorPools retains the Populators of the construction functions, so
+// that the temporary arrays are recycled between constructions. There is one
+// pool per power-of-two class of key count, so that small constructions do
+// not pin the arrays of large ones.
+var populatorPools [65]sync.Pool
+
+func populatorClass(n int) int {
+	if n < 0 {
+		n = 0
+	}
+	return bits.Len64(uint64(n))
+}
+
+func getPopulator(n int) *Populator {
+	if p, ok := populatorPools[populatorClass(n)].Get().(*Populator); ok {
+		return p
+	}
+	return new(Populator)
+}
+
+func putPopulator(n int, p *Populator) {
+	populatorPools[populatorClass(n)].Put(p)
+}
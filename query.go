@@ -0,0 +1,72 @@
+package xorfilter
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// queryChunkSize is the number of keys that a goroutine of ContainsParallel
+// queries at a time. It is a multiple of the cache line size, so that the
+// goroutines write to distinct cache lines of the results but at the
+// boundaries of their chunks, and large enough that they rarely contend for
+// the next chunk.
+const queryChunkSize = 64 * cacheLineSize
+
+// batchFilter is implemented by the filters that query several keys at a
+// time.
+type batchFilter interface {
+	ContainsBatch(keys []uint64, out []bool)
+}
+
+// ContainsParallel sets out[i] to whether keys[i] is part of the set of
+// filter, querying the keys with the given number of goroutines, or
+// GOMAXPROCS goroutines if workers <= 0. out must be at least as long as
+// keys. The goroutines take chunks of keys in turn, so that a slow chunk does
+// not hold the others up, and query them with ContainsBatch if filter has
+// it. The filter must not be modified while it is queried. Few keys are
+// queried by the calling goroutine alone.
+func ContainsParallel(filter Filter, keys []uint64, out []bool, workers int) {
+	out = out[:len(keys)]
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	chunks := (len(keys) + queryChunkSize - 1) / queryChunkSize
+	if workers > chunks {
+		workers = chunks
+	}
+	if workers < 2 {
+		containsChunk(filter, keys, out)
+		return
+	}
+	var next int64
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for {
+				lo := int(atomic.AddInt64(&next, 1)-1) * queryChunkSize
+				if lo >= len(keys) {
+					return
+				}
+				hi := lo + queryChunkSize
+				if hi > len(keys) {
+					hi = len(keys)
+				}
+				containsChunk(filter, keys[lo:hi], out[lo:hi])
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func containsChunk(filter Filter, keys []uint64, out []bool) {
+	if f, ok := filter.(batchFilter); ok {
+		f.ContainsBatch(keys, out)
+		return
+	}
+	for i, key := range keys {
+		out[i] = filter.Contains(key)
+	}
+}
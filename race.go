@@ -0,0 +1,80 @@
+package xorfilter
+
+import (
+	"context"
+	"runtime"
+)
+
+// PopulateBinaryFuse8Race builds a BinaryFuse8 filter for keys with k
+// concurrent constructions, or GOMAXPROCS if k <= 0, each trying its own
+// sequence of seeds, and returns the first filter built: the others are
+// canceled. A construction needs a second attempt once in a while, and
+// seldom more, so this spends k times the CPU and the memory of a
+// construction to cut the latency of the unlucky ones. The filter depends
+// on which construction wins, and may differ from one call to the next.
+//
+// The options apply to every construction. The WithStats option describes
+// the winning construction, the progress function of WithProgress is only
+// called by the first construction, and WithScratchBuffer, which cannot be
+// shared, is ignored. If all the constructions fail, the error is that of
+// the first one.
+func PopulateBinaryFuse8Race(ctx context.Context, keys []uint64, k int, opts ...Option) (*BinaryFuse8, error) {
+	if k <= 0 {
+		k = runtime.GOMAXPROCS(0)
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type result struct {
+		filter *BinaryFuse8
+		err    error
+		stats  BuildStats
+	}
+	results := make([]chan result, k)
+	for i := range results {
+		results[i] = make(chan result, 1)
+		cfg := newBuildConfig(opts)
+		stats := cfg.stats
+		if i > 0 {
+			cfg.progress = nil
+			cfg.seeded = false
+		}
+		// The sequences of seeds are spaced far apart; the first is that
+		// of a construction on its own.
+		cfg.rngCounter += uint64(i) << 48
+		cfg.scratch = nil
+		go func(cfg *buildConfig, done chan<- result) {
+			var r result
+			if stats != nil {
+				cfg.stats = &r.stats
+			}
+			p := getPopulator(len(keys))
+			r.filter, r.err = p.populateBinaryFuse8(ctx, len(keys), &sliceSource{keys: keys}, cfg)
+			putPopulator(len(keys), p)
+			if r.err == nil {
+				cancel()
+			}
+			done <- r
+		}(cfg, results[i])
+	}
+	var winner *result
+	var firstErr error
+	for i := range results {
+		r := <-results[i]
+		if r.err == nil && winner == nil {
+			winner = &r
+		}
+		if i == 0 {
+			firstErr = r.err
+		}
+	}
+	if winner == nil {
+		return nil, firstErr
+	}
+	if stats := newBuildConfig(opts).stats; stats != nil {
+		*stats = winner.stats
+	}
+	return winner.filter, nil
+}
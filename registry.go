@@ -0,0 +1,126 @@
+package xorfilter
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Registry holds BinaryFuse8 filters by name, such as one per tenant, per
+// table or per day, for the services that query many filters. A filter is
+// replaced atomically by Set: a query answers from either the old or the new
+// filter, never from a mix of both. A filter may expire after a time to
+// live, after which it answers as absent, until Evict drops it or Set
+// replaces it. A Registry is safe for concurrent use; it is created by
+// NewRegistry. The filters it holds must not be modified.
+type Registry struct {
+	ttl time.Duration
+	// now is time.Now, but for the tests.
+	now func() time.Time
+
+	mu      sync.RWMutex
+	entries map[string]registryEntry
+}
+
+// registryEntry is a filter of a Registry with its expiry time, which is
+// zero if it does not expire.
+type registryEntry struct {
+	filter  *BinaryFuse8
+	expires time.Time
+}
+
+// NewRegistry returns an empty Registry whose filters expire ttl after they
+// are set, or never if ttl is not positive.
+func NewRegistry(ttl time.Duration) *Registry {
+	return &Registry{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]registryEntry),
+	}
+}
+
+// Set sets the filter of name, with the time to live of the registry, and
+// returns the filter it replaces, or nil, even if it had expired.
+func (r *Registry) Set(name string, filter *BinaryFuse8) *BinaryFuse8 {
+	return r.SetWithTTL(name, filter, r.ttl)
+}
+
+// SetWithTTL is Set with a time to live of its own, which never expires if
+// it is not positive.
+func (r *Registry) SetWithTTL(name string, filter *BinaryFuse8, ttl time.Duration) *BinaryFuse8 {
+	entry := registryEntry{filter: filter}
+	if ttl > 0 {
+		entry.expires = r.now().Add(ttl)
+	}
+	r.mu.Lock()
+	old := r.entries[name]
+	r.entries[name] = entry
+	r.mu.Unlock()
+	return old.filter
+}
+
+// Get returns the filter of name, and whether there is one that has not
+// expired.
+func (r *Registry) Get(name string) (*BinaryFuse8, bool) {
+	r.mu.RLock()
+	entry, ok := r.entries[name]
+	r.mu.RUnlock()
+	if !ok || entry.expired(r.now()) {
+		return nil, false
+	}
+	return entry.filter, true
+}
+
+// Contains returns whether key is part of the filter of name, as Contains
+// does, and false if there is no such filter or if it has expired.
+func (r *Registry) Contains(name string, key uint64) bool {
+	filter, ok := r.Get(name)
+	return ok && filter.Contains(key)
+}
+
+// Delete drops the filter of name, and returns it, or nil.
+func (r *Registry) Delete(name string) *BinaryFuse8 {
+	r.mu.Lock()
+	old := r.entries[name]
+	delete(r.entries, name)
+	r.mu.Unlock()
+	return old.filter
+}
+
+// Evict drops the filters that have expired, and returns their number. The
+// expired filters already answer as absent: Evict releases their memory, so
+// a service whose filters expire calls it from time to time.
+func (r *Registry) Evict() int {
+	now := r.now()
+	evicted := 0
+	r.mu.Lock()
+	for name, entry := range r.entries {
+		if entry.expired(now) {
+			delete(r.entries, name)
+			evicted++
+		}
+	}
+	r.mu.Unlock()
+	return evicted
+}
+
+// Names returns the names of the filters that have not expired, in
+// increasing order.
+func (r *Registry) Names() []string {
+	now := r.now()
+	r.mu.RLock()
+	names := make([]string, 0, len(r.entries))
+	for name, entry := range r.entries {
+		if !entry.expired(now) {
+			names = append(names, name)
+		}
+	}
+	r.mu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// expired returns whether the entry has expired at now.
+func (entry registryEntry) expired(now time.Time) bool {
+	return !entry.expires.IsZero() && !now.Before(entry.expires)
+}
@@ -0,0 +1,101 @@
+package xorfilter
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := NewRegistry(time.Hour)
+	r.now = func() time.Time { return now }
+	filters := make(map[string][]uint64)
+	for _, name := range []string{"tenant-a", "tenant-b", "2024-01-01"} {
+		keys := make([]uint64, SMALL_NUM_KEYS)
+		for i := range keys {
+			keys[i] = rand.Uint64()
+		}
+		filters[name] = keys
+		filter, err := PopulateBinaryFuse8(keys)
+		assert.Equal(t, nil, err)
+		if name == "2024-01-01" {
+			assert.Nil(t, r.SetWithTTL(name, filter, 24*time.Hour))
+		} else {
+			assert.Nil(t, r.Set(name, filter))
+		}
+	}
+	assert.Equal(t, []string{"2024-01-01", "tenant-a", "tenant-b"}, r.Names())
+	for name, keys := range filters {
+		for _, key := range keys {
+			assert.True(t, r.Contains(name, key))
+		}
+	}
+	assert.False(t, r.Contains("tenant-c", filters["tenant-a"][0]))
+
+	// A replacement takes effect at once, with a new time to live, and
+	// returns the old filter.
+	now = now.Add(30 * time.Minute)
+	old, _ := r.Get("tenant-a")
+	replacement, err := PopulateBinaryFuse8([]uint64{1, 2, 3})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, old, r.Set("tenant-a", replacement))
+	assert.True(t, r.Contains("tenant-a", 1))
+
+	// The filters expire after their time to live.
+	assert.True(t, r.Contains("tenant-b", filters["tenant-b"][0]))
+	now = now.Add(30 * time.Minute)
+	assert.False(t, r.Contains("tenant-b", filters["tenant-b"][0]))
+	_, ok := r.Get("tenant-b")
+	assert.False(t, ok)
+	// tenant-a was replaced 30 minutes ago.
+	assert.Equal(t, []string{"2024-01-01", "tenant-a"}, r.Names())
+	assert.Equal(t, 1, r.Evict())
+	assert.Equal(t, 0, r.Evict())
+	assert.Equal(t, replacement, r.Delete("tenant-a"))
+	assert.Nil(t, r.Delete("tenant-a"))
+	assert.Equal(t, []string{"2024-01-01"}, r.Names())
+
+	// A registry without a time to live keeps its filters.
+	forever := NewRegistry(0)
+	forever.now = func() time.Time { return now }
+	forever.Set("a", replacement)
+	now = now.Add(1000 * time.Hour)
+	assert.True(t, forever.Contains("a", 1))
+	assert.Equal(t, 0, forever.Evict())
+}
+
+func TestRegistryConcurrent(t *testing.T) {
+	r := NewRegistry(0)
+	var filters [2]*BinaryFuse8
+	for i := range filters {
+		var err error
+		filters[i], err = PopulateBinaryFuse8([]uint64{uint64(i)})
+		assert.Equal(t, nil, err)
+	}
+	r.Set("f", filters[0])
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				// Either filter contains one of the keys.
+				if !r.Contains("f", 0) && !r.Contains("f", 1) {
+					filter, _ := r.Get("f")
+					if !filter.Contains(0) && !filter.Contains(1) {
+						t.Error("no filter")
+						return
+					}
+				}
+			}
+		}()
+	}
+	for i := 0; i < 1000; i++ {
+		r.Set("f", filters[i%2])
+	}
+	wg.Wait()
+}
@@ -0,0 +1,12 @@
+module github.com/FastFilter/xorfilter/roaringfilter
+
+go 1.21
+
+require (
+	github.com/FastFilter/xorfilter v0.0.0
+	github.com/RoaringBitmap/roaring v1.9.4
+)
+
+require github.com/bits-and-blooms/bitset v1.12.0 // indirect
+
+replace github.com/FastFilter/xorfilter => ../
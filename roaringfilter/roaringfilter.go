@@ -0,0 +1,48 @@
+// Package roaringfilter builds filters from the Roaring bitmaps of
+// github.com/RoaringBitmap/roaring, which many systems use to hold their
+// sets of keys:
+//
+//	filter, err := roaringfilter.PopulateFromRoaring64(bm)
+//	filter.Contains(key)
+//
+// The package is a module of its own, so that the xorfilter module does not
+// depend on roaring.
+package roaringfilter
+
+import (
+	"errors"
+
+	"github.com/FastFilter/xorfilter"
+	"github.com/RoaringBitmap/roaring/roaring64"
+)
+
+// chunkSize is the number of keys taken out of a bitmap at a time.
+const chunkSize = 4096
+
+// PopulateFromRoaring64 returns the filter of the keys of bm. The keys are
+// iterated out of the bitmap a chunk at a time, for each pass of the
+// construction, without a copy of all of them: as they come out sorted and
+// distinct, the construction is that of WithSortedUniqueInput, which opts
+// need not include. bm must not be modified during the construction.
+func PopulateFromRoaring64(bm *roaring64.Bitmap, opts ...xorfilter.Option) (*xorfilter.BinaryFuse8, error) {
+	n := bm.GetCardinality()
+	if n > uint64(maxInt) {
+		return nil, errors.New("roaringfilter: too many keys")
+	}
+	opts = append(opts[:len(opts):len(opts)], xorfilter.WithSortedUniqueInput())
+	return xorfilter.PopulateBinaryFuse8FromFunc(int(n), func(emit func(keys []uint64) error) error {
+		chunk := make([]uint64, chunkSize)
+		it := bm.ManyIterator()
+		for {
+			count := it.NextMany(chunk)
+			if count == 0 {
+				return nil
+			}
+			if err := emit(chunk[:count]); err != nil {
+				return err
+			}
+		}
+	}, opts...)
+}
+
+const maxInt = int(^uint(0) >> 1)
@@ -0,0 +1,33 @@
+package roaringfilter
+
+import (
+	"testing"
+
+	"github.com/RoaringBitmap/roaring/roaring64"
+)
+
+func TestPopulateFromRoaring64(t *testing.T) {
+	bm := roaring64.New()
+	for i := uint64(0); i < 100000; i++ {
+		bm.Add(i * 7919)
+	}
+	bm.AddRange(1<<40, 1<<40+50000)
+	filter, err := PopulateFromRoaring64(bm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	it := bm.Iterator()
+	for it.HasNext() {
+		if key := it.Next(); !filter.Contains(key) {
+			t.Fatalf("key %d is missing", key)
+		}
+	}
+
+	empty, err := PopulateFromRoaring64(roaring64.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if empty.Contains(1) {
+		t.Fatal("the empty filter contains a key")
+	}
+}
@@ -0,0 +1,52 @@
+// Package rocksdbfilter implements a custom filter policy of RocksDB with
+// binary fuse filters, for the Go bindings of RocksDB such as grocksdb,
+// github.com/linxGnu/grocksdb, whose FilterPolicy interface it satisfies:
+//
+//	bbto.SetFilterPolicy(rocksdbfilter.FilterPolicy{})
+//
+// The custom filter policies are the block-based filters of RocksDB: a filter
+// is built for the keys of about 2KB of data at a time, and RocksDB stores
+// the filters along with their offsets in the filter block of the table. The
+// Go callbacks are only available with the versions of RocksDB before 7.0,
+// which removed them from the C API.
+//
+// The package does not depend on the bindings, whose interfaces only involve
+// byte slices.
+package rocksdbfilter
+
+import "github.com/FastFilter/xorfilter"
+
+// FilterPolicy builds a BinaryFuse8 filter for each group of keys. A filter
+// is the encoding of MarshalBinary, which RocksDB stores as is and hands back
+// to KeyMayMatch. The name of the policy is stored in the tables, so that
+// RocksDB only queries with it the filters it built.
+type FilterPolicy struct{}
+
+// Name returns the name of the policy, "xorfilter.BinaryFuse8".
+func (FilterPolicy) Name() string {
+	return "xorfilter.BinaryFuse8"
+}
+
+// CreateFilter returns the filter of keys. If the filter cannot be built,
+// which is unlikely, it returns an empty filter, which may contain any key.
+func (FilterPolicy) CreateFilter(keys [][]byte) []byte {
+	f, err := xorfilter.PopulateBinaryFuse8FromBytes(keys)
+	if err != nil {
+		return nil
+	}
+	data, err := f.MarshalBinary()
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// KeyMayMatch returns whether key may be one of the keys of filter. A filter
+// that cannot be decoded may contain any key.
+func (FilterPolicy) KeyMayMatch(key []byte, filter []byte) bool {
+	f, err := xorfilter.ViewBinaryFuse8(filter)
+	if err != nil {
+		return true
+	}
+	return f.ContainsBytes(key)
+}
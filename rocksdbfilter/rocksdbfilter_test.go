@@ -0,0 +1,28 @@
+package rocksdbfilter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterPolicy(t *testing.T) {
+	var policy FilterPolicy
+	keys := make([][]byte, 10000)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("key-%d", i))
+	}
+	filter := policy.CreateFilter(keys)
+	for _, key := range keys {
+		assert.True(t, policy.KeyMayMatch(key, filter))
+	}
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if policy.KeyMayMatch([]byte(fmt.Sprintf("other-%d", i)), filter) {
+			falsePositives++
+		}
+	}
+	assert.True(t, falsePositives < 100, "%d false positives", falsePositives)
+	assert.True(t, policy.KeyMayMatch([]byte("key"), nil))
+}
@@ -0,0 +1,165 @@
+package xorfilter
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RollingFilter holds the keys seen over a sliding period of time, in
+// buckets of a fixed duration, such as one per hour over the past day: a
+// common way to drop the duplicate events of a stream. The keys of the
+// current bucket are held in a map; once the bucket is over, a BinaryFuse8
+// filter of its keys is built in the background, and replaces the map. The
+// buckets past the period are dropped. A RollingFilter is safe for
+// concurrent use; it is created by NewRollingFilter.
+type RollingFilter struct {
+	bucket  time.Duration
+	buckets int
+	opts    []Option
+	// now is time.Now, but for the tests.
+	now func() time.Time
+
+	mu sync.RWMutex
+	// current is the bucket of the keys added, at index currentIndex, the
+	// number of bucket durations since the zero Unix time.
+	current      map[uint64]struct{}
+	currentIndex int64
+	// sealed are the buckets before current, oldest first.
+	sealed []*rollingBucket
+}
+
+// rollingBucket is a bucket of a RollingFilter that is over. Until its filter
+// is built, its keys answer the queries.
+type rollingBucket struct {
+	index  int64
+	keys   map[uint64]struct{}
+	filter *BinaryFuse8
+	// sorted holds the keys instead of filter in the unlikely case where
+	// the construction fails.
+	sorted []uint64
+}
+
+// NewRollingFilter returns an empty RollingFilter with the given number of
+// buckets of the given duration, which must be positive. The options apply to
+// the construction of the filter of each bucket.
+func NewRollingFilter(bucket time.Duration, buckets int, opts ...Option) (*RollingFilter, error) {
+	if bucket <= 0 || buckets <= 0 {
+		return nil, errors.New("the buckets of a RollingFilter must have a positive duration and number")
+	}
+	return &RollingFilter{
+		bucket:  bucket,
+		buckets: buckets,
+		opts:    opts,
+		now:     time.Now,
+		current: make(map[uint64]struct{}),
+	}, nil
+}
+
+// Add adds key to the current bucket.
+func (r *RollingFilter) Add(key uint64) {
+	index := r.index(r.now())
+	r.mu.Lock()
+	r.rotate(index)
+	r.current[key] = struct{}{}
+	r.mu.Unlock()
+}
+
+// SeenWithin returns whether key was added within window of now, as
+// Contains does: it is true if key was, and almost always false otherwise.
+// The time is that of the buckets: the keys of a bucket that overlaps the
+// window count as seen within it, and the keys of the buckets dropped do
+// not.
+func (r *RollingFilter) SeenWithin(key uint64, window time.Duration) bool {
+	now := r.now()
+	index := r.index(now)
+	// The oldest bucket that overlaps [now-window, now].
+	first := r.index(now.Add(-window))
+	r.mu.RLock()
+	if index > r.currentIndex {
+		r.mu.RUnlock()
+		r.mu.Lock()
+		r.rotate(index)
+		r.mu.Unlock()
+		r.mu.RLock()
+	}
+	defer r.mu.RUnlock()
+	if _, ok := r.current[key]; ok {
+		return true
+	}
+	for i := len(r.sealed) - 1; i >= 0 && r.sealed[i].index >= first; i-- {
+		if r.sealed[i].contains(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// index returns the index of the bucket of t.
+func (r *RollingFilter) index(t time.Time) int64 {
+	ns := t.UnixNano()
+	index := ns / int64(r.bucket)
+	if ns < 0 && ns%int64(r.bucket) != 0 {
+		index--
+	}
+	return index
+}
+
+// rotate moves on to the bucket at index, if it follows the current one: it
+// seals the current bucket, unless it is empty, and drops the buckets that
+// fell out of the period. It must be called with r.mu locked.
+func (r *RollingFilter) rotate(index int64) {
+	if index <= r.currentIndex {
+		return
+	}
+	if len(r.current) > 0 {
+		b := &rollingBucket{index: r.currentIndex, keys: r.current}
+		r.sealed = append(r.sealed, b)
+		r.current = make(map[uint64]struct{})
+		go r.seal(b)
+	}
+	r.currentIndex = index
+	expired := 0
+	for expired < len(r.sealed) && r.sealed[expired].index <= index-int64(r.buckets) {
+		expired++
+	}
+	if expired > 0 {
+		r.sealed = append(r.sealed[:0], r.sealed[expired:]...)
+	}
+}
+
+// seal builds the filter of b, which then replaces its keys.
+func (r *RollingFilter) seal(b *rollingBucket) {
+	// The map is no longer modified: it is read without the lock.
+	keys := make([]uint64, 0, len(b.keys))
+	for key := range b.keys {
+		keys = append(keys, key)
+	}
+	filter, err := PopulateBinaryFuse8(keys, r.opts...)
+	if err != nil {
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	}
+	r.mu.Lock()
+	if err != nil {
+		b.sorted = keys
+	} else {
+		b.filter = filter
+	}
+	b.keys = nil
+	r.mu.Unlock()
+}
+
+// contains returns whether key may be part of the bucket. It must be called
+// with the lock of the RollingFilter held.
+func (b *rollingBucket) contains(key uint64) bool {
+	switch {
+	case b.keys != nil:
+		_, ok := b.keys[key]
+		return ok
+	case b.filter != nil:
+		return b.filter.Contains(key)
+	}
+	i := sort.Search(len(b.sorted), func(i int) bool { return b.sorted[i] >= key })
+	return i < len(b.sorted) && b.sorted[i] == key
+}
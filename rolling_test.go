@@ -0,0 +1,51 @@
+package xorfilter
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRollingFilter(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 10, 0, 0, time.UTC)
+	now := start
+	r, err := NewRollingFilter(time.Hour, 3)
+	assert.Equal(t, nil, err)
+	r.now = func() time.Time { return now }
+	keys := make([]uint64, SMALL_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+		r.Add(keys[i])
+	}
+	assert.True(t, r.SeenWithin(keys[0], time.Minute))
+	now = now.Add(time.Hour)
+	r.Add(1)
+	for _, key := range keys {
+		assert.True(t, r.SeenWithin(key, 2*time.Hour))
+	}
+	assert.False(t, r.SeenWithin(keys[0], 5*time.Minute))
+	assert.True(t, r.SeenWithin(1, 5*time.Minute))
+	// Wait for the filter of the first bucket.
+	for {
+		r.mu.RLock()
+		sealed := r.sealed[0].filter != nil
+		r.mu.RUnlock()
+		if sealed {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for _, key := range keys {
+		assert.True(t, r.SeenWithin(key, 2*time.Hour))
+	}
+	now = now.Add(2 * time.Hour)
+	assert.True(t, r.SeenWithin(1, 24*time.Hour))
+	assert.False(t, r.SeenWithin(keys[0], 24*time.Hour))
+	now = now.Add(time.Hour)
+	assert.False(t, r.SeenWithin(1, 24*time.Hour))
+	r.mu.RLock()
+	assert.Equal(t, 0, len(r.sealed))
+	r.mu.RUnlock()
+}
@@ -0,0 +1,75 @@
+package xorfilter
+
+import "math/bits"
+
+// The ContainsSafe methods query filters whose fields may be inconsistent,
+// because they were filled from untrusted or corrupted data without a call
+// to Validate. They compute the indexes of the fingerprints in 64 bits, so
+// that they cannot wrap around, and answer false rather than read outside of
+// the fingerprints. On a consistent filter, they answer as Contains, a little
+// slower; on an inconsistent one, they may answer anything but never panic.
+// Validate, which the decoding of filters runs, remains the way to reject
+// such filters once and query them with Contains afterwards.
+
+// ContainsSafe is like Contains, but never panics, whatever the fields of
+// the filter.
+func (filter *BinaryFuse8) ContainsSafe(key uint64) bool {
+	if filter == nil {
+		return false
+	}
+	hash := filter.hash(key)
+	hi, _ := bits.Mul64(hash, uint64(filter.SegmentCountLength))
+	length, mask := uint64(filter.SegmentLength), uint64(filter.SegmentLengthMask)
+	h0 := hi
+	h1 := (h0 + length) ^ (hash>>18)&mask
+	h2 := (h0 + 2*length) ^ hash&mask
+	return fingerprintsContain(filter.Fingerprints, filter.fingerprintOf(hash), h0, h1, h2)
+}
+
+// ContainsSafe is like Contains, but never panics, whatever the fields of
+// the filter.
+func (filter *BinaryFuse8Big) ContainsSafe(key uint64) bool {
+	if filter == nil || len(filter.Shards) == 0 {
+		return false
+	}
+	return filter.Shards[filter.shard(key)].ContainsSafe(key)
+}
+
+// ContainsSafe is like Contains, but never panics, whatever the fields of
+// the filter.
+func (filter *Xor8) ContainsSafe(key uint64) bool {
+	if filter == nil {
+		return false
+	}
+	hash := mixsplit(key, filter.Seed)
+	length := uint64(filter.BlockLength)
+	h0 := uint64(reduce(uint32(hash), filter.BlockLength))
+	h1 := uint64(reduce(uint32(rotl64(hash, 21)), filter.BlockLength)) + length
+	h2 := uint64(reduce(uint32(rotl64(hash, 42)), filter.BlockLength)) + 2*length
+	return fingerprintsContain(filter.Fingerprints, uint8(fingerprint(hash)), h0, h1, h2)
+}
+
+// ContainsSafe is like Contains, but never panics, whatever the fields of
+// the filter.
+func (filter *Fuse8) ContainsSafe(key uint64) bool {
+	if filter == nil {
+		return false
+	}
+	hash := mixsplit(key, filter.Seed)
+	length := uint64(filter.SegmentLength)
+	seg := uint64(reduce(uint32(hash), SEGMENT_COUNT))
+	h0 := seg*length + uint64(reduce(uint32(rotl64(hash, 21)), filter.SegmentLength))
+	h1 := (seg+1)*length + uint64(reduce(uint32(rotl64(hash, 42)), filter.SegmentLength))
+	h2 := (seg+2)*length + uint64(reduce(uint32((0xBF58476D1CE4E5B9*hash)>>32), filter.SegmentLength))
+	return fingerprintsContain(filter.Fingerprints, uint8(fingerprint(hash)), h0, h1, h2)
+}
+
+// fingerprintsContain returns whether the fingerprints at h0, h1 and h2 match
+// f, and false if one of them is out of the fingerprints.
+func fingerprintsContain(fingerprints []uint8, f uint8, h0, h1, h2 uint64) bool {
+	n := uint64(len(fingerprints))
+	if h0 >= n || h1 >= n || h2 >= n {
+		return false
+	}
+	return f == fingerprints[h0]^fingerprints[h1]^fingerprints[h2]
+}
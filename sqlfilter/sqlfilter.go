@@ -0,0 +1,119 @@
+// Package sqlfilter builds filters from the rows of SQL queries, with any
+// driver of database/sql:
+//
+//	filter, err := sqlfilter.BuildFromRows(ctx, db, "SELECT id FROM banned_users")
+//	filter.Contains(uint64(id))
+//
+// The query returns a single column. The integers are the keys themselves,
+// queried with Contains, a negative one as its uint64 conversion; the strings
+// and byte strings are hashed as PopulateBinaryFuse8FromStrings hashes them,
+// and queried with ContainsString or ContainsBytes.
+package sqlfilter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/FastFilter/xorfilter"
+)
+
+// Queryer runs a query; *sql.DB, *sql.Conn and *sql.Tx are Queryers.
+type Queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// BuildFromRows runs query with args on db, and returns the filter of the
+// keys of its rows, as ReadKeys reads them.
+func BuildFromRows(ctx context.Context, db Queryer, query string, args ...interface{}) (*xorfilter.BinaryFuse8, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := ReadKeys(rows)
+	if err != nil {
+		return nil, err
+	}
+	return xorfilter.PopulateBinaryFuse8(keys, xorfilter.WithSortedUniqueInput())
+}
+
+// ReadKeys returns the keys of the rows, sorted and distinct, and closes
+// rows. The rows are streamed: only the 8 bytes of each key are held. An
+// integer column, by the scan type of its driver, is read as integers, even
+// if the driver sends them as text; otherwise, each value is read as an
+// integer or as a string according to its own type.
+func ReadKeys(rows *sql.Rows) ([]uint64, error) {
+	defer rows.Close()
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	if len(types) != 1 {
+		return nil, fmt.Errorf("sqlfilter: the query returns %d columns instead of 1", len(types))
+	}
+	integers := false
+	if t := types[0].ScanType(); t != nil {
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			integers = true
+		}
+	}
+	var keys []uint64
+	for rows.Next() {
+		var key uint64
+		if integers {
+			var n sql.NullInt64
+			if err := rows.Scan(&n); err != nil {
+				return nil, err
+			}
+			if !n.Valid {
+				return nil, errNull
+			}
+			key = uint64(n.Int64)
+		} else {
+			var v interface{}
+			if err := rows.Scan(&v); err != nil {
+				return nil, err
+			}
+			if key, err = valueKey(v); err != nil {
+				return nil, err
+			}
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// The rows may repeat a key, which the construction would have to
+	// remove: the keys are made distinct at once.
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	n := 0
+	for i, key := range keys {
+		if i == 0 || key != keys[n-1] {
+			keys[n] = key
+			n++
+		}
+	}
+	return keys[:n], nil
+}
+
+var errNull = errors.New("sqlfilter: the query returns a NULL key")
+
+// valueKey returns the key of a value scanned by database/sql.
+func valueKey(v interface{}) (uint64, error) {
+	switch v := v.(type) {
+	case int64:
+		return uint64(v), nil
+	case []byte:
+		return xorfilter.HashBytes(v), nil
+	case string:
+		return xorfilter.HashBytes([]byte(v)), nil
+	case nil:
+		return 0, errNull
+	}
+	return 0, fmt.Errorf("sqlfilter: the query returns a key of type %T, neither an integer nor a string", v)
+}
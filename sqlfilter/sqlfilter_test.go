@@ -0,0 +1,116 @@
+package sqlfilter
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The test driver returns the rows of a query of the form "column:v1,v2,...",
+// of integers if column is "int", integers in text if column is "textint",
+// and strings otherwise.
+type testDriver struct{}
+
+func (testDriver) Open(name string) (driver.Conn, error) { return testConn{}, nil }
+
+type testConn struct{}
+
+func (testConn) Prepare(query string) (driver.Stmt, error) { return testStmt(query), nil }
+func (testConn) Close() error                              { return nil }
+func (testConn) Begin() (driver.Tx, error)                 { return nil, errors.New("no transactions") }
+
+type testStmt string
+
+func (testStmt) Close() error                                    { return nil }
+func (testStmt) NumInput() int                                   { return -1 }
+func (testStmt) Exec(args []driver.Value) (driver.Result, error) { return nil, errors.New("no exec") }
+func (s testStmt) Query(args []driver.Value) (driver.Rows, error) {
+	parts := strings.SplitN(string(s), ":", 2)
+	rows := &testRows{column: parts[0]}
+	if parts[1] != "" {
+		rows.values = strings.Split(parts[1], ",")
+	}
+	return rows, nil
+}
+
+type testRows struct {
+	column string
+	values []string
+}
+
+func (r *testRows) Columns() []string { return strings.Split(r.column, "+") }
+func (r *testRows) Close() error      { return nil }
+func (r *testRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	v := r.values[0]
+	r.values = r.values[1:]
+	switch {
+	case v == "NULL":
+		dest[0] = nil
+	case r.column == "int":
+		var n int64
+		for _, c := range strings.TrimPrefix(v, "-") {
+			n = 10*n + int64(c-'0')
+		}
+		if strings.HasPrefix(v, "-") {
+			n = -n
+		}
+		dest[0] = n
+	default:
+		dest[0] = []byte(v)
+	}
+	for i := 1; i < len(dest); i++ {
+		dest[i] = nil
+	}
+	return nil
+}
+
+func (r *testRows) ColumnTypeScanType(index int) reflect.Type {
+	if r.column == "int" || r.column == "textint" {
+		return reflect.TypeOf(int64(0))
+	}
+	return reflect.TypeOf("")
+}
+
+func init() {
+	sql.Register("sqlfiltertest", testDriver{})
+}
+
+func TestBuildFromRows(t *testing.T) {
+	db, err := sql.Open("sqlfiltertest", "")
+	assert.Equal(t, nil, err)
+	defer db.Close()
+	ctx := context.Background()
+
+	for _, column := range []string{"int", "textint"} {
+		filter, err := BuildFromRows(ctx, db, column+":1,2,3,2,-1")
+		assert.Equal(t, nil, err, column)
+		for _, id := range []int64{1, 2, 3, -1} {
+			assert.True(t, filter.Contains(uint64(id)), column)
+		}
+	}
+	filter, err := BuildFromRows(ctx, db, "name:alice,bob")
+	assert.Equal(t, nil, err)
+	assert.True(t, filter.ContainsString("alice"))
+	assert.True(t, filter.ContainsBytes([]byte("bob")))
+
+	filter, err = BuildFromRows(ctx, db, "name:")
+	assert.Equal(t, nil, err)
+	assert.False(t, filter.ContainsString("alice"))
+
+	_, err = BuildFromRows(ctx, db, "int:1,NULL")
+	assert.Equal(t, errNull, err)
+	_, err = BuildFromRows(ctx, db, "name:alice,NULL")
+	assert.Equal(t, errNull, err)
+	_, err = BuildFromRows(ctx, db, "id+name:1")
+	assert.NotEqual(t, nil, err)
+}
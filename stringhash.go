@@ -0,0 +1,45 @@
+package xorfilter
+
+import "github.com/FastFilter/xorfilter/hash"
+
+// The first two constants of wyhash, with which the keys are also hashed to
+// the shards of a BinaryFuse8Big filter.
+const (
+	wyp0 = 0xa0761d6478bd642f
+	wyp1 = 0xe7037ed1a0b428db
+)
+
+// stringSeed is the seed with which the string keys are hashed. It is part of
+// the format of the filters built from strings and must never change.
+const stringSeed = hash.StringSeed
+
+func wymix(a, b uint64) uint64 {
+	return hash.WyMix(a, b)
+}
+
+// hashString hashes s to a 64-bit key with the wyhash variant used by the Go
+// runtime. The result only depends on the bytes of s and on seed.
+func hashString(s string, seed uint64) uint64 {
+	return hash.WyHash(s, seed)
+}
+
+// hashBytes is hashString for a byte slice: a slice and a string with the same
+// bytes have the same hash. b is not copied.
+func hashBytes(b []byte, seed uint64) uint64 {
+	return hash.WyHashBytes(b, seed)
+}
+
+// HashBytes returns the 64-bit key that PopulateBinaryFuse8FromBytes and
+// ContainsBytes derive from b: a filter built by PopulateBinaryFuse8 from the
+// keys HashBytes(b) contains b for ContainsBytes. It lets the keys be hashed
+// as they arrive, rather than kept until the filter is built. It is
+// hash.Bytes.
+func HashBytes(b []byte) uint64 {
+	return hash.Bytes(b)
+}
+
+// hashUint128 is hashBytes for the 16 bytes of hi and lo in big-endian order,
+// that is the byte order of a UUID.
+func hashUint128(hi, lo, seed uint64) uint64 {
+	return hash.WyHash128(hi, lo, seed)
+}
@@ -0,0 +1,219 @@
+package urlfilter
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Canonicalize returns the canonical form of rawURL, as defined by the Safe
+// Browsing API, https://developers.google.com/safe-browsing/v4/urls-hashing:
+// the tabs and line breaks, the fragment and the port are removed, the URL is
+// unescaped until it has no more escapes, the host is lower-cased and freed
+// of extra dots, an IPv4 address in any of its forms is written in dotted
+// decimal, the path is resolved, and the control, non-ASCII, space, "#" and
+// "%" characters are escaped again. A URL without a scheme is an http URL.
+func Canonicalize(rawURL string) string {
+	scheme, host, path, query, hasQuery := canonicalParts(rawURL)
+	s := scheme + "://" + host + path
+	if hasQuery {
+		s += "?" + query
+	}
+	return s
+}
+
+// canonicalParts returns the parts of the canonical form of rawURL, escaped,
+// and whether it has a query, which may be empty.
+func canonicalParts(rawURL string) (scheme, host, path, query string, hasQuery bool) {
+	// The bytes are removed one by one, rather than as runes, so that the
+	// bytes that are not UTF-8 are kept.
+	b := []byte(strings.TrimSpace(rawURL))
+	n := 0
+	for _, c := range b {
+		if c != '\t' && c != '\r' && c != '\n' {
+			b[n] = c
+			n++
+		}
+	}
+	s := string(b[:n])
+	if i := strings.IndexByte(s, '#'); i >= 0 {
+		s = s[:i]
+	}
+	s = unescapeAll(s)
+	scheme = "http"
+	if i := strings.Index(s, "://"); i >= 0 {
+		scheme, s = asciiLower(s[:i]), s[i+3:]
+	}
+	end := strings.IndexAny(s, "/?")
+	if end < 0 {
+		end = len(s)
+	}
+	host, rest := s[:end], s[end:]
+	if i := strings.LastIndexByte(host, '@'); i >= 0 {
+		host = host[i+1:]
+	}
+	if i := strings.LastIndexByte(host, ':'); i >= 0 {
+		host = host[:i]
+	}
+	path = rest
+	if i := strings.IndexByte(rest, '?'); i >= 0 {
+		path, query, hasQuery = rest[:i], rest[i+1:], true
+	}
+	return scheme, escape(canonicalHost(host)), escape(canonicalPath(path)), escape(query), hasQuery
+}
+
+// unescapeAll replaces the valid percent escapes of s until there are none.
+func unescapeAll(s string) string {
+	for {
+		unescaped := unescape(s)
+		if unescaped == s {
+			return s
+		}
+		s = unescaped
+	}
+}
+
+func unescape(s string) string {
+	if strings.IndexByte(s, '%') < 0 {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			b.WriteByte(unhex(s[i+1])<<4 | unhex(s[i+2]))
+			i += 2
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c <= '9':
+		return c - '0'
+	case c <= 'F':
+		return c - 'A' + 10
+	}
+	return c - 'a' + 10
+}
+
+// escape escapes the bytes of s that are controls, spaces, non-ASCII, "#"
+// or "%".
+func escape(s string) string {
+	const digits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= ' ' || c >= 0x7f || c == '#' || c == '%' {
+			b.WriteByte('%')
+			b.WriteByte(digits[c>>4])
+			b.WriteByte(digits[c&15])
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// asciiLower lower-cases the ASCII letters of s, and leaves its other bytes,
+// which need not be UTF-8, as they are.
+func asciiLower(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
+// canonicalHost returns host lower-cased, without leading, trailing and
+// consecutive dots, and in dotted decimal if it is an IPv4 address.
+func canonicalHost(host string) string {
+	labels := strings.Split(asciiLower(host), ".")
+	n := 0
+	for _, label := range labels {
+		if label != "" {
+			labels[n] = label
+			n++
+		}
+	}
+	host = strings.Join(labels[:n], ".")
+	if ip, ok := parseIPv4(labels[:n]); ok {
+		return strconv.Itoa(int(ip>>24)) + "." + strconv.Itoa(int(ip>>16&0xff)) + "." +
+			strconv.Itoa(int(ip>>8&0xff)) + "." + strconv.Itoa(int(ip&0xff))
+	}
+	return host
+}
+
+// parseIPv4 parses the labels of a host as an IPv4 address, as inet_aton
+// does: one to four numbers, in decimal, octal with a leading 0, or
+// hexadecimal with a leading 0x, the last of which fills the remaining bytes.
+func parseIPv4(labels []string) (uint32, bool) {
+	if len(labels) == 0 || len(labels) > 4 {
+		return 0, false
+	}
+	ip := uint64(0)
+	for i, label := range labels {
+		base := 10
+		switch {
+		case len(label) > 2 && (label[:2] == "0x" || label[:2] == "0X"):
+			label, base = label[2:], 16
+		case len(label) > 1 && label[0] == '0':
+			label, base = label[1:], 8
+		}
+		v, err := strconv.ParseUint(label, base, 32)
+		if err != nil {
+			return 0, false
+		}
+		if i < len(labels)-1 {
+			if v > 0xff {
+				return 0, false
+			}
+			ip |= v << uint(8*(3-i))
+			continue
+		}
+		if bits := uint(8 * (4 - i)); v >= 1<<bits {
+			return 0, false
+		}
+		ip |= v
+	}
+	return uint32(ip), true
+}
+
+// canonicalPath resolves the "." and ".." segments of path, and removes its
+// empty segments, keeping its trailing slash.
+func canonicalPath(path string) string {
+	segments := strings.Split(path, "/")
+	resolved := make([]string, 0, len(segments))
+	trailing := false
+	for _, segment := range segments {
+		trailing = false
+		switch segment {
+		case "":
+			trailing = true
+		case ".":
+			trailing = true
+		case "..":
+			if len(resolved) > 0 {
+				resolved = resolved[:len(resolved)-1]
+			}
+			trailing = true
+		default:
+			resolved = append(resolved, segment)
+		}
+	}
+	if len(resolved) == 0 {
+		return "/"
+	}
+	s := "/" + strings.Join(resolved, "/")
+	if trailing {
+		s += "/"
+	}
+	return s
+}
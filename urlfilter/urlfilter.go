@@ -0,0 +1,133 @@
+// Package urlfilter screens URLs against a filter of URL expressions, as the
+// Safe Browsing API does with its lists of malicious URLs:
+//
+//	filter, err := urlfilter.Build([]string{"evil.example/", "example.com/phishing/"})
+//	filter.ContainsURL("http://www.evil.example/login?user=1") // true
+//
+// An expression is a host and a path, without the scheme, in the canonical
+// form of Canonicalize. A URL matches the expressions of the suffixes of its
+// host and of the prefixes of its path, listed by Expressions: the
+// expression "evil.example/" blocks all the URLs of evil.example and of its
+// subdomains. The key of an expression is the first 64 bits of its SHA-256,
+// the hash of Safe Browsing, so that its lists of full hashes can be loaded
+// as well.
+package urlfilter
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"strings"
+
+	"github.com/FastFilter/xorfilter"
+)
+
+// maxHostSuffixes and maxPathPrefixes bound the number of host suffixes and
+// of path prefixes of a URL besides its host and its path, as Safe Browsing
+// does.
+const (
+	maxHostSuffixes = 4
+	maxPathPrefixes = 4
+)
+
+// Expressions returns the expressions that a URL matches, after its
+// canonicalization: the combinations of its host, or of up to 4 suffixes of
+// its host made of its last 5 to 2 labels unless it is an IP address, and of
+// its path with its query, its path without it, and up to 4 prefixes of its
+// path ending with a slash. The first expression is the URL itself, without
+// its scheme.
+//
+// For http://a.b.c/1/2.html?param=1, they are a.b.c/1/2.html?param=1,
+// a.b.c/1/2.html, a.b.c/, a.b.c/1/, b.c/1/2.html?param=1, b.c/1/2.html, b.c/
+// and b.c/1/.
+func Expressions(rawURL string) []string {
+	_, host, path, query, hasQuery := canonicalParts(rawURL)
+
+	hosts := []string{host}
+	if _, ok := parseIPv4(strings.Split(host, ".")); !ok {
+		labels := strings.Split(host, ".")
+		n := len(labels) - 1
+		if n > maxHostSuffixes+1 {
+			n = maxHostSuffixes + 1
+		}
+		for ; n >= 2; n-- {
+			hosts = append(hosts, strings.Join(labels[len(labels)-n:], "."))
+		}
+	}
+
+	var paths []string
+	if hasQuery {
+		paths = append(paths, path+"?"+query)
+	}
+	paths = append(paths, path)
+	prefix := "/"
+	rest := path[1:]
+	for i := 0; i < maxPathPrefixes; i++ {
+		if prefix != path {
+			paths = append(paths, prefix)
+		}
+		slash := strings.IndexByte(rest, '/')
+		if slash < 0 {
+			break
+		}
+		prefix += rest[:slash+1]
+		rest = rest[slash+1:]
+	}
+
+	expressions := make([]string, 0, len(hosts)*len(paths))
+	for _, host := range hosts {
+		for _, path := range paths {
+			expressions = append(expressions, host+path)
+		}
+	}
+	return expressions
+}
+
+// Key returns the key of an expression, the first 64 bits of its SHA-256.
+func Key(expression string) uint64 {
+	sum := sha256.Sum256([]byte(expression))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// A Filter is a filter of expressions.
+type Filter struct {
+	*xorfilter.BinaryFuse8
+}
+
+// Build returns the filter of expressions, which must be canonical: that of
+// a URL is Expressions(url)[0].
+func Build(expressions []string, opts ...xorfilter.Option) (*Filter, error) {
+	keys := make([]uint64, len(expressions))
+	for i, expression := range expressions {
+		keys[i] = Key(expression)
+	}
+	return BuildFromKeys(keys, opts...)
+}
+
+// BuildFromKeys returns the filter of the keys of expressions, such as the
+// first 8 bytes of the full hashes of a Safe Browsing list, in big-endian
+// order.
+func BuildFromKeys(keys []uint64, opts ...xorfilter.Option) (*Filter, error) {
+	filter, err := xorfilter.PopulateBinaryFuse8(keys, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{BinaryFuse8: filter}, nil
+}
+
+// Match returns the first of the expressions of rawURL that may be in the
+// filter, and whether there is one.
+func (f *Filter) Match(rawURL string) (string, bool) {
+	for _, expression := range Expressions(rawURL) {
+		if f.Contains(Key(expression)) {
+			return expression, true
+		}
+	}
+	return "", false
+}
+
+// ContainsURL returns whether any of the expressions of rawURL may be in the
+// filter.
+func (f *Filter) ContainsURL(rawURL string) bool {
+	_, ok := f.Match(rawURL)
+	return ok
+}
@@ -0,0 +1,241 @@
+package xorfilter
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// A TestVector is a BinaryFuse8 filter built from a fixed set of keys, with
+// the default options but WithRNGCounter and WithAlgorithmVersion. The filter
+// only depends on the keys, on the counter and on the version: the ports of this package to other languages can
+// check that they build the same filters, bit for bit, from the keys of the
+// vectors written by WriteTestVectors, or have their own vectors checked by
+// Verify.
+type TestVector struct {
+	Name string
+	// Keys are the uint64 keys of the filter, or if Strings is not nil,
+	// the string keys, as PopulateBinaryFuse8FromStrings hashes them.
+	Keys       []uint64
+	Strings    []string
+	RNGCounter uint64
+	Version    AlgorithmVersion
+	// Filter is the filter built from the keys.
+	Filter BinaryFuse8
+}
+
+// NewTestVector returns the test vector of the keys, the counter and the
+// version.
+func NewTestVector(name string, keys []uint64, rngCounter uint64, version AlgorithmVersion) (*TestVector, error) {
+	v := &TestVector{Name: name, Keys: keys, RNGCounter: rngCounter, Version: version}
+	filter, err := v.build()
+	if err != nil {
+		return nil, err
+	}
+	v.Filter = *filter
+	return v, nil
+}
+
+// NewStringTestVector returns the test vector of the string keys, the counter
+// and the version.
+func NewStringTestVector(name string, keys []string, rngCounter uint64, version AlgorithmVersion) (*TestVector, error) {
+	v := &TestVector{Name: name, Strings: keys, RNGCounter: rngCounter, Version: version}
+	filter, err := v.build()
+	if err != nil {
+		return nil, err
+	}
+	v.Filter = *filter
+	return v, nil
+}
+
+func (v *TestVector) build() (*BinaryFuse8, error) {
+	opts := []Option{WithRNGCounter(v.RNGCounter), WithAlgorithmVersion(v.Version)}
+	if v.Strings != nil {
+		return PopulateBinaryFuse8FromStrings(v.Strings, opts...)
+	}
+	return PopulateBinaryFuse8(v.Keys, opts...)
+}
+
+// Verify builds the filter of the keys of the vector, and returns an error
+// that tells the first difference with the filter of the vector, if any.
+func (v *TestVector) Verify() error {
+	filter, err := v.build()
+	if err != nil {
+		return fmt.Errorf("%s: %v", v.Name, err)
+	}
+	want := &v.Filter
+	fields := []struct {
+		name      string
+		got, want uint64
+	}{
+		{"algorithm version", uint64(filter.version), uint64(want.version)},
+		{"seed", filter.Seed, want.Seed},
+		{"segment length", uint64(filter.SegmentLength), uint64(want.SegmentLength)},
+		{"segment length mask", uint64(filter.SegmentLengthMask), uint64(want.SegmentLengthMask)},
+		{"segment count", uint64(filter.SegmentCount), uint64(want.SegmentCount)},
+		{"segment count length", uint64(filter.SegmentCountLength), uint64(want.SegmentCountLength)},
+		{"number of fingerprints", uint64(len(filter.Fingerprints)), uint64(len(want.Fingerprints))},
+	}
+	for _, f := range fields {
+		if f.got != f.want {
+			return fmt.Errorf("%s: %s is %d, want %d", v.Name, f.name, f.got, f.want)
+		}
+	}
+	for i := range filter.Fingerprints {
+		if filter.Fingerprints[i] != want.Fingerprints[i] {
+			return fmt.Errorf("%s: fingerprint %d is %#02x, want %#02x", v.Name, i, filter.Fingerprints[i], want.Fingerprints[i])
+		}
+	}
+	return nil
+}
+
+// CanonicalTestVectors returns the test vectors of this package: filters of
+// a few sizes, from the tiny filters of a single segment to filters of
+// several segments, of uint64 and of string keys, and of each algorithm
+// version. The uint64 keys of the filter of n keys are the first n outputs of
+// splitmix64 from the state n<<32, and its RNGCounter is n.
+func CanonicalTestVectors() ([]*TestVector, error) {
+	var vectors []*TestVector
+	sets := []struct {
+		n       int
+		version AlgorithmVersion
+	}{
+		{1, AlgorithmV1}, {2, AlgorithmV1}, {3, AlgorithmV1}, {7, AlgorithmV1},
+		{8, AlgorithmV1}, {9, AlgorithmV1}, {100, AlgorithmV1}, {1000, AlgorithmV1},
+		// A filter that AlgorithmV2 peels in ranges.
+		{3000, AlgorithmV2},
+	}
+	for _, set := range sets {
+		state := uint64(set.n) << 32
+		keys := make([]uint64, set.n)
+		for i := range keys {
+			keys[i] = splitmix64(&state)
+		}
+		v, err := NewTestVector(fmt.Sprintf("uint64-%d-%v", set.n, set.version), keys, uint64(set.n), set.version)
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, v)
+	}
+	keys := make([]string, 100)
+	for i := range keys {
+		keys[i] = "key-" + strconv.Itoa(i)
+	}
+	keys[0] = ""
+	v, err := NewStringTestVector("string-100-v1", keys, 1, AlgorithmV1)
+	if err != nil {
+		return nil, err
+	}
+	return append(vectors, v), nil
+}
+
+// jsonTestVector is the JSON form of a TestVector. The 64-bit integers are
+// hexadecimal strings, which the JSON parsers of all languages read without
+// rounding them to doubles, and the fingerprints are a hexadecimal string.
+type jsonTestVector struct {
+	Name               string   `json:"name"`
+	Keys               []string `json:"keys,omitempty"`
+	Strings            []string `json:"strings,omitempty"`
+	RNGCounter         string   `json:"rng_counter"`
+	Version            uint8    `json:"algorithm_version"`
+	Seed               string   `json:"seed"`
+	SegmentLength      uint32   `json:"segment_length"`
+	SegmentLengthMask  uint32   `json:"segment_length_mask"`
+	SegmentCount       uint32   `json:"segment_count"`
+	SegmentCountLength uint32   `json:"segment_count_length"`
+	Fingerprints       string   `json:"fingerprints"`
+}
+
+// WriteTestVectors writes the vectors to w as a JSON array of objects with
+// the fields name, keys or strings, rng_counter, algorithm_version, seed, segment_length,
+// segment_length_mask, segment_count, segment_count_length and fingerprints.
+// The 64-bit integers are strings of 16 hexadecimal digits, and the
+// fingerprints a string of 2 hexadecimal digits per fingerprint.
+func WriteTestVectors(w io.Writer, vectors []*TestVector) error {
+	out := make([]jsonTestVector, len(vectors))
+	for i, v := range vectors {
+		out[i] = jsonTestVector{
+			Name:               v.Name,
+			Strings:            v.Strings,
+			RNGCounter:         formatUint64(v.RNGCounter),
+			Version:            uint8(v.Version),
+			Seed:               formatUint64(v.Filter.Seed),
+			SegmentLength:      v.Filter.SegmentLength,
+			SegmentLengthMask:  v.Filter.SegmentLengthMask,
+			SegmentCount:       v.Filter.SegmentCount,
+			SegmentCountLength: v.Filter.SegmentCountLength,
+			Fingerprints:       hex.EncodeToString(v.Filter.Fingerprints),
+		}
+		if v.Strings == nil {
+			out[i].Keys = make([]string, len(v.Keys))
+			for j, key := range v.Keys {
+				out[i].Keys[j] = formatUint64(key)
+			}
+		}
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// ReadTestVectors reads the vectors written by WriteTestVectors.
+func ReadTestVectors(r io.Reader) ([]*TestVector, error) {
+	var in []jsonTestVector
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&in); err != nil {
+		return nil, err
+	}
+	vectors := make([]*TestVector, len(in))
+	for i, j := range in {
+		v := &TestVector{
+			Name:    j.Name,
+			Strings: j.Strings,
+			Version: AlgorithmVersion(j.Version),
+			Filter: BinaryFuse8{
+				version:            AlgorithmVersion(j.Version),
+				SegmentLength:      j.SegmentLength,
+				SegmentLengthMask:  j.SegmentLengthMask,
+				SegmentCount:       j.SegmentCount,
+				SegmentCountLength: j.SegmentCountLength,
+			},
+		}
+		var err error
+		if v.RNGCounter, err = parseUint64(j.RNGCounter); err != nil {
+			return nil, fmt.Errorf("%s: rng_counter: %v", j.Name, err)
+		}
+		if v.Filter.Seed, err = parseUint64(j.Seed); err != nil {
+			return nil, fmt.Errorf("%s: seed: %v", j.Name, err)
+		}
+		if v.Filter.Fingerprints, err = hex.DecodeString(j.Fingerprints); err != nil {
+			return nil, fmt.Errorf("%s: fingerprints: %v", j.Name, err)
+		}
+		if j.Strings == nil {
+			v.Keys = make([]uint64, len(j.Keys))
+			for k, key := range j.Keys {
+				if v.Keys[k], err = parseUint64(key); err != nil {
+					return nil, fmt.Errorf("%s: key %d: %v", j.Name, k, err)
+				}
+			}
+		}
+		if err := v.Filter.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", j.Name, err)
+		}
+		vectors[i] = v
+	}
+	return vectors, nil
+}
+
+func formatUint64(x uint64) string {
+	return fmt.Sprintf("%016x", x)
+}
+
+func parseUint64(s string) (uint64, error) {
+	return strconv.ParseUint(strings.TrimPrefix(s, "0x"), 16, 64)
+}
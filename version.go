@@ -0,0 +1,77 @@
+package xorfilter
+
+import (
+	"errors"
+	"fmt"
+)
+
+// An AlgorithmVersion identifies the construction of the BinaryFuse8
+// filters: how a filter is sized for a number of keys, and in which order its
+// keys are peeled and their fingerprints assigned. A version is frozen once
+// released: the same keys, options, version and seed build the same filter,
+// byte for byte, in every later release of the package, while the default
+// construction may move to a newer version. The queries do not depend on the
+// version.
+type AlgorithmVersion uint8
+
+const (
+	// AlgorithmV1 is the construction of the releases from before the
+	// versions, which did not save the filters. The keys are peeled sequentially, unless WithParallelism
+	// first peels the ranges of segments of a large filter in parallel,
+	// which gives another filter.
+	AlgorithmV1 AlgorithmVersion = 1
+	// AlgorithmV2 always peels the ranges of segments of a large filter
+	// first, with a single goroutine without WithParallelism, so that the
+	// filter is the same with and without WithParallelism or
+	// WithMaxScratchMemory. It takes 4 more bytes of temporary memory per
+	// fingerprint of the filters of 14 segments or more.
+	AlgorithmV2 AlgorithmVersion = 2
+
+	// DefaultAlgorithm is the version of the constructions without
+	// WithAlgorithmVersion.
+	DefaultAlgorithm = AlgorithmV1
+)
+
+// errUnknownVersion is returned for a construction with a version that this
+// release does not know.
+var errUnknownVersion = errors.New("unknown algorithm version")
+
+func (v AlgorithmVersion) String() string {
+	if v == 0 {
+		return "unknown"
+	}
+	return fmt.Sprintf("v%d", v)
+}
+
+// WithAlgorithmVersion makes the construction of a BinaryFuse8 filter follow
+// the given version, rather than DefaultAlgorithm, and fail if this release
+// does not know it. The filter records its version, which MarshalBinary
+// saves with it: a saved filter can thus be built again from its keys, with
+// its version and its seed, after an upgrade of the package.
+func WithAlgorithmVersion(v AlgorithmVersion) Option {
+	return func(cfg *buildConfig) {
+		cfg.version = v
+	}
+}
+
+// WithSeed makes the first attempt of the construction of a BinaryFuse8
+// filter use seed, rather than the first seed drawn from the RNG counter; the
+// next attempts, if any, use the seeds drawn from the counter. With the seed
+// and the version of a filter, the construction rebuilds that filter from
+// its keys at the first attempt. PopulateBinaryFuse8Race gives the seed to
+// its first construction only.
+func WithSeed(seed uint64) Option {
+	return func(cfg *buildConfig) {
+		cfg.seed = seed
+		cfg.seeded = true
+	}
+}
+
+// AlgorithmVersion returns the version of the construction of the filter, or
+// 0 if it is not known, as for a filter whose fields were set by hand.
+func (filter *BinaryFuse8) AlgorithmVersion() AlgorithmVersion {
+	if filter == nil {
+		return 0
+	}
+	return filter.version
+}
@@ -0,0 +1,116 @@
+//go:build js && wasm
+// +build js,wasm
+
+// Command wasm queries a filter in a browser, built on a server and
+// downloaded, so that the keys never leave the server:
+//
+//	GOOS=js GOARCH=wasm go build -o xorfilter.wasm ./wasm
+//
+// Once run with the wasm_exec.js of the Go distribution, it defines a global
+// xorfilter object with the functions:
+//
+//	xorfilter.loadFilter(bytes)  loads a filter encoded by MarshalBinary, from
+//	                             a Uint8Array, and returns null, or an Error
+//	xorfilter.contains(key)      whether the string key may be in the set, as
+//	                             ContainsString
+//	xorfilter.containsUint64(key) whether the uint64 key, a BigInt, a safe
+//	                             integer Number, or a decimal string, may be in
+//	                             the set, as Contains
+//
+// For instance:
+//
+//	const data = new Uint8Array(await (await fetch("filter.bin")).arrayBuffer());
+//	const err = xorfilter.loadFilter(data);
+//	if (err === null && xorfilter.contains(email)) { ... }
+package main
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"syscall/js"
+
+	"github.com/FastFilter/xorfilter"
+)
+
+// filter is the filter loaded, queried on the single thread of JavaScript.
+var filter *xorfilter.BinaryFuse8
+
+var errNoFilter = errors.New("xorfilter: no filter loaded")
+
+func jsError(err error) js.Value {
+	return js.Global().Get("Error").New(err.Error())
+}
+
+// loadFilter implements xorfilter.loadFilter.
+func loadFilter(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 || typeOf.Invoke(args[0]).String() != "object" || !args[0].InstanceOf(js.Global().Get("Uint8Array")) {
+		return jsError(errors.New("xorfilter.loadFilter: expected a Uint8Array"))
+	}
+	data := make([]byte, args[0].Get("length").Int())
+	js.CopyBytesToGo(data, args[0])
+	// The filter keeps data, which is not shared, as its fingerprints.
+	f, err := xorfilter.ViewBinaryFuse8(data)
+	if err != nil {
+		return jsError(err)
+	}
+	filter = f
+	return nil
+}
+
+// contains implements xorfilter.contains.
+func contains(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 || typeOf.Invoke(args[0]).String() != "string" {
+		return jsError(errors.New("xorfilter.contains: expected a string"))
+	}
+	if filter == nil {
+		return jsError(errNoFilter)
+	}
+	return filter.ContainsString(args[0].String())
+}
+
+// containsUint64 implements xorfilter.containsUint64.
+func containsUint64(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 {
+		return jsError(errors.New("xorfilter.containsUint64: expected a key"))
+	}
+	key, err := uint64Key(args[0])
+	if err != nil {
+		return jsError(err)
+	}
+	if filter == nil {
+		return jsError(errNoFilter)
+	}
+	return filter.Contains(key)
+}
+
+// typeOf is the typeof operator of JavaScript. The Type method of js.Value
+// does not know BigInts, and panics on them.
+var typeOf = js.Global().Get("Function").New("v", "return typeof v")
+
+// uint64Key returns the uint64 of a BigInt, a Number or a decimal string.
+func uint64Key(v js.Value) (uint64, error) {
+	switch typeOf.Invoke(v).String() {
+	case "number":
+		f := v.Float()
+		if f < 0 || f > 1<<53 || f != math.Trunc(f) {
+			return 0, errors.New("xorfilter.containsUint64: the Number is not a safe integer; use a BigInt")
+		}
+		return uint64(f), nil
+	case "bigint":
+		return strconv.ParseUint(js.Global().Call("String", v).String(), 10, 64)
+	case "string":
+		return strconv.ParseUint(v.String(), 10, 64)
+	}
+	return 0, errors.New("xorfilter.containsUint64: expected a BigInt, a Number or a string")
+}
+
+func main() {
+	js.Global().Set("xorfilter", js.ValueOf(map[string]interface{}{
+		"loadFilter":     js.FuncOf(loadFilter),
+		"contains":       js.FuncOf(contains),
+		"containsUint64": js.FuncOf(containsUint64),
+	}))
+	// The functions are called by JavaScript for the life of the page.
+	select {}
+}
@@ -0,0 +1,90 @@
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+	"strconv"
+	"syscall/js"
+	"testing"
+
+	"github.com/FastFilter/xorfilter"
+)
+
+// isError returns whether the result of a function is a JavaScript Error.
+func isError(result interface{}) bool {
+	v, ok := result.(js.Value)
+	return ok && v.InstanceOf(js.Global().Get("Error"))
+}
+
+func TestQueries(t *testing.T) {
+	filter = nil
+	if !isError(contains(js.Undefined(), []js.Value{js.ValueOf("a")})) {
+		t.Fatal("contains without a filter did not fail")
+	}
+	if !isError(containsUint64(js.Undefined(), []js.Value{js.ValueOf(1)})) {
+		t.Fatal("containsUint64 without a filter did not fail")
+	}
+
+	strings, err := xorfilter.PopulateBinaryFuse8FromStrings([]string{"alice@example.com", "bob@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	load := func(f *xorfilter.BinaryFuse8) interface{} {
+		data, err := f.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		array := js.Global().Get("Uint8Array").New(len(data))
+		js.CopyBytesToJS(array, data)
+		return loadFilter(js.Undefined(), []js.Value{array})
+	}
+	if result := load(strings); result != nil {
+		t.Fatalf("loadFilter: %v", result)
+	}
+	for _, key := range []string{"alice@example.com", "bob@example.com"} {
+		if result := contains(js.Undefined(), []js.Value{js.ValueOf(key)}); result != true {
+			t.Fatalf("contains(%q) = %v", key, result)
+		}
+	}
+	if !isError(contains(js.Undefined(), []js.Value{js.ValueOf(1)})) {
+		t.Fatal("contains of a Number did not fail")
+	}
+
+	keys := []uint64{1, 1 << 53, 1<<64 - 1}
+	numbers, err := xorfilter.PopulateBinaryFuse8(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := load(numbers); result != nil {
+		t.Fatalf("loadFilter: %v", result)
+	}
+	for _, key := range keys {
+		s := strconv.FormatUint(key, 10)
+		args := []js.Value{js.ValueOf(s), js.Global().Call("BigInt", s)}
+		if key <= 1<<53 {
+			args = append(args, js.ValueOf(float64(key)))
+		}
+		for _, arg := range args {
+			if result := containsUint64(js.Undefined(), []js.Value{arg}); result != true {
+				t.Fatalf("containsUint64(%v) = %v", arg, result)
+			}
+		}
+	}
+	for _, arg := range []js.Value{js.ValueOf(-1), js.ValueOf(1.5), js.ValueOf("x"), js.Null()} {
+		if !isError(containsUint64(js.Undefined(), []js.Value{arg})) {
+			t.Fatalf("containsUint64(%v) did not fail", arg)
+		}
+	}
+}
+
+func TestLoadFilterErrors(t *testing.T) {
+	for _, arg := range []js.Value{js.ValueOf("filter"), js.Null(), js.Global().Get("Uint8Array").New(3)} {
+		if !isError(loadFilter(js.Undefined(), []js.Value{arg})) {
+			t.Fatalf("loadFilter(%v) did not fail", arg)
+		}
+	}
+	if !isError(loadFilter(js.Undefined(), nil)) {
+		t.Fatal("loadFilter() did not fail")
+	}
+}
@@ -1,30 +1,25 @@
 package xorfilter
 
 import (
+	"bytes"
 	"errors"
 	"math"
+	"unsafe"
+
+	"github.com/FastFilter/xorfilter/hash"
 )
 
 func murmur64(h uint64) uint64 {
-	h ^= h >> 33
-	h *= 0xff51afd7ed558ccd
-	h ^= h >> 33
-	h *= 0xc4ceb9fe1a85ec53
-	h ^= h >> 33
-	return h
+	return hash.Murmur64(h)
 }
 
 // returns random number, modifies the seed
 func splitmix64(seed *uint64) uint64 {
-	*seed = *seed + 0x9E3779B97F4A7C15
-	z := *seed
-	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
-	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
-	return z ^ (z >> 31)
+	return hash.SplitMix64(seed)
 }
 
 func mixsplit(key, seed uint64) uint64 {
-	return murmur64(key + seed)
+	return hash.MixSplit(key, seed)
 }
 
 func rotl64(n uint64, c int) uint64 {
@@ -42,6 +37,9 @@ func fingerprint(hash uint64) uint64 {
 
 // Contains tell you whether the key is likely part of the set
 func (filter *Xor8) Contains(key uint64) bool {
+	if filter == nil || len(filter.Fingerprints) == 0 {
+		return false
+	}
 	hash := mixsplit(key, filter.Seed)
 	f := uint8(fingerprint(hash))
 	r0 := uint32(hash)
@@ -109,17 +107,31 @@ func resetSets(setsi []xorset) []xorset {
 // The maximum  number of iterations allowed before the populate function returns an error
 var MaxIterations = 1024
 
+// MaxXor8Keys is the largest number of keys of a Xor8 filter, whose sizes are
+// 32-bit. Larger sets go into a BinaryFuse8Big filter.
+const MaxXor8Keys = 3 << 30
+
+// xor8Capacity returns the number of fingerprints of a Xor8 filter of size
+// keys, a multiple of 3, or ErrTooManyKeys if there are more than
+// MaxXor8Keys keys.
+func xor8Capacity(size int) (uint32, error) {
+	if size < 0 || uint64(size) > MaxXor8Keys {
+		return 0, ErrTooManyKeys
+	}
+	capacity := 32 + uint32(math.Ceil(1.23*float64(size)))
+	return capacity / 3 * 3, nil // round it down to a multiple of 3
+}
+
 // Populate fills the filter with provided keys.
 // The caller is responsible to ensure that there are no duplicate keys.
 // The function may return an error after too many iterations: it is almost
 // surely an indication that you have duplicate keys.
 func Populate(keys []uint64) (*Xor8, error) {
 	size := len(keys)
-	if size == 0 {
-		return nil, errors.New("provide a non-empty set")
+	capacity, err := xor8Capacity(size)
+	if err != nil {
+		return nil, err
 	}
-	capacity := 32 + uint32(math.Ceil(1.23*float64(size)))
-	capacity = capacity / 3 * 3 // round it down to a multiple of 3
 
 	filter := &Xor8{}
 	var rngcounter uint64 = 1
@@ -127,7 +139,7 @@ func Populate(keys []uint64) (*Xor8, error) {
 	filter.BlockLength = capacity / 3
 
 	// slice capacity defaults to length
-	filter.Fingerprints = make([]uint8, capacity)
+	filter.Fingerprints = makeFingerprints(int(capacity), cacheLineSize)
 
 	stack := make([]keyindex, size)
 	Q0 := make([]keyindex, filter.BlockLength)
@@ -278,3 +290,55 @@ func Populate(keys []uint64) (*Xor8, error) {
 	}
 	return filter, nil
 }
+
+// SizeInBytes returns the size of the exported fields of the filter, which
+// is what needs to be serialized.
+func (filter *Xor8) SizeInBytes() uint64 {
+	if filter == nil {
+		return 0
+	}
+	return 8 + 4 + uint64(len(filter.Fingerprints))
+}
+
+// MemoryBytes returns the memory held by the filter.
+func (filter *Xor8) MemoryBytes() uint64 {
+	if filter == nil {
+		return 0
+	}
+	return uint64(unsafe.Sizeof(*filter)) + uint64(cap(filter.Fingerprints))
+}
+
+// BitsPerEntry returns the size of the fingerprints, in bits per key, for a
+// filter built from n distinct keys.
+func (filter *Xor8) BitsPerEntry(n int) float64 {
+	if filter == nil {
+		return 0
+	}
+	return float64(8*len(filter.Fingerprints)) / float64(n)
+}
+
+// FalsePositiveRate returns the theoretical probability that Contains returns
+// true for a key that is not in the set: one in 2^8 with 8-bit fingerprints.
+func (filter *Xor8) FalsePositiveRate() float64 {
+	return 1.0 / 256
+}
+
+// Clone returns a copy of the filter that does not share its fingerprints.
+func (filter *Xor8) Clone() *Xor8 {
+	if filter == nil {
+		return nil
+	}
+	clone := *filter
+	clone.Fingerprints = copyFingerprints(filter.Fingerprints)
+	return &clone
+}
+
+// Equal reports whether the filters have the same seed, parameters and
+// fingerprints.
+func (filter *Xor8) Equal(other *Xor8) bool {
+	if filter == nil || other == nil {
+		return filter == other
+	}
+	return filter.Seed == other.Seed && filter.BlockLength == other.BlockLength &&
+		bytes.Equal(filter.Fingerprints, other.Fingerprints)
+}
@@ -1,6 +1,8 @@
 package xorfilter
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"math/rand"
 	"testing"
@@ -172,3 +174,64 @@ func BenchmarkXor8bigContains50000000(b *testing.B) {
 		xor8big.Contains(rand.Uint64())
 	}
 }
+
+func TestXor8Tiny(t *testing.T) {
+	for n := 0; n < 8; n++ {
+		keys := make([]uint64, n)
+		for i := range keys {
+			keys[i] = rand.Uint64()
+		}
+		filter, err := Populate(keys)
+		assert.Equal(t, nil, err)
+		for _, v := range keys {
+			assert.Equal(t, true, filter.Contains(v))
+		}
+	}
+}
+
+func TestXor8Sizes(t *testing.T) {
+	keys := make([]uint64, SMALL_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, err := Populate(keys)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, uint64(12+len(filter.Fingerprints)), filter.SizeInBytes())
+	assert.True(t, filter.MemoryBytes() > filter.SizeInBytes())
+	assert.Equal(t, float64(8*len(filter.Fingerprints))/float64(len(keys)), filter.BitsPerEntry(len(keys)))
+}
+
+func TestXor8Validate(t *testing.T) {
+	keys := make([]uint64, SMALL_NUM_KEYS)
+	for i := range keys {
+		keys[i] = rand.Uint64()
+	}
+	filter, err := Populate(keys)
+	assert.Equal(t, nil, err)
+	data, err := filter.MarshalBinary()
+	assert.Equal(t, nil, err)
+	var decoded Xor8
+	assert.Equal(t, nil, decoded.UnmarshalBinary(data))
+	assert.True(t, filter.Equal(&decoded))
+	assert.NotNil(t, decoded.UnmarshalBinary(data[:len(data)-1]))
+	filter.BlockLength++
+	assert.NotNil(t, filter.Validate())
+	// A block length whose fingerprints would overflow 32-bit indexes is
+	// rejected, whatever the size of the data.
+	binary.LittleEndian.PutUint32(data[8:], 1<<31)
+	assert.True(t, errors.Is(decoded.UnmarshalBinary(data), ErrInvalidFilter))
+	assert.True(t, errors.Is((&Xor8{BlockLength: 1 << 31}).Validate(), ErrInvalidFilter))
+}
+
+func TestXor8TooManyKeys(t *testing.T) {
+	_, err := xor8Capacity(-1)
+	assert.Equal(t, ErrTooManyKeys, err)
+	if n := uint64(MaxXor8Keys); int(n+1) > 0 {
+		capacity, err := xor8Capacity(int(n))
+		assert.Equal(t, nil, err)
+		assert.True(t, uint64(capacity) > n)
+		assert.Equal(t, uint32(0), capacity%3)
+		_, err = xor8Capacity(int(n + 1))
+		assert.Equal(t, ErrTooManyKeys, err)
+	}
+}
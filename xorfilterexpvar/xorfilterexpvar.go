@@ -0,0 +1,119 @@
+// Package xorfilterexpvar publishes the size and the query counts of a
+// filter with the expvar package, for the services that serve
+// /debug/vars rather than Prometheus metrics:
+//
+//	f := xorfilterexpvar.Publish("denylist", filter, len(keys))
+//	f.Contains(key) // counted
+//
+// The variable is a JSON object such as
+//
+//	{"size_bytes": 1149, "keys": 1000, "false_positive_rate": 0.00390625,
+//	 "queries": 52, "positives": 3, "negatives": 49}
+package xorfilterexpvar
+
+import (
+	"encoding/json"
+	"expvar"
+	"sync"
+	"sync/atomic"
+
+	"github.com/FastFilter/xorfilter"
+)
+
+// A Filter is a filter whose size and query counts are published. It is an
+// expvar.Var.
+type Filter struct {
+	// The counters are first, for their 64-bit alignment on 32-bit
+	// platforms.
+	queries   uint64
+	positives uint64
+
+	mu     sync.RWMutex
+	filter xorfilter.Filter
+	keys   int
+}
+
+// New returns filter, of the given number of keys, with its queries counted,
+// but not published: publish it with expvar.Publish, or under a map with
+// expvar.Map.Set.
+func New(filter xorfilter.Filter, keys int) *Filter {
+	return &Filter{filter: filter, keys: keys}
+}
+
+// Publish is New, with the filter published as the expvar variable name. As
+// expvar.Publish, it panics if name is already published.
+func Publish(name string, filter xorfilter.Filter, keys int) *Filter {
+	f := New(filter, keys)
+	expvar.Publish(name, f)
+	return f
+}
+
+// SetFilter replaces the filter, of the given number of keys. The query
+// counts go on.
+func (f *Filter) SetFilter(filter xorfilter.Filter, keys int) {
+	f.mu.Lock()
+	f.filter = filter
+	f.keys = keys
+	f.mu.Unlock()
+}
+
+func (f *Filter) current() xorfilter.Filter {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.filter
+}
+
+// Contains returns whether key is part of the set, as the Contains method of
+// the filter, and counts the query.
+func (f *Filter) Contains(key uint64) bool {
+	found := f.current().Contains(key)
+	atomic.AddUint64(&f.queries, 1)
+	if found {
+		atomic.AddUint64(&f.positives, 1)
+	}
+	return found
+}
+
+// ContainsBatch sets out[i] to whether keys[i] is part of the set, with the
+// ContainsBatch method of the filter if it has one, and counts the queries.
+func (f *Filter) ContainsBatch(keys []uint64, out []bool) {
+	out = out[:len(keys)]
+	filter := f.current()
+	if b, ok := filter.(interface {
+		ContainsBatch(keys []uint64, out []bool)
+	}); ok {
+		b.ContainsBatch(keys, out)
+	} else {
+		for i, key := range keys {
+			out[i] = filter.Contains(key)
+		}
+	}
+	positives := uint64(0)
+	for _, found := range out {
+		if found {
+			positives++
+		}
+	}
+	atomic.AddUint64(&f.queries, uint64(len(keys)))
+	atomic.AddUint64(&f.positives, positives)
+}
+
+// String returns the variable in JSON, as expvar.Var.
+func (f *Filter) String() string {
+	f.mu.RLock()
+	filter, keys := f.filter, f.keys
+	f.mu.RUnlock()
+	// The positives are loaded first, so that they never exceed the
+	// queries.
+	positives := atomic.LoadUint64(&f.positives)
+	queries := atomic.LoadUint64(&f.queries)
+	data, _ := json.Marshal(struct {
+		SizeBytes         uint64  `json:"size_bytes"`
+		Keys              int     `json:"keys"`
+		FalsePositiveRate float64 `json:"false_positive_rate"`
+		Queries           uint64  `json:"queries"`
+		Positives         uint64  `json:"positives"`
+		Negatives         uint64  `json:"negatives"`
+	}{filter.SizeInBytes(), keys, filter.FalsePositiveRate(), queries, positives, queries - positives})
+	return string(data)
+}
@@ -0,0 +1,32 @@
+package xorfilterexpvar
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+
+	"github.com/FastFilter/xorfilter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublish(t *testing.T) {
+	keys := []uint64{1, 2, 3}
+	filter, err := xorfilter.PopulateBinaryFuse8(keys)
+	assert.Equal(t, nil, err)
+	f := Publish("xorfilterexpvar_test", filter, len(keys))
+	assert.Equal(t, f, expvar.Get("xorfilterexpvar_test"))
+	for _, key := range keys {
+		assert.True(t, f.Contains(key))
+	}
+	out := make([]bool, 2)
+	f.ContainsBatch([]uint64{1, 2}, out)
+	assert.Equal(t, []bool{true, true}, out)
+
+	var v map[string]interface{}
+	assert.Equal(t, nil, json.Unmarshal([]byte(f.String()), &v))
+	assert.Equal(t, float64(filter.SizeInBytes()), v["size_bytes"])
+	assert.Equal(t, float64(3), v["keys"])
+	assert.Equal(t, float64(5), v["queries"])
+	assert.Equal(t, float64(5), v["positives"])
+	assert.Equal(t, float64(0), v["negatives"])
+}
@@ -0,0 +1,124 @@
+package xorfiltergrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/FastFilter/xorfilter"
+	"google.golang.org/grpc"
+)
+
+// Client queries a remote filter through the Membership service.
+type Client struct {
+	c MembershipClient
+}
+
+// NewClient returns a Client of the Membership service on conn.
+func NewClient(conn grpc.ClientConnInterface) *Client {
+	return &Client{c: NewMembershipClient(conn)}
+}
+
+func (c *Client) contains(ctx context.Context, key *Key) (bool, error) {
+	resp, err := c.c.Contains(ctx, &ContainsRequest{Key: key})
+	if err != nil {
+		return false, err
+	}
+	return resp.GetContains(), nil
+}
+
+// Contains returns whether key is part of the remote set, as the Contains
+// method of the filter.
+func (c *Client) Contains(ctx context.Context, key uint64) (bool, error) {
+	return c.contains(ctx, &Key{Key: &Key_Number{Number: key}})
+}
+
+// ContainsString is Contains for a string key, as ContainsString.
+func (c *Client) ContainsString(ctx context.Context, key string) (bool, error) {
+	return c.contains(ctx, &Key{Key: &Key_Text{Text: key}})
+}
+
+// ContainsBytes is Contains for a binary key, as ContainsBytes.
+func (c *Client) ContainsBytes(ctx context.Context, key []byte) (bool, error) {
+	return c.contains(ctx, &Key{Key: &Key_Data{Data: key}})
+}
+
+// BatchContains returns whether each of keys is part of the remote set, in
+// a single request.
+func (c *Client) BatchContains(ctx context.Context, keys []uint64) ([]bool, error) {
+	req := &BatchContainsRequest{Keys: make([]*Key, len(keys))}
+	for i, key := range keys {
+		req.Keys[i] = &Key{Key: &Key_Number{Number: key}}
+	}
+	return c.batchContains(ctx, req)
+}
+
+// BatchContainsStrings is BatchContains for string keys.
+func (c *Client) BatchContainsStrings(ctx context.Context, keys []string) ([]bool, error) {
+	req := &BatchContainsRequest{Keys: make([]*Key, len(keys))}
+	for i, key := range keys {
+		req.Keys[i] = &Key{Key: &Key_Text{Text: key}}
+	}
+	return c.batchContains(ctx, req)
+}
+
+func (c *Client) batchContains(ctx context.Context, req *BatchContainsRequest) ([]bool, error) {
+	resp, err := c.c.BatchContains(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.GetContains()) != len(req.Keys) {
+		return nil, errors.New("xorfiltergrpc: the response does not match the keys")
+	}
+	return resp.GetContains(), nil
+}
+
+// maxPrealloc is the largest buffer allocated for a replicated filter before
+// its chunks arrive.
+const maxPrealloc = 64 << 20
+
+// Replicate fetches the remote filter, to query it locally, along with its
+// version. If knownVersion, the version of a filter fetched before, is still
+// current, it returns a nil filter and knownVersion.
+func (c *Client) Replicate(ctx context.Context, knownVersion uint64) (*xorfilter.BinaryFuse8, uint64, error) {
+	stream, err := c.c.Replicate(ctx, &ReplicateRequest{KnownVersion: knownVersion})
+	if err != nil {
+		return nil, 0, err
+	}
+	var data []byte
+	var size uint64
+	version := knownVersion
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		if data == nil {
+			version, size = chunk.GetVersion(), chunk.GetSize()
+			// The size announced by the server is not trusted with more
+			// than maxPrealloc bytes before the data arrives.
+			prealloc := size
+			if prealloc > maxPrealloc {
+				prealloc = maxPrealloc
+			}
+			data = make([]byte, 0, prealloc)
+		} else if chunk.GetVersion() != version {
+			return nil, 0, errors.New("xorfiltergrpc: the filter changed during its replication")
+		}
+		if uint64(len(chunk.GetData())) > size-uint64(len(data)) {
+			return nil, 0, errors.New("xorfiltergrpc: the filter is larger than announced")
+		}
+		data = append(data, chunk.GetData()...)
+	}
+	if data == nil {
+		return nil, knownVersion, nil
+	}
+	var filter xorfilter.BinaryFuse8
+	if err := filter.UnmarshalBinary(data); err != nil {
+		return nil, 0, err
+	}
+	return &filter, version, nil
+}
@@ -0,0 +1,16 @@
+// Package xorfiltergrpc serves the queries of a BinaryFuse8 filter over gRPC,
+// so that many frontends with little memory can query the filter of one
+// host, or replicate it. The service is defined in membership.proto:
+//
+//	srv := grpc.NewServer()
+//	xorfiltergrpc.RegisterMembershipServer(srv, xorfiltergrpc.NewServer(filter))
+//
+//	client := xorfiltergrpc.NewClient(conn)
+//	found, err := client.ContainsString(ctx, "key")
+//
+// The package is a module of its own, so that the xorfilter module does not
+// depend on gRPC. The code of the messages and of the service is generated
+// with protoc, protoc-gen-go and protoc-gen-go-grpc, by go generate.
+package xorfiltergrpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative membership.proto
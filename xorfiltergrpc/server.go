@@ -0,0 +1,138 @@
+package xorfiltergrpc
+
+import (
+	"context"
+	"sync"
+
+	"github.com/FastFilter/xorfilter"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// chunkSize is the size of the chunks of a replicated filter, well within the
+// 4 MiB that gRPC accepts in a message by default.
+const chunkSize = 1 << 20
+
+// DefaultMaxBatch is the largest number of keys of a BatchContains request,
+// unless the MaxBatch field of the Server sets another.
+const DefaultMaxBatch = 1 << 16
+
+// Server implements the Membership service for a filter, which SetFilter
+// replaces.
+type Server struct {
+	UnimplementedMembershipServer
+
+	// MaxBatch is the largest number of keys of a BatchContains request.
+	MaxBatch int
+
+	mu      sync.RWMutex
+	filter  *xorfilter.BinaryFuse8
+	data    []byte
+	version uint64
+}
+
+// NewServer returns a Server for filter, which may be nil until SetFilter is
+// called.
+func NewServer(filter *xorfilter.BinaryFuse8) *Server {
+	s := &Server{}
+	if filter != nil {
+		if err := s.SetFilter(filter); err != nil {
+			panic(err)
+		}
+	}
+	return s
+}
+
+// SetFilter replaces the filter of the server, and the version replicated.
+// The queries in progress complete with the previous filter.
+func (s *Server) SetFilter(filter *xorfilter.BinaryFuse8) error {
+	data, err := filter.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.filter = filter
+	s.data = data
+	s.version++
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Server) current() (*xorfilter.BinaryFuse8, []byte, uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.filter == nil {
+		return nil, nil, 0, status.Error(codes.Unavailable, "no filter")
+	}
+	return s.filter, s.data, s.version, nil
+}
+
+// contains returns whether key is part of the set of filter.
+func contains(filter *xorfilter.BinaryFuse8, key *Key) (bool, error) {
+	switch k := key.GetKey().(type) {
+	case *Key_Number:
+		return filter.Contains(k.Number), nil
+	case *Key_Text:
+		return filter.ContainsString(k.Text), nil
+	case *Key_Data:
+		return filter.ContainsBytes(k.Data), nil
+	}
+	return false, status.Error(codes.InvalidArgument, "missing key")
+}
+
+// Contains implements the Contains method of the service.
+func (s *Server) Contains(ctx context.Context, req *ContainsRequest) (*ContainsResponse, error) {
+	filter, _, _, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	found, err := contains(filter, req.GetKey())
+	if err != nil {
+		return nil, err
+	}
+	return &ContainsResponse{Contains: found}, nil
+}
+
+// BatchContains implements the BatchContains method of the service.
+func (s *Server) BatchContains(ctx context.Context, req *BatchContainsRequest) (*BatchContainsResponse, error) {
+	maxBatch := s.MaxBatch
+	if maxBatch <= 0 {
+		maxBatch = DefaultMaxBatch
+	}
+	if len(req.GetKeys()) > maxBatch {
+		return nil, status.Errorf(codes.InvalidArgument, "too many keys, at most %d", maxBatch)
+	}
+	filter, _, _, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	found := make([]bool, len(req.GetKeys()))
+	for i, key := range req.GetKeys() {
+		if found[i], err = contains(filter, key); err != nil {
+			return nil, err
+		}
+	}
+	return &BatchContainsResponse{Contains: found}, nil
+}
+
+// Replicate implements the Replicate method of the service.
+func (s *Server) Replicate(req *ReplicateRequest, stream Membership_ReplicateServer) error {
+	_, data, version, err := s.current()
+	if err != nil {
+		return err
+	}
+	if version == req.GetKnownVersion() {
+		return nil
+	}
+	for off := 0; off < len(data); off += chunkSize {
+		end := off + chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk := &FilterChunk{Version: version, Size: uint64(len(data)), Data: data[off:end]}
+		if err := stream.Send(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package xorfiltergrpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/FastFilter/xorfilter"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// serve starts a gRPC server of s on an in-memory listener, and returns a
+// Client connected to it.
+func serve(t *testing.T, s *Server) *Client {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	RegisterMembershipServer(srv, s)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return NewClient(conn)
+}
+
+func testFilter(t *testing.T, n int) ([]string, *xorfilter.BinaryFuse8) {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+	filter, err := xorfilter.PopulateBinaryFuse8FromStrings(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys, filter
+}
+
+func TestContains(t *testing.T) {
+	keys, filter := testFilter(t, 1000)
+	client := serve(t, NewServer(filter))
+	ctx := context.Background()
+	for _, key := range keys {
+		found, err := client.ContainsString(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !found {
+			t.Fatalf("missing key %q", key)
+		}
+		found, err = client.ContainsBytes(ctx, []byte(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !found {
+			t.Fatalf("missing key %q", key)
+		}
+	}
+	numbers, err := xorfilter.PopulateBinaryFuse8([]uint64{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = serve(t, NewServer(numbers))
+	for key := uint64(1); key <= 3; key++ {
+		found, err := client.Contains(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !found {
+			t.Fatalf("missing key %d", key)
+		}
+	}
+}
+
+func TestBatchContains(t *testing.T) {
+	keys, filter := testFilter(t, 1000)
+	s := NewServer(filter)
+	client := serve(t, s)
+	ctx := context.Background()
+	found, err := client.BatchContainsStrings(ctx, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != len(keys) {
+		t.Fatalf("%d results for %d keys", len(found), len(keys))
+	}
+	for i, ok := range found {
+		if !ok {
+			t.Fatalf("missing key %q", keys[i])
+		}
+	}
+	s.MaxBatch = 10
+	_, err = client.BatchContainsStrings(ctx, keys)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("got %v for too many keys, expected InvalidArgument", err)
+	}
+	found, err = client.BatchContains(ctx, nil)
+	if err != nil || len(found) != 0 {
+		t.Fatalf("got %v, %v for no keys", found, err)
+	}
+}
+
+func TestMissingKey(t *testing.T) {
+	_, filter := testFilter(t, 10)
+	s := NewServer(filter)
+	_, err := s.Contains(context.Background(), &ContainsRequest{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("got %v for a missing key, expected InvalidArgument", err)
+	}
+}
+
+func TestNoFilter(t *testing.T) {
+	client := serve(t, NewServer(nil))
+	ctx := context.Background()
+	if _, err := client.Contains(ctx, 1); status.Code(err) != codes.Unavailable {
+		t.Fatalf("got %v without a filter, expected Unavailable", err)
+	}
+	if _, _, err := client.Replicate(ctx, 0); status.Code(err) != codes.Unavailable {
+		t.Fatalf("got %v without a filter, expected Unavailable", err)
+	}
+}
+
+func TestReplicate(t *testing.T) {
+	// The filter is larger than a chunk, so that it takes several.
+	keys, filter := testFilter(t, 1<<20)
+	s := NewServer(filter)
+	client := serve(t, s)
+	ctx := context.Background()
+	replica, version, err := client.Replicate(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !replica.Equal(filter) {
+		t.Fatal("the replica differs from the filter")
+	}
+	for _, key := range keys[:1000] {
+		if !replica.ContainsString(key) {
+			t.Fatalf("missing key %q", key)
+		}
+	}
+
+	// Nothing is streamed while the version is current.
+	same, v, err := client.Replicate(ctx, version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same != nil || v != version {
+		t.Fatalf("got a filter of version %d, expected none", v)
+	}
+
+	_, next := testFilter(t, 10)
+	if err := s.SetFilter(next); err != nil {
+		t.Fatal(err)
+	}
+	replica, v, err = client.Replicate(ctx, version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v == version || replica == nil || !replica.Equal(next) {
+		t.Fatalf("got version %d, expected the replaced filter", v)
+	}
+}
@@ -0,0 +1,244 @@
+// Package xorfilterhttp serves the queries of a BinaryFuse8 filter over HTTP,
+// for a small membership service such as a denylist:
+//
+//	GET  /contains?key=k    {"key":"k","contains":true}
+//	POST /contains          {"keys":["k1","k2"]} -> {"contains":[true,false]}
+//	GET  /filter            the filter, encoded by MarshalBinary
+//	PUT  /filter            replaces the filter, if uploads are allowed
+//
+// The paths are relative to where the Handler is mounted, with
+// http.StripPrefix for instance.
+package xorfilterhttp
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/FastFilter/xorfilter"
+)
+
+// DefaultMaxBatch is the largest number of keys of a batch query, unless the
+// MaxBatch field of the Handler sets another.
+const DefaultMaxBatch = 1 << 16
+
+// maxKeyBytes bounds the average size of the keys of a batch query, in bytes,
+// to bound the size of its body.
+const maxKeyBytes = 4096
+
+// DefaultMaxUpload is the largest size of an uploaded filter, in bytes,
+// unless the MaxUpload field of the Handler sets another.
+const DefaultMaxUpload = 1 << 30
+
+// A Handler answers the queries of a filter over HTTP. The keys are strings,
+// queried with ContainsString, unless Numeric is set. The fields must not be
+// changed once the Handler serves requests; the filter may be, with
+// SetFilter.
+type Handler struct {
+	// Numeric makes the keys decimal uint64 integers, queried with Contains.
+	Numeric bool
+	// AllowUpload enables PUT /filter.
+	AllowUpload bool
+	// MaxBatch is the largest number of keys of a batch query.
+	MaxBatch int
+	// MaxUpload is the largest size of an uploaded filter, in bytes.
+	MaxUpload int64
+
+	mu     sync.RWMutex
+	filter *xorfilter.BinaryFuse8
+}
+
+// NewHandler returns a Handler that serves filter, which may be nil until a
+// filter is set or uploaded.
+func NewHandler(filter *xorfilter.BinaryFuse8) *Handler {
+	return &Handler{filter: filter}
+}
+
+// Filter returns the filter served.
+func (h *Handler) Filter() *xorfilter.BinaryFuse8 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.filter
+}
+
+// SetFilter replaces the filter served. The queries in progress complete
+// with the previous one.
+func (h *Handler) SetFilter(filter *xorfilter.BinaryFuse8) {
+	h.mu.Lock()
+	h.filter = filter
+	h.mu.Unlock()
+}
+
+// ServeHTTP routes the request to the endpoints of the package doc.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/contains":
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			h.contains(w, r)
+		case http.MethodPost:
+			h.containsBatch(w, r)
+		default:
+			methodNotAllowed(w, "GET, HEAD, POST")
+		}
+	case "/filter":
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			h.download(w, r)
+		case http.MethodPut:
+			if !h.AllowUpload {
+				methodNotAllowed(w, "GET, HEAD")
+				return
+			}
+			h.upload(w, r)
+		default:
+			if h.AllowUpload {
+				methodNotAllowed(w, "GET, HEAD, PUT")
+			} else {
+				methodNotAllowed(w, "GET, HEAD")
+			}
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func methodNotAllowed(w http.ResponseWriter, allow string) {
+	w.Header().Set("Allow", allow)
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+}
+
+// containsKey returns whether key is part of the set of filter.
+func (h *Handler) containsKey(filter *xorfilter.BinaryFuse8, key string) (bool, error) {
+	if !h.Numeric {
+		return filter.ContainsString(key), nil
+	}
+	n, err := strconv.ParseUint(key, 10, 64)
+	if err != nil {
+		return false, err
+	}
+	return filter.Contains(n), nil
+}
+
+func (h *Handler) contains(w http.ResponseWriter, r *http.Request) {
+	keys, ok := r.URL.Query()["key"]
+	if !ok || len(keys) != 1 {
+		http.Error(w, "expected a single key parameter", http.StatusBadRequest)
+		return
+	}
+	filter := h.Filter()
+	if filter == nil {
+		http.Error(w, "no filter", http.StatusServiceUnavailable)
+		return
+	}
+	found, err := h.containsKey(filter, keys[0])
+	if err != nil {
+		http.Error(w, "invalid key: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, struct {
+		Key      string `json:"key"`
+		Contains bool   `json:"contains"`
+	}{keys[0], found})
+}
+
+func (h *Handler) containsBatch(w http.ResponseWriter, r *http.Request) {
+	maxBatch := h.MaxBatch
+	if maxBatch <= 0 {
+		maxBatch = DefaultMaxBatch
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBatch)*maxKeyBytes)
+	var keys []string
+	if h.Numeric {
+		// The keys are JSON numbers, decoded as such rather than rounded
+		// to a float64.
+		var query struct {
+			Keys []json.Number `json:"keys"`
+		}
+		if !decodeJSON(w, r, &query) {
+			return
+		}
+		for _, key := range query.Keys {
+			keys = append(keys, string(key))
+		}
+	} else {
+		var query struct {
+			Keys []string `json:"keys"`
+		}
+		if !decodeJSON(w, r, &query) {
+			return
+		}
+		keys = query.Keys
+	}
+	if len(keys) > maxBatch {
+		http.Error(w, "too many keys, at most "+strconv.Itoa(maxBatch), http.StatusRequestEntityTooLarge)
+		return
+	}
+	filter := h.Filter()
+	if filter == nil {
+		http.Error(w, "no filter", http.StatusServiceUnavailable)
+		return
+	}
+	found := make([]bool, len(keys))
+	for i, key := range keys {
+		var err error
+		if found[i], err = h.containsKey(filter, key); err != nil {
+			http.Error(w, "invalid key: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	writeJSON(w, struct {
+		Contains []bool `json:"contains"`
+	}{found})
+}
+
+func (h *Handler) download(w http.ResponseWriter, r *http.Request) {
+	filter := h.Filter()
+	if filter == nil {
+		http.Error(w, "no filter", http.StatusNotFound)
+		return
+	}
+	data, err := filter.MarshalBinary()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+func (h *Handler) upload(w http.ResponseWriter, r *http.Request) {
+	maxUpload := h.MaxUpload
+	if maxUpload <= 0 {
+		maxUpload = DefaultMaxUpload
+	}
+	data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxUpload))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	var filter xorfilter.BinaryFuse8
+	if err := filter.UnmarshalBinary(data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.SetFilter(&filter)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeJSON decodes the body of r into v, and answers the error if it fails.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, "invalid query: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
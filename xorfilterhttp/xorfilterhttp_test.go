@@ -0,0 +1,75 @@
+package xorfilterhttp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/FastFilter/xorfilter"
+	"github.com/stretchr/testify/assert"
+)
+
+func serve(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return w
+}
+
+func TestHandler(t *testing.T) {
+	filter, err := xorfilter.PopulateBinaryFuse8FromStrings([]string{"evil.example", "bad.example"})
+	assert.Equal(t, nil, err)
+	h := NewHandler(filter)
+
+	w := serve(h, "GET", "/contains?key=evil.example", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"key":"evil.example","contains":true}`+"\n", w.Body.String())
+	w = serve(h, "GET", "/contains", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve(h, "POST", "/contains", `{"keys":["bad.example","good.example"]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"contains":[true,false]}`+"\n", w.Body.String())
+	w = serve(h, "POST", "/contains", `{"keys":`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	h.MaxBatch = 1
+	w = serve(h, "POST", "/contains", `{"keys":["a","b"]}`)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	w = serve(h, "GET", "/filter", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	data, _ := filter.MarshalBinary()
+	assert.Equal(t, data, w.Body.Bytes())
+
+	w = serve(h, "PUT", "/filter", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	w = serve(h, "GET", "/other", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestHandlerNumericUpload(t *testing.T) {
+	h := NewHandler(nil)
+	h.Numeric = true
+	h.AllowUpload = true
+	w := serve(h, "GET", "/contains?key=1", "")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	filter, err := xorfilter.PopulateBinaryFuse8([]uint64{1, 18446744073709551615})
+	assert.Equal(t, nil, err)
+	data, _ := filter.MarshalBinary()
+	req := httptest.NewRequest("PUT", "/filter", bytes.NewReader(data))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	w = serve(h, "POST", "/contains", `{"keys":[1,18446744073709551615,2]}`)
+	assert.Equal(t, `{"contains":[true,true,false]}`+"\n", w.Body.String())
+	w = serve(h, "GET", "/contains?key=x", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = serve(h, "PUT", "/filter", "garbage")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	body, _ := ioutil.ReadAll(serve(h, "GET", "/filter", "").Body)
+	assert.Equal(t, data, body)
+}
@@ -0,0 +1,166 @@
+// Package xorfiltermetrics instruments filters and their constructions with
+// Prometheus metrics:
+//
+//	m := xorfiltermetrics.New("denylist")
+//	prometheus.MustRegister(m)
+//	filter, err := m.PopulateBinaryFuse8(keys)
+//	f := m.Wrap(filter) // f.Contains counts the queries
+//
+// The metrics are xorfilter_queries_total, by result, positive or negative;
+// xorfilter_batch_size, the number of keys of the batch queries;
+// xorfilter_builds_total, by result, success or failure;
+// xorfilter_build_attempts_total, the attempts of the constructions, one
+// more than their retries; and xorfilter_build_duration_seconds. Each has a
+// "filter" label, the name given to New.
+//
+// The package is a module of its own, so that the xorfilter module does not
+// depend on Prometheus.
+package xorfiltermetrics
+
+import (
+	"github.com/FastFilter/xorfilter"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics are the metrics of the filters of a name. They are a
+// prometheus.Collector, to register.
+type Metrics struct {
+	queries       *prometheus.CounterVec
+	positives     prometheus.Counter
+	negatives     prometheus.Counter
+	batchSize     prometheus.Histogram
+	builds        *prometheus.CounterVec
+	buildAttempts prometheus.Counter
+	buildDuration prometheus.Histogram
+}
+
+// New returns the metrics of the filters named name, the value of their
+// "filter" label.
+func New(name string) *Metrics {
+	labels := prometheus.Labels{"filter": name}
+	m := &Metrics{
+		queries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "xorfilter_queries_total",
+			Help:        "Number of keys queried, by result.",
+			ConstLabels: labels,
+		}, []string{"result"}),
+		batchSize: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:        "xorfilter_batch_size",
+			Help:        "Number of keys of the batch queries.",
+			ConstLabels: labels,
+			Buckets:     prometheus.ExponentialBuckets(1, 4, 10),
+		}),
+		builds: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "xorfilter_builds_total",
+			Help:        "Number of filter constructions, by result.",
+			ConstLabels: labels,
+		}, []string{"result"}),
+		buildAttempts: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "xorfilter_build_attempts_total",
+			Help:        "Number of attempts of the filter constructions.",
+			ConstLabels: labels,
+		}),
+		buildDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:        "xorfilter_build_duration_seconds",
+			Help:        "Wall time of the filter constructions.",
+			ConstLabels: labels,
+			Buckets:     prometheus.ExponentialBuckets(0.001, 4, 10),
+		}),
+	}
+	// The counters of the queries are resolved once, out of the way of
+	// Contains.
+	m.positives = m.queries.WithLabelValues("positive")
+	m.negatives = m.queries.WithLabelValues("negative")
+	m.builds.WithLabelValues("success")
+	m.builds.WithLabelValues("failure")
+	return m
+}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.queries.Describe(ch)
+	m.batchSize.Describe(ch)
+	m.builds.Describe(ch)
+	m.buildAttempts.Describe(ch)
+	m.buildDuration.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.queries.Collect(ch)
+	m.batchSize.Collect(ch)
+	m.builds.Collect(ch)
+	m.buildAttempts.Collect(ch)
+	m.buildDuration.Collect(ch)
+}
+
+// ObserveBuild records a construction described by stats, which failed if
+// err is not nil.
+func (m *Metrics) ObserveBuild(stats xorfilter.BuildStats, err error) {
+	if err != nil {
+		m.builds.WithLabelValues("failure").Inc()
+	} else {
+		m.builds.WithLabelValues("success").Inc()
+	}
+	m.buildAttempts.Add(float64(stats.Iterations))
+	m.buildDuration.Observe(stats.Duration.Seconds())
+}
+
+// PopulateBinaryFuse8 is xorfilter.PopulateBinaryFuse8, recorded by
+// ObserveBuild. It needs the stats of the construction, so a WithStats
+// option among opts is overridden: to keep the stats, or to record the other
+// constructions, call ObserveBuild instead.
+func (m *Metrics) PopulateBinaryFuse8(keys []uint64, opts ...xorfilter.Option) (*xorfilter.BinaryFuse8, error) {
+	var stats xorfilter.BuildStats
+	filter, err := xorfilter.PopulateBinaryFuse8(keys, append(opts[:len(opts):len(opts)], xorfilter.WithStats(&stats))...)
+	m.ObserveBuild(stats, err)
+	return filter, err
+}
+
+// Filter is a filter whose queries are counted.
+type Filter struct {
+	xorfilter.Filter
+	m *Metrics
+}
+
+// Wrap returns filter with its queries counted by m.
+func (m *Metrics) Wrap(filter xorfilter.Filter) *Filter {
+	return &Filter{Filter: filter, m: m}
+}
+
+// Contains returns whether key is part of the set, as the Contains method of
+// the wrapped filter, and counts the query.
+func (f *Filter) Contains(key uint64) bool {
+	found := f.Filter.Contains(key)
+	if found {
+		f.m.positives.Inc()
+	} else {
+		f.m.negatives.Inc()
+	}
+	return found
+}
+
+// ContainsBatch sets out[i] to whether keys[i] is part of the set, with the
+// ContainsBatch method of the wrapped filter if it has one, and counts the
+// queries and the size of the batch.
+func (f *Filter) ContainsBatch(keys []uint64, out []bool) {
+	out = out[:len(keys)]
+	if b, ok := f.Filter.(interface {
+		ContainsBatch(keys []uint64, out []bool)
+	}); ok {
+		b.ContainsBatch(keys, out)
+	} else {
+		for i, key := range keys {
+			out[i] = f.Filter.Contains(key)
+		}
+	}
+	positives := 0
+	for _, found := range out {
+		if found {
+			positives++
+		}
+	}
+	f.m.positives.Add(float64(positives))
+	f.m.negatives.Add(float64(len(keys) - positives))
+	f.m.batchSize.Observe(float64(len(keys)))
+}
@@ -0,0 +1,39 @@
+package xorfiltermetrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	m := New("test")
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(m)
+	keys := []uint64{1, 2, 3, 4, 5}
+	filter, err := m.PopulateBinaryFuse8(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := m.Wrap(filter)
+	for _, key := range keys {
+		if !f.Contains(key) {
+			t.Fatalf("missing key %d", key)
+		}
+	}
+	out := make([]bool, len(keys))
+	f.ContainsBatch(keys, out)
+	if got := testutil.ToFloat64(m.positives); got != 10 {
+		t.Errorf("%v positive queries, expected 10", got)
+	}
+	if got := testutil.ToFloat64(m.builds.WithLabelValues("success")); got != 1 {
+		t.Errorf("%v builds, expected 1", got)
+	}
+	if got := testutil.ToFloat64(m.buildAttempts); got < 1 {
+		t.Errorf("%v build attempts, expected at least 1", got)
+	}
+	if got := testutil.CollectAndCount(m.batchSize); got != 1 {
+		t.Errorf("%v batch size metrics, expected 1", got)
+	}
+}
@@ -0,0 +1,156 @@
+// Package xorfiltertext serves the queries of a BinaryFuse8 filter with a
+// line protocol in the style of memcached, for the clients that can open a
+// TCP connection but not speak HTTP or gRPC:
+//
+//	CONTAINS <hex-key>   YES or NO
+//	QUIT                 closes the connection
+//
+// A key is in hexadecimal: its bytes, queried with ContainsBytes, or a
+// uint64 of up to 16 digits, queried with Contains, if Numeric is set. The
+// lines end with "\r\n", or "\n" from the client. An invalid command, or a
+// line longer than 4096 bytes, is answered with "ERROR <reason>", and the
+// connection stays open.
+package xorfiltertext
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/FastFilter/xorfilter"
+)
+
+// maxLineBytes is the longest command line read.
+const maxLineBytes = 4096
+
+// A Server answers the queries of a filter on its connections. Numeric must
+// not be changed once the Server serves connections; the filter may be, with
+// SetFilter.
+type Server struct {
+	// Numeric makes the keys uint64 integers rather than byte strings.
+	Numeric bool
+
+	mu     sync.RWMutex
+	filter *xorfilter.BinaryFuse8
+}
+
+// NewServer returns a Server for filter, which may be nil until SetFilter is
+// called.
+func NewServer(filter *xorfilter.BinaryFuse8) *Server {
+	return &Server{filter: filter}
+}
+
+// SetFilter replaces the filter of the server. The queries in progress
+// complete with the previous one.
+func (s *Server) SetFilter(filter *xorfilter.BinaryFuse8) {
+	s.mu.Lock()
+	s.filter = filter
+	s.mu.Unlock()
+}
+
+func (s *Server) current() *xorfilter.BinaryFuse8 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.filter
+}
+
+// Serve accepts the connections of l, and serves each in a goroutine of its
+// own. It returns the error of Accept, once l is closed for instance.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.ServeConn(conn)
+	}
+}
+
+// ServeConn answers the commands of conn until the client quits or the
+// connection fails, and then closes conn.
+func (s *Server) ServeConn(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReaderSize(conn, maxLineBytes)
+	w := bufio.NewWriter(conn)
+	defer w.Flush()
+	for {
+		line, err := r.ReadSlice('\n')
+		var reply string
+		if err == bufio.ErrBufferFull {
+			// Discard the rest of the line, and answer it like any
+			// invalid command.
+			for err == bufio.ErrBufferFull {
+				_, err = r.ReadSlice('\n')
+			}
+			if err != nil {
+				return
+			}
+			reply = "ERROR line too long"
+		} else if err != nil {
+			return
+		} else {
+			line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r"))
+			var quit bool
+			reply, quit = s.execute(line)
+			if quit {
+				return
+			}
+		}
+		w.WriteString(reply)
+		w.WriteString("\r\n")
+		// The replies to the commands pipelined by the client are sent
+		// together.
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// execute returns the reply to a command line, and whether the client quits.
+func (s *Server) execute(line []byte) (reply string, quit bool) {
+	fields := bytes.Fields(line)
+	if len(fields) == 0 {
+		return "ERROR empty command", false
+	}
+	switch string(bytes.ToUpper(fields[0])) {
+	case "QUIT":
+		return "", true
+	case "CONTAINS":
+		if len(fields) != 2 {
+			return "ERROR expected CONTAINS <hex-key>", false
+		}
+		filter := s.current()
+		if filter == nil {
+			return "ERROR no filter", false
+		}
+		found, err := s.contains(filter, fields[1])
+		if err != nil {
+			return "ERROR invalid key", false
+		}
+		if found {
+			return "YES", false
+		}
+		return "NO", false
+	}
+	return "ERROR unknown command", false
+}
+
+func (s *Server) contains(filter *xorfilter.BinaryFuse8, key []byte) (bool, error) {
+	if s.Numeric {
+		n, err := strconv.ParseUint(string(key), 16, 64)
+		if err != nil {
+			return false, err
+		}
+		return filter.Contains(n), nil
+	}
+	b := make([]byte, hex.DecodedLen(len(key)))
+	if _, err := hex.Decode(b, key); err != nil {
+		return false, err
+	}
+	return filter.ContainsBytes(b), nil
+}
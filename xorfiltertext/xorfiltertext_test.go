@@ -0,0 +1,90 @@
+package xorfiltertext
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/FastFilter/xorfilter"
+	"github.com/stretchr/testify/assert"
+)
+
+func session(t *testing.T, s *Server, commands ...string) []string {
+	client, server := net.Pipe()
+	go s.ServeConn(server)
+	go func() {
+		client.Write([]byte(strings.Join(commands, "")))
+	}()
+	var replies []string
+	r := bufio.NewReader(client)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			break
+		}
+		replies = append(replies, line)
+	}
+	client.Close()
+	return replies
+}
+
+func TestServer(t *testing.T) {
+	filter, err := xorfilter.PopulateBinaryFuse8FromBytes([][]byte{{0xde, 0xad}, {0xbe, 0xef}})
+	assert.Equal(t, nil, err)
+	s := NewServer(filter)
+	replies := session(t, s,
+		"CONTAINS dead\r\n",
+		"contains BEEF\n",
+		"CONTAINS 0102\r\n",
+		"CONTAINS xyz\r\n",
+		"CONTAINS\r\n",
+		"FOO\r\n",
+		"QUIT\r\n",
+		"CONTAINS dead\r\n")
+	assert.Equal(t, []string{
+		"YES\r\n",
+		"YES\r\n",
+		"NO\r\n",
+		"ERROR invalid key\r\n",
+		"ERROR expected CONTAINS <hex-key>\r\n",
+		"ERROR unknown command\r\n",
+	}, replies)
+}
+
+func TestServerNumeric(t *testing.T) {
+	s := NewServer(nil)
+	s.Numeric = true
+	assert.Equal(t, []string{"ERROR no filter\r\n"}, session(t, s, "CONTAINS ff\r\n", "QUIT\r\n"))
+	filter, err := xorfilter.PopulateBinaryFuse8([]uint64{0xff, 0xffffffffffffffff})
+	assert.Equal(t, nil, err)
+	s.SetFilter(filter)
+	assert.Equal(t, []string{"YES\r\n", "YES\r\n", "NO\r\n", "ERROR invalid key\r\n"},
+		session(t, s, "CONTAINS ff\r\n", "CONTAINS ffffffffffffffff\r\n", "CONTAINS 1\r\n", "CONTAINS 10000000000000000\r\n", "QUIT\r\n"))
+}
+
+func TestServerLongLine(t *testing.T) {
+	filter, err := xorfilter.PopulateBinaryFuse8FromBytes([][]byte{{0xde, 0xad}})
+	assert.Equal(t, nil, err)
+	s := NewServer(filter)
+	// The connection stays open after an oversized line, as after any
+	// invalid command.
+	assert.Equal(t, []string{"ERROR line too long\r\n", "YES\r\n", "ERROR unknown command\r\n", "NO\r\n"},
+		session(t, s,
+			"CONTAINS "+strings.Repeat("a", 3*maxLineBytes)+"\r\n",
+			"CONTAINS dead\r\n",
+			"FOO\r\n",
+			"CONTAINS beef\r\n",
+			"QUIT\r\n"))
+	// A client that never ends its oversized line is disconnected when it
+	// closes its side.
+	client, server := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		s.ServeConn(server)
+		close(done)
+	}()
+	client.Write([]byte(strings.Repeat("a", 2*maxLineBytes)))
+	client.Close()
+	<-done
+}